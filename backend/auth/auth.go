@@ -0,0 +1,117 @@
+// Package auth verifies login credentials against the configured backends:
+// the local users table and, optionally, an LDAP / Active Directory server.
+// OpenID Connect single sign-on (OIDCProvider) is a separate redirect-based flow.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"student-management/config"
+	"student-management/logger"
+	"student-management/models"
+)
+
+var (
+	// ErrInvalidCredentials means the username is unknown to the backend or the password is wrong
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+	// ErrNoRole means the directory accepted the credentials but none of the user's groups maps to a role
+	ErrNoRole = errors.New("auth: user has no role")
+)
+
+// Authenticator verifies a username and password and returns the matching local user.
+// It returns ErrInvalidCredentials when the credentials are not accepted; any other
+// error means the backend could not decide (e.g. the directory is unreachable).
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (models.User, error)
+}
+
+// NewFromEnv creates the authenticator selected by AUTH_BACKENDS, a comma-separated list
+// of backends tried in order: "db" (default) and "ldap" (configured by the LDAP_* variables).
+func NewFromEnv(db *sql.DB) (Authenticator, error) {
+	var chain Chain
+	for _, name := range strings.Split(config.GetEnv("AUTH_BACKENDS", "db"), ",") {
+		switch strings.TrimSpace(name) {
+		case "db":
+			chain = append(chain, &DBAuthenticator{DB: db})
+		case "ldap":
+			ldapAuth, err := NewLDAPFromEnv(db)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, ldapAuth)
+		case "":
+		default:
+			return nil, fmt.Errorf("auth: unknown backend %q in AUTH_BACKENDS", name)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("auth: AUTH_BACKENDS is empty")
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// Chain tries each authenticator in order and returns the first success.
+// If every backend rejects the credentials the result is ErrInvalidCredentials; if a backend
+// failed and none succeeded, its error is returned so that an outage is not counted as a failed login.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	var backendErr error
+	for _, authenticator := range c {
+		user, err := authenticator.Authenticate(ctx, username, password)
+		switch {
+		case err == nil:
+			return user, nil
+		case err == ErrInvalidCredentials:
+			continue
+		case err == ErrNoRole:
+			return models.User{}, err
+		default:
+			logger.FromContext(ctx).Error("authentication backend failed", "backend", fmt.Sprintf("%T", authenticator), "error", err)
+			backendErr = err
+		}
+	}
+	if backendErr != nil {
+		return models.User{}, backendErr
+	}
+	return models.User{}, ErrInvalidCredentials
+}
+
+// DBAuthenticator checks passwords of local accounts against the users table.
+// Hashes that use outdated parameters are transparently upgraded after a successful check.
+type DBAuthenticator struct {
+	DB *sql.DB
+}
+
+// Authenticate implements Authenticator
+func (a *DBAuthenticator) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := models.GetUserByUsername(a.DB, username)
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrInvalidCredentials
+	} else if err != nil {
+		return models.User{}, err
+	}
+
+	// Directory accounts have no usable local password
+	if user.AuthSource != models.AuthSourceLocal || !models.CheckPasswordHash(password, user.Password) {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if models.NeedsRehash(user.Password) {
+		hash, err := models.HashPassword(password)
+		if err == nil {
+			err = models.UpdatePasswordHash(a.DB, user.ID, user.Password, hash)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to upgrade password hash", "user_id", user.ID, "error", err)
+		}
+	}
+	return user, nil
+}
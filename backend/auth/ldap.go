@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"student-management/config"
+	"student-management/logger"
+	"student-management/models"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPConn is the part of *ldap.Conn used by LDAPAuthenticator.
+// Tests can provide an in-process fake directory through LDAPAuthenticator.Dial.
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// GroupRole maps members of a directory group to a role
+type GroupRole struct {
+	Group *ldap.DN
+	Role  string
+}
+
+// LDAPConfig configures LDAPAuthenticator
+type LDAPConfig struct {
+	URL       string      // ldap://host:389 or ldaps://host:636
+	StartTLS  bool        // upgrade ldap:// connections with StartTLS
+	TLSConfig *tls.Config // used for ldaps:// and StartTLS
+	Timeout   time.Duration
+
+	BindDN       string // service account used to look up users; empty for an anonymous search
+	BindPassword string
+
+	BaseDN         string // where users are searched
+	UserFilter     string // %s is replaced with the escaped username, e.g. (uid=%s) or (sAMAccountName=%s)
+	EmailAttribute string
+
+	GroupAttribute string // attribute on the user entry listing group DNs (memberOf), used when GroupFilter is empty
+	GroupBaseDN    string // where groups are searched when GroupFilter is set
+	GroupFilter    string // %s is replaced with the escaped user DN, e.g. (member=%s)
+
+	GroupRoles  []GroupRole // checked in order; the first group the user belongs to decides the role
+	DefaultRole string      // role for users in none of the groups; empty denies them
+}
+
+// LDAPAuthenticator verifies passwords by binding to an LDAP / Active Directory server as the user.
+// On success a local users row (auth_source "ldap") is created or its email and role are synced.
+type LDAPAuthenticator struct {
+	DB     *sql.DB
+	Config LDAPConfig
+	Dial   func() (LDAPConn, error) // defaults to connecting to Config.URL
+}
+
+// NewLDAPFromEnv creates an LDAPAuthenticator from the LDAP_* environment variables
+func NewLDAPFromEnv(db *sql.DB) (*LDAPAuthenticator, error) {
+	cfg := LDAPConfig{
+		URL:            config.GetEnv("LDAP_URL", ""),
+		StartTLS:       config.GetEnv("LDAP_START_TLS", "false") == "true",
+		Timeout:        config.GetDurationEnv("LDAP_TIMEOUT", 10*time.Second),
+		BindDN:         config.GetEnv("LDAP_BIND_DN", ""),
+		BindPassword:   config.GetEnv("LDAP_BIND_PASSWORD", ""),
+		BaseDN:         config.GetEnv("LDAP_BASE_DN", ""),
+		UserFilter:     config.GetEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		EmailAttribute: config.GetEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		GroupAttribute: config.GetEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		GroupBaseDN:    config.GetEnv("LDAP_GROUP_BASE_DN", ""),
+		GroupFilter:    config.GetEnv("LDAP_GROUP_FILTER", ""),
+		DefaultRole:    config.GetEnv("LDAP_DEFAULT_ROLE", ""),
+	}
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return nil, errors.New("auth: LDAP_URL and LDAP_BASE_DN are required for the ldap backend")
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+
+	groupRoles, err := ParseGroupRoles(config.GetEnv("LDAP_GROUP_ROLES", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.GroupRoles = groupRoles
+
+	cfg.TLSConfig = &tls.Config{}
+	if caFile := config.GetEnv("LDAP_CA_FILE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading LDAP_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("auth: LDAP_CA_FILE contains no certificates")
+		}
+		cfg.TLSConfig.RootCAs = pool
+	}
+
+	return &LDAPAuthenticator{DB: db, Config: cfg}, nil
+}
+
+// ParseGroupRoles parses a group-to-role mapping of the form "groupDN:role;groupDN:role"
+func ParseGroupRoles(value string) ([]GroupRole, error) {
+	var groupRoles []GroupRole
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("auth: invalid LDAP_GROUP_ROLES entry %q, expected groupDN:role", entry)
+		}
+		group, err := ldap.ParseDN(strings.TrimSpace(entry[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("auth: invalid group DN in LDAP_GROUP_ROLES entry %q: %w", entry, err)
+		}
+		role := strings.TrimSpace(entry[i+1:])
+		if !models.IsValidRoleName(role) {
+			return nil, fmt.Errorf("auth: invalid role in LDAP_GROUP_ROLES entry %q", entry)
+		}
+		groupRoles = append(groupRoles, GroupRole{Group: group, Role: role})
+	}
+	return groupRoles, nil
+}
+
+// Authenticate implements Authenticator
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept
+	if username == "" || password == "" {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return models.User{}, fmt.Errorf("auth: connecting to LDAP: %w", err)
+	}
+	defer conn.Close()
+
+	// Look up the user with the service account
+	if a.Config.BindDN != "" {
+		if err := conn.Bind(a.Config.BindDN, a.Config.BindPassword); err != nil {
+			return models.User{}, fmt.Errorf("auth: LDAP service bind: %w", err)
+		}
+	}
+	entry, err := a.findUser(conn, username)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// Verify the password by binding as the user
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, fmt.Errorf("auth: LDAP user bind: %w", err)
+	}
+
+	// Group lookups run as the service account again, since users may not be allowed to search
+	if a.Config.BindDN != "" && a.Config.GroupFilter != "" {
+		if err := conn.Bind(a.Config.BindDN, a.Config.BindPassword); err != nil {
+			return models.User{}, fmt.Errorf("auth: LDAP service bind: %w", err)
+		}
+	}
+	groups, err := a.userGroups(conn, entry)
+	if err != nil {
+		return models.User{}, err
+	}
+	role := a.roleFor(groups)
+	if role == "" {
+		logger.FromContext(ctx).Warn("directory user is not in any group mapped to a role", "username", username)
+		return models.User{}, ErrNoRole
+	}
+
+	user, err := models.ProvisionDirectoryUser(a.DB, models.AuthSourceLDAP, username, entry.GetAttributeValue(a.Config.EmailAttribute), role)
+	if err == models.ErrAuthSourceConflict {
+		// A local account with the same name takes precedence and is left to the db backend
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, err
+}
+
+func (a *LDAPAuthenticator) dial() (LDAPConn, error) {
+	if a.Dial != nil {
+		return a.Dial()
+	}
+
+	conn, err := ldap.DialURL(a.Config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.Config.Timeout}),
+		ldap.DialWithTLSConfig(a.Config.TLSConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(a.Config.Timeout)
+	if a.Config.StartTLS {
+		if err := conn.StartTLS(a.Config.TLSConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// findUser searches for exactly one entry matching the username
+func (a *LDAPAuthenticator) findUser(conn LDAPConn, username string) (*ldap.Entry, error) {
+	attributes := []string{"dn", a.Config.EmailAttribute}
+	if a.Config.GroupFilter == "" {
+		attributes = append(attributes, a.Config.GroupAttribute)
+	}
+	request := ldap.NewSearchRequest(
+		a.Config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.Config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.Config.UserFilter, ldap.EscapeFilter(username)),
+		attributes, nil,
+	)
+	result, err := conn.Search(request)
+	switch {
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject):
+		return nil, ErrInvalidCredentials
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded):
+		// The size limit of 2 is hit when the username matches several entries, so it is ambiguous
+		return nil, ErrInvalidCredentials
+	case err != nil:
+		return nil, fmt.Errorf("auth: LDAP user search: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// userGroups returns the DNs of the groups the user belongs to
+func (a *LDAPAuthenticator) userGroups(conn LDAPConn, entry *ldap.Entry) ([]string, error) {
+	if a.Config.GroupFilter == "" {
+		return entry.GetAttributeValues(a.Config.GroupAttribute), nil
+	}
+
+	request := ldap.NewSearchRequest(
+		a.Config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.Config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.Config.GroupFilter, ldap.EscapeFilter(entry.DN)),
+		[]string{"dn"}, nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("auth: LDAP group search: %w", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, group := range result.Entries {
+		groups = append(groups, group.DN)
+	}
+	return groups, nil
+}
+
+// roleFor returns the role of the first configured group the user is a member of, or the default role
+func (a *LDAPAuthenticator) roleFor(groups []string) string {
+	parsed := make([]*ldap.DN, 0, len(groups))
+	for _, group := range groups {
+		if dn, err := ldap.ParseDN(group); err == nil {
+			parsed = append(parsed, dn)
+		}
+	}
+	for _, mapping := range a.Config.GroupRoles {
+		for _, dn := range parsed {
+			if mapping.Group.EqualFold(dn) {
+				return mapping.Role
+			}
+		}
+	}
+	return a.Config.DefaultRole
+}
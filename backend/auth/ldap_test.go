@@ -0,0 +1,405 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"student-management/dbtest"
+	"student-management/models"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	serviceDN       = "cn=service,dc=example,dc=com"
+	servicePassword = "service-secret"
+	teachersDN      = "cn=teachers,ou=groups,dc=example,dc=com"
+	adminsDN        = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+// fakeDirectory is an in-process LDAPConn. Search understands the single-attribute
+// equality filters the tests configure, e.g. (uid=alice) or (member=uid=alice,...).
+type fakeDirectory struct {
+	entries   []*ldap.Entry
+	passwords map[string]string // DN -> password
+
+	binds    []string // DNs bound as, in order
+	searches []*ldap.SearchRequest
+	closed   bool
+}
+
+func newFakeDirectory() *fakeDirectory {
+	d := &fakeDirectory{passwords: map[string]string{serviceDN: servicePassword}}
+	d.addUser("uid=alice,ou=people,dc=example,dc=com", "alice", "alice-secret", "alice@example.com", "CN=Teachers,OU=Groups,DC=example,DC=com")
+	d.addUser("uid=bob,ou=people,dc=example,dc=com", "bob", "bob-secret", "bob@example.com")
+	d.addGroup(teachersDN, "uid=alice,ou=people,dc=example,dc=com")
+	d.addGroup(adminsDN, "uid=alice,ou=people,dc=example,dc=com")
+	return d
+}
+
+func (d *fakeDirectory) addUser(dn, uid, password, email string, memberOf ...string) {
+	d.entries = append(d.entries, ldap.NewEntry(dn, map[string][]string{
+		"uid":      {uid},
+		"mail":     {email},
+		"memberOf": memberOf,
+	}))
+	d.passwords[dn] = password
+}
+
+func (d *fakeDirectory) addGroup(dn string, members ...string) {
+	d.entries = append(d.entries, ldap.NewEntry(dn, map[string][]string{"member": members}))
+}
+
+func (d *fakeDirectory) Bind(username, password string) error {
+	d.binds = append(d.binds, username)
+	if want, ok := d.passwords[username]; !ok || want != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (d *fakeDirectory) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.searches = append(d.searches, request)
+	if len(d.binds) == 0 {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("anonymous search"))
+	}
+
+	filter := strings.TrimSuffix(strings.TrimPrefix(request.Filter, "("), ")")
+	attribute, value, ok := strings.Cut(filter, "=")
+	if !ok {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, fmt.Errorf("unsupported filter %q", request.Filter))
+	}
+	value = unescapeFilter(value)
+
+	result := &ldap.SearchResult{}
+	for _, entry := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(request.BaseDN)) {
+			continue
+		}
+		for _, v := range entry.GetAttributeValues(attribute) {
+			if strings.EqualFold(v, value) {
+				result.Entries = append(result.Entries, entry)
+				break
+			}
+		}
+		// Like a real server, stop at the size limit and report it together with the entries found so far
+		if request.SizeLimit > 0 && len(result.Entries) > request.SizeLimit {
+			result.Entries = result.Entries[:request.SizeLimit]
+			return result, ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded"))
+		}
+	}
+	return result, nil
+}
+
+func (d *fakeDirectory) Close() error {
+	d.closed = true
+	return nil
+}
+
+// unescapeFilter reverses ldap.EscapeFilter for the characters that occur in the tests' DNs
+func unescapeFilter(value string) string {
+	return strings.NewReplacer(`\28`, "(", `\29`, ")", `\2a`, "*", `\5c`, `\`).Replace(value)
+}
+
+// fakeUsers is an in-memory users table with the statements ProvisionDirectoryUser and ProvisionOIDCUser run
+type fakeUsers struct {
+	rows     map[int64]models.User
+	subjects map[[2]string]int64 // oidc_issuer and oidc_subject of bound users
+	nextID   int64
+	revoked  []int64 // users whose tokens were revoked
+}
+
+func newFakeUsers(users ...models.User) *fakeUsers {
+	u := &fakeUsers{rows: map[int64]models.User{}, subjects: map[[2]string]int64{}}
+	for _, user := range users {
+		u.nextID++
+		user.ID = u.nextID
+		u.rows[user.ID] = user
+	}
+	return u
+}
+
+func (u *fakeUsers) byUsername(username string) (models.User, bool) {
+	for _, user := range u.rows {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
+
+func (u *fakeUsers) handle(query string, args []driver.Value) (*dbtest.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT id, auth_source, role FROM users WHERE username = ?"):
+		result := dbtest.Rows([]string{"id", "auth_source", "role"})
+		if user, ok := u.byUsername(args[0].(string)); ok {
+			result.Rows = append(result.Rows, []driver.Value{user.ID, user.AuthSource, user.Role})
+		}
+		return result, nil
+	case strings.HasPrefix(query, "SELECT id, role FROM users WHERE oidc_issuer = ? AND oidc_subject = ?"):
+		result := dbtest.Rows([]string{"id", "role"})
+		if id, ok := u.subjects[[2]string{args[0].(string), args[1].(string)}]; ok {
+			result.Rows = append(result.Rows, []driver.Value{id, u.rows[id].Role})
+		}
+		return result, nil
+	case strings.HasPrefix(query, "SELECT auth_source FROM users WHERE username = ?"):
+		result := dbtest.Rows([]string{"auth_source"})
+		if user, ok := u.byUsername(args[0].(string)); ok {
+			result.Rows = append(result.Rows, []driver.Value{user.AuthSource})
+		}
+		return result, nil
+	case strings.HasPrefix(query, "INSERT INTO users"):
+		if _, ok := u.byUsername(args[0].(string)); ok {
+			return nil, errors.New("duplicate username")
+		}
+		u.nextID++
+		u.rows[u.nextID] = models.User{
+			ID:         u.nextID,
+			Username:   args[0].(string),
+			Password:   args[1].(string),
+			Email:      args[2].(string),
+			Role:       args[3].(string),
+			AuthSource: args[4].(string),
+		}
+		if len(args) == 7 {
+			u.subjects[[2]string{args[5].(string), args[6].(string)}] = u.nextID
+		}
+		return &dbtest.Result{LastInsertID: u.nextID, RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "UPDATE users SET email = ?, role = ?"):
+		user := u.rows[args[2].(int64)]
+		user.Email, user.Role = args[0].(string), args[1].(string)
+		u.rows[user.ID] = user
+		return &dbtest.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "UPDATE users SET tokens_revoked_at"):
+		u.revoked = append(u.revoked, args[1].(int64))
+		return &dbtest.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "UPDATE refresh_tokens"), strings.HasPrefix(query, "UPDATE sessions"):
+		return &dbtest.Result{}, nil
+	case strings.HasPrefix(query, "SELECT id, username, password, email, role, disabled, totp_enabled, auth_source"):
+		result := dbtest.Rows([]string{"id", "username", "password", "email", "role", "disabled", "totp_enabled", "auth_source", "created_at", "updated_at"})
+		if user, ok := u.rows[args[0].(int64)]; ok {
+			result.Rows = append(result.Rows, []driver.Value{
+				user.ID, user.Username, user.Password, user.Email, user.Role, user.Disabled, user.TOTPEnabled, user.AuthSource, time.Now(), time.Now(),
+			})
+		}
+		return result, nil
+	}
+	return nil, dbtest.Unexpected(query)
+}
+
+func mustParseDN(t *testing.T, dn string) *ldap.DN {
+	t.Helper()
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func newTestLDAP(t *testing.T, db *sql.DB, dir *fakeDirectory) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		DB: db,
+		Config: LDAPConfig{
+			BindDN:         serviceDN,
+			BindPassword:   servicePassword,
+			BaseDN:         "ou=people,dc=example,dc=com",
+			UserFilter:     "(uid=%s)",
+			EmailAttribute: "mail",
+			GroupAttribute: "memberOf",
+			GroupRoles: []GroupRole{
+				{Group: mustParseDN(t, adminsDN), Role: "admin"},
+				{Group: mustParseDN(t, teachersDN), Role: "teacher"},
+			},
+		},
+		Dial: func() (LDAPConn, error) { return dir, nil },
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		setup    func(a *LDAPAuthenticator, dir *fakeDirectory)
+		wantErr  error
+		wantRole string
+	}{
+		{
+			name:     "memberOf maps to role",
+			username: "alice",
+			password: "alice-secret",
+			wantRole: "teacher", // memberOf only lists the teachers group, compared case-insensitively
+		},
+		{
+			name:     "group search maps to the first configured role",
+			username: "alice",
+			password: "alice-secret",
+			setup: func(a *LDAPAuthenticator, dir *fakeDirectory) {
+				a.Config.GroupBaseDN = "ou=groups,dc=example,dc=com"
+				a.Config.GroupFilter = "(member=%s)"
+			},
+			wantRole: "admin",
+		},
+		{
+			name:     "default role for users without a mapped group",
+			username: "bob",
+			password: "bob-secret",
+			setup:    func(a *LDAPAuthenticator, dir *fakeDirectory) { a.Config.DefaultRole = "teacher" },
+			wantRole: "teacher",
+		},
+		{
+			name:     "no mapped group and no default role",
+			username: "bob",
+			password: "bob-secret",
+			wantErr:  ErrNoRole,
+		},
+		{
+			name:     "wrong password",
+			username: "alice",
+			password: "wrong",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "empty password",
+			username: "alice",
+			password: "",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "unknown user",
+			username: "mallory",
+			password: "secret",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "filter injection",
+			username: "*",
+			password: "alice-secret",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "username matches several entries",
+			username: "alice",
+			password: "alice-secret",
+			setup: func(a *LDAPAuthenticator, dir *fakeDirectory) {
+				dir.addUser("uid=alice,ou=staff,ou=people,dc=example,dc=com", "alice", "other-secret", "alice2@example.com")
+				dir.addUser("uid=alice,ou=alumni,ou=people,dc=example,dc=com", "alice", "third-secret", "alice3@example.com")
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newFakeDirectory()
+			users := newFakeUsers()
+			a := newTestLDAP(t, dbtest.Open(t, users.handle), dir)
+			if tt.setup != nil {
+				tt.setup(a, dir)
+			}
+
+			user, err := a.Authenticate(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if err != tt.wantErr {
+					t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+				}
+				if len(users.rows) != 0 {
+					t.Errorf("Authenticate created %d users, want none", len(users.rows))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Username != tt.username || user.Role != tt.wantRole || user.AuthSource != models.AuthSourceLDAP {
+				t.Errorf("Authenticate = %s/%s/%s, want %s/%s/%s",
+					user.Username, user.Role, user.AuthSource, tt.username, tt.wantRole, models.AuthSourceLDAP)
+			}
+			if !dir.closed {
+				t.Error("connection was not closed")
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateSearchSizeLimit(t *testing.T) {
+	dir := newFakeDirectory()
+	dir.addUser("uid=alice,ou=staff,ou=people,dc=example,dc=com", "alice", "alice-secret", "alice2@example.com")
+	a := newTestLDAP(t, dbtest.Open(t, newFakeUsers().handle), dir)
+
+	if _, err := a.Authenticate(context.Background(), "alice", "alice-secret"); err != ErrInvalidCredentials {
+		t.Fatalf("Authenticate error = %v, want ErrInvalidCredentials", err)
+	}
+	if got := dir.searches[0].SizeLimit; got != 2 {
+		t.Errorf("user search size limit = %d, want 2", got)
+	}
+	// The password must not be tried against either of the ambiguous entries
+	for _, dn := range dir.binds {
+		if dn != serviceDN {
+			t.Errorf("bound as %q after an ambiguous search", dn)
+		}
+	}
+}
+
+func TestLDAPAuthenticateServiceBindFailure(t *testing.T) {
+	dir := newFakeDirectory()
+	a := newTestLDAP(t, dbtest.Open(t, newFakeUsers().handle), dir)
+	a.Config.BindPassword = "wrong"
+
+	// A broken service account is a configuration error, not a wrong password
+	_, err := a.Authenticate(context.Background(), "alice", "alice-secret")
+	if err == nil || err == ErrInvalidCredentials {
+		t.Fatalf("Authenticate error = %v, want a service bind error", err)
+	}
+	if len(dir.searches) != 0 {
+		t.Errorf("searched %d times after the service bind failed", len(dir.searches))
+	}
+}
+
+func TestLDAPAuthenticateDialFailure(t *testing.T) {
+	a := newTestLDAP(t, dbtest.Open(t, newFakeUsers().handle), nil)
+	a.Dial = func() (LDAPConn, error) { return nil, errors.New("connection refused") }
+
+	_, err := a.Authenticate(context.Background(), "alice", "alice-secret")
+	if err == nil || err == ErrInvalidCredentials {
+		t.Fatalf("Authenticate error = %v, want a connection error", err)
+	}
+}
+
+func TestLDAPAuthenticateAuthSourceConflict(t *testing.T) {
+	// A local account named alice takes precedence over the directory user
+	users := newFakeUsers(models.User{Username: "alice", Role: "admin", AuthSource: models.AuthSourceLocal})
+	a := newTestLDAP(t, dbtest.Open(t, users.handle), newFakeDirectory())
+
+	if _, err := a.Authenticate(context.Background(), "alice", "alice-secret"); err != ErrInvalidCredentials {
+		t.Fatalf("Authenticate error = %v, want ErrInvalidCredentials", err)
+	}
+	if user := users.rows[1]; user.Role != "admin" || user.AuthSource != models.AuthSourceLocal {
+		t.Errorf("local user changed to %s/%s", user.Role, user.AuthSource)
+	}
+	if len(users.rows) != 1 {
+		t.Errorf("%d users, want 1", len(users.rows))
+	}
+}
+
+func TestLDAPAuthenticateSyncsRole(t *testing.T) {
+	users := newFakeUsers(models.User{Username: "alice", Email: "old@example.com", Role: "admin", AuthSource: models.AuthSourceLDAP})
+	a := newTestLDAP(t, dbtest.Open(t, users.handle), newFakeDirectory())
+
+	user, err := a.Authenticate(context.Background(), "alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.ID != 1 || user.Role != "teacher" || user.Email != "alice@example.com" {
+		t.Errorf("Authenticate = %d/%s/%s, want 1/teacher/alice@example.com", user.ID, user.Role, user.Email)
+	}
+	// Losing a role ends the user's existing sessions
+	if len(users.revoked) != 1 || users.revoked[0] != 1 {
+		t.Errorf("revoked tokens of %v, want [1]", users.revoked)
+	}
+}
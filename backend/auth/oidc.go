@@ -0,0 +1,465 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"student-management/config"
+	"student-management/models"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrOIDCDisabled means no OpenID Connect provider is configured
+	ErrOIDCDisabled = errors.New("auth: OpenID Connect is not configured")
+	// ErrInvalidIDToken means the ID token failed signature or claim validation
+	ErrInvalidIDToken = errors.New("auth: invalid ID token")
+)
+
+// clockSkew is tolerated when checking exp, iat and nbf of ID tokens
+const clockSkew = time.Minute
+
+// maxUsernameLength and maxIdentifierLength are the sizes of users.username and users.oidc_issuer/oidc_subject
+const (
+	maxUsernameLength   = 50
+	maxIdentifierLength = 255
+)
+
+// jwksRefreshInterval limits how often the provider's keys are refetched for unknown key IDs
+const jwksRefreshInterval = time.Minute
+
+// ClaimRole maps a value of the roles claim (e.g. a group name) to a role
+type ClaimRole struct {
+	Value string
+	Role  string
+}
+
+// OIDCConfig configures OIDCProvider
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string // this server's callback, registered with the provider
+	Scopes       []string
+
+	UsernameClaim string // claim used as the username of new users, e.g. preferred_username, email or sub
+	EmailClaim    string
+	RolesClaim    string      // claim holding a string or a list of strings, e.g. groups
+	ClaimRoles    []ClaimRole // checked in order; the first value the user has decides the role
+	DefaultRole   string      // role for users without a mapped value; empty denies them
+}
+
+// OIDCProvider implements the authorization code flow with PKCE against an OpenID Connect provider
+type OIDCProvider struct {
+	DB     *sql.DB
+	Config OIDCConfig
+	Client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCFromEnv creates an OIDCProvider from the OIDC_* environment variables, or returns nil if OIDC_ISSUER is not set
+func NewOIDCFromEnv(db *sql.DB) (*OIDCProvider, error) {
+	issuer := config.GetEnv("OIDC_ISSUER", "")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	cfg := OIDCConfig{
+		Issuer:        strings.TrimSuffix(issuer, "/"),
+		ClientID:      config.GetEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret:  config.GetEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:   config.GetEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		Scopes:        strings.Fields(config.GetEnv("OIDC_SCOPES", "openid profile email")),
+		UsernameClaim: config.GetEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		EmailClaim:    config.GetEnv("OIDC_EMAIL_CLAIM", "email"),
+		RolesClaim:    config.GetEnv("OIDC_ROLES_CLAIM", "groups"),
+		DefaultRole:   config.GetEnv("OIDC_DEFAULT_ROLE", ""),
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("auth: OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+
+	claimRoles, err := ParseClaimRoles(config.GetEnv("OIDC_CLAIM_ROLES", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClaimRoles = claimRoles
+
+	return &OIDCProvider{DB: db, Config: cfg, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// ParseClaimRoles parses a claim-value-to-role mapping of the form "value:role;value:role"
+func ParseClaimRoles(value string) ([]ClaimRole, error) {
+	var claimRoles []ClaimRole
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("auth: invalid OIDC_CLAIM_ROLES entry %q, expected value:role", entry)
+		}
+		role := strings.TrimSpace(entry[i+1:])
+		if !models.IsValidRoleName(role) {
+			return nil, fmt.Errorf("auth: invalid role in OIDC_CLAIM_ROLES entry %q", entry)
+		}
+		claimRoles = append(claimRoles, ClaimRole{Value: strings.TrimSpace(entry[:i]), Role: role})
+	}
+	return claimRoles, nil
+}
+
+// NewPKCEVerifier returns a random code verifier and its S256 code challenge
+func NewPKCEVerifier() (verifier, challenge string, err error) {
+	verifier, _, err = models.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to for signing in
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientID},
+		"redirect_uri":          {p.Config.RedirectURL},
+		"scope":                 {strings.Join(p.Config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the validated ID token claims
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"client_id":     {p.Config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &tokenResponse); err != nil {
+		if tokenResponse.Error != "" {
+			return nil, fmt.Errorf("auth: token request failed: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+		}
+		return nil, fmt.Errorf("auth: token request failed: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, errors.New("auth: token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, tokenResponse.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, lifetime and nonce of an ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{
+		ValidMethods:         []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		SkipClaimsValidation: true, // validated below with clock skew tolerance
+	}
+	_, err = parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	// The provider's own spelling of its issuer, which may end in a slash that OIDC_ISSUER lacks
+	case claims["iss"] != discovery.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	case !audienceContains(claims["aud"], p.Config.ClientID):
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	case claims["azp"] != nil && claims["azp"] != p.Config.ClientID:
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	case !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true):
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	case !claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), true):
+		return nil, fmt.Errorf("%w: token is issued in the future", ErrInvalidIDToken)
+	case !claims.VerifyNotBefore(now.Add(clockSkew).Unix(), false):
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidIDToken)
+	case claims["nonce"] != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// ProvisionUser maps ID token claims to a local user (auth_source "oidc"), creating it on first login.
+// The user is bound to the token's issuer and subject; the username claim only names a new account.
+func (p *OIDCProvider) ProvisionUser(claims jwt.MapClaims) (models.User, error) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if issuer == "" || len(issuer) > maxIdentifierLength || subject == "" || len(subject) > maxIdentifierLength {
+		return models.User{}, fmt.Errorf("%w: missing or invalid iss or sub claim", ErrInvalidIDToken)
+	}
+	username, _ := claims[p.Config.UsernameClaim].(string)
+	if username == "" || utf8.RuneCountInString(username) > maxUsernameLength {
+		return models.User{}, fmt.Errorf("%w: missing or invalid %s claim", ErrInvalidIDToken, p.Config.UsernameClaim)
+	}
+	email, _ := claims[p.Config.EmailClaim].(string)
+
+	role := p.roleFor(claimStrings(claims[p.Config.RolesClaim]))
+	if role == "" {
+		return models.User{}, ErrNoRole
+	}
+	return models.ProvisionOIDCUser(p.DB, issuer, subject, username, email, role)
+}
+
+func (p *OIDCProvider) roleFor(values []string) string {
+	for _, mapping := range p.Config.ClaimRoles {
+		for _, value := range values {
+			if value == mapping.Value {
+				return mapping.Role
+			}
+		}
+	}
+	return p.Config.DefaultRole
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery oidcDiscovery
+	if err := p.doJSON(req, &discovery); err != nil {
+		return nil, fmt.Errorf("auth: fetching OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Config.Issuer {
+		return nil, fmt.Errorf("auth: discovery document issuer %q does not match %q", discovery.Issuer, p.Config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("auth: discovery document is missing endpoints")
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// key returns the provider's public key with the given ID, refetching the key set when the ID is unknown
+func (p *OIDCProvider) key(ctx context.Context, kid string) (interface{}, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []providerJWK `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	p.keys = map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			p.keys[jwk.Kid] = key
+		}
+	}
+	p.keysAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookupKey finds a cached key; tokens without a kid are accepted only when the provider has a single key
+func (p *OIDCProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) error {
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return decodeErr
+}
+
+// providerJWK is an RSA or EC public key from the provider's key set
+type providerJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k providerJWK) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	for _, value := range claimStrings(aud) {
+		if value == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// claimStrings returns a claim that is either a string or a list of strings as a slice
+func claimStrings(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,428 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"student-management/dbtest"
+	"student-management/models"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	testClientID = "student-management"
+	testKeyID    = "test"
+	testNonce    = "nonce-123"
+)
+
+// testProvider is an httptest OpenID Connect provider serving discovery, JWKS and token endpoints
+type testProvider struct {
+	server *httptest.Server
+	issuer string // as published in the discovery document
+	key    *rsa.PrivateKey
+
+	idToken      string // returned by the token endpoint
+	codeVerifier string // last code_verifier received by the token endpoint
+}
+
+func newTestProvider(t *testing.T, issuerSuffix string) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := &testProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 tp.issuer,
+			"authorization_endpoint": tp.server.URL + "/authorize",
+			"token_endpoint":         tp.server.URL + "/token",
+			"jwks_uri":               tp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tp.codeVerifier = r.PostFormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": tp.idToken})
+	})
+	tp.server = httptest.NewServer(mux)
+	t.Cleanup(tp.server.Close)
+	tp.issuer = tp.server.URL + issuerSuffix
+	return tp
+}
+
+// provider returns an OIDCProvider configured the way NewOIDCFromEnv would, with the trailing slash trimmed
+func (tp *testProvider) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Config: OIDCConfig{Issuer: tp.server.URL, ClientID: testClientID},
+		Client: tp.server.Client(),
+	}
+}
+
+// claims returns valid ID token claims that individual tests modify
+func (tp *testProvider) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   tp.issuer,
+		"sub":   "user-1",
+		"aud":   testClientID,
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": testNonce,
+	}
+}
+
+func (tp *testProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return signRSA(t, tp.key, claims)
+}
+
+func signRSA(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	tp := newTestProvider(t, "")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := tp.claims()
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+		ok    bool
+	}{
+		{
+			name:  "valid",
+			token: func() string { return tp.sign(t, tp.claims()) },
+			nonce: testNonce,
+			ok:    true,
+		},
+		{
+			name: "audience list with azp",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) {
+					c["aud"] = []string{"other-client", testClientID}
+					c["azp"] = testClientID
+				}))
+			},
+			nonce: testNonce,
+			ok:    true,
+		},
+		{
+			name: "expired within clock skew",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-clockSkew / 2).Unix() }))
+			},
+			nonce: testNonce,
+			ok:    true,
+		},
+		{
+			name:  "bad signature",
+			token: func() string { return signRSA(t, otherKey, tp.claims()) },
+			nonce: testNonce,
+		},
+		{
+			name: "tampered payload",
+			token: func() string {
+				signed := tp.sign(t, tp.claims())
+				forged := tp.sign(t, with(func(c jwt.MapClaims) { c["sub"] = "admin" }))
+				return segment(forged, 0) + "." + segment(forged, 1) + "." + segment(signed, 2)
+			},
+			nonce: testNonce,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }))
+			},
+			nonce: testNonce,
+		},
+		{
+			name:  "missing issuer",
+			token: func() string { return tp.sign(t, with(func(c jwt.MapClaims) { delete(c, "iss") })) },
+			nonce: testNonce,
+		},
+		{
+			name:  "wrong audience",
+			token: func() string { return tp.sign(t, with(func(c jwt.MapClaims) { c["aud"] = "other-client" })) },
+			nonce: testNonce,
+		},
+		{
+			name: "wrong authorized party",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) {
+					c["aud"] = []string{testClientID, "other-client"}
+					c["azp"] = "other-client"
+				}))
+			},
+			nonce: testNonce,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() }))
+			},
+			nonce: testNonce,
+		},
+		{
+			name:  "missing expiry",
+			token: func() string { return tp.sign(t, with(func(c jwt.MapClaims) { delete(c, "exp") })) },
+			nonce: testNonce,
+		},
+		{
+			name: "issued in the future",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) { c["iat"] = time.Now().Add(2 * clockSkew).Unix() }))
+			},
+			nonce: testNonce,
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				return tp.sign(t, with(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(2 * clockSkew).Unix() }))
+			},
+			nonce: testNonce,
+		},
+		{
+			name:  "nonce mismatch",
+			token: func() string { return tp.sign(t, tp.claims()) },
+			nonce: "other-nonce",
+		},
+		{
+			name:  "missing nonce",
+			token: func() string { return tp.sign(t, with(func(c jwt.MapClaims) { delete(c, "nonce") })) },
+			nonce: testNonce,
+		},
+		{
+			name: "alg none",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, tp.claims())
+				token.Header["kid"] = testKeyID
+				signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+			nonce: testNonce,
+		},
+		{
+			// HMAC keyed with the provider's public key, the classic algorithm confusion attack
+			name: "HS256 with public key",
+			token: func() string {
+				public, err := x509.MarshalPKIXPublicKey(&tp.key.PublicKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, tp.claims())
+				token.Header["kid"] = testKeyID
+				signed, err := token.SignedString(public)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+			nonce: testNonce,
+		},
+		{
+			name: "unknown key ID",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, tp.claims())
+				token.Header["kid"] = "other"
+				signed, err := token.SignedString(tp.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+			nonce: testNonce,
+		},
+		{
+			name:  "malformed",
+			token: func() string { return "not-a-jwt" },
+			nonce: testNonce,
+		},
+	}
+
+	p := tp.provider()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.VerifyIDToken(context.Background(), tt.token(), tt.nonce)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("VerifyIDToken: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Errorf("sub = %v, want user-1", claims["sub"])
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("VerifyIDToken error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenIssuerTrailingSlash(t *testing.T) {
+	// The provider publishes its issuer with a trailing slash, OIDC_ISSUER was configured without one
+	tp := newTestProvider(t, "/")
+	p := tp.provider()
+
+	if _, err := p.VerifyIDToken(context.Background(), tp.sign(t, tp.claims()), testNonce); err != nil {
+		t.Fatalf("iss %q: %v", tp.issuer, err)
+	}
+
+	claims := tp.claims()
+	claims["iss"] = tp.server.URL
+	if _, err := p.VerifyIDToken(context.Background(), tp.sign(t, claims), testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("iss %q: error = %v, want ErrInvalidIDToken", tp.server.URL, err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	tp := newTestProvider(t, "/tenant")
+	if _, err := tp.provider().VerifyIDToken(context.Background(), tp.sign(t, tp.claims()), testNonce); err == nil {
+		t.Fatal("VerifyIDToken accepted a provider whose discovery issuer does not match")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tp := newTestProvider(t, "")
+	p := tp.provider()
+
+	tp.idToken = tp.sign(t, tp.claims())
+	claims, err := p.Exchange(context.Background(), "code", "verifier", testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Errorf("sub = %v, want user-1", claims["sub"])
+	}
+	if tp.codeVerifier != "verifier" {
+		t.Errorf("code_verifier = %q, want verifier", tp.codeVerifier)
+	}
+
+	// The ID token of a replayed callback belongs to another login attempt and carries its nonce
+	if _, err := p.Exchange(context.Background(), "code", "verifier", "other-nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Exchange with another nonce: error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+// segment returns the header (0), payload (1) or signature (2) of a compact JWT
+func segment(token string, i int) string {
+	return strings.Split(token, ".")[i]
+}
+
+func TestProvisionUser(t *testing.T) {
+	const issuer = "https://idp.example.com"
+	users := newFakeUsers(
+		models.User{Username: "admin", Role: "admin", AuthSource: models.AuthSourceLocal},
+		models.User{Username: "bob", Role: "teacher", AuthSource: models.AuthSourceOIDC},
+	)
+	users.subjects[[2]string{issuer, "bob-sub"}] = 2
+	p := &OIDCProvider{
+		DB: dbtest.Open(t, users.handle),
+		Config: OIDCConfig{
+			UsernameClaim: "preferred_username",
+			EmailClaim:    "email",
+			RolesClaim:    "groups",
+			ClaimRoles:    []ClaimRole{{Value: "staff", Role: "teacher"}},
+		},
+	}
+	// Claims as decoded from JSON
+	claims := func(sub, username string, groups ...interface{}) jwt.MapClaims {
+		return jwt.MapClaims{"iss": issuer, "sub": sub, "preferred_username": username, "email": username + "@example.com", "groups": groups}
+	}
+
+	// First login creates the account under the username claim
+	alice, err := p.ProvisionUser(claims("alice-sub", "alice", "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionUser: %v", err)
+	}
+	if alice.Username != "alice" || alice.Role != "teacher" || alice.AuthSource != models.AuthSourceOIDC {
+		t.Errorf("new user = %+v", alice)
+	}
+
+	// Later logins find the account by subject even after the username claim changed
+	again, err := p.ProvisionUser(claims("alice-sub", "alice.smith", "staff"))
+	if err != nil {
+		t.Fatalf("ProvisionUser after a rename: %v", err)
+	}
+	if again.ID != alice.ID || again.Username != "alice" {
+		t.Errorf("user after a rename = %d/%s, want %d/alice", again.ID, again.Username, alice.ID)
+	}
+	if len(users.rows) != 3 {
+		t.Errorf("%d users, want 3", len(users.rows))
+	}
+
+	// Another subject claiming a taken username does not get that account
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"username of another OIDC user", claims("mallory-sub", "bob", "staff"), models.ErrUsernameTaken},
+		{"username of a local user", claims("mallory-sub", "admin", "staff"), models.ErrAuthSourceConflict},
+		{"same subject from another issuer", jwt.MapClaims{"iss": "https://other.example.com", "sub": "bob-sub", "preferred_username": "bob", "groups": "staff"}, models.ErrUsernameTaken},
+		{"no role", claims("carol-sub", "carol"), ErrNoRole},
+		{"missing subject", claims("", "carol", "staff"), ErrInvalidIDToken},
+		{"subject too long", claims(strings.Repeat("s", 256), "carol", "staff"), ErrInvalidIDToken},
+		{"missing username", claims("carol-sub", "", "staff"), ErrInvalidIDToken},
+		{"username too long", claims("carol-sub", strings.Repeat("c", 51), "staff"), ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.ProvisionUser(tt.claims); !errors.Is(err, tt.want) {
+				t.Errorf("ProvisionUser error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if len(users.rows) != 3 {
+		t.Errorf("%d users after rejected logins, want 3", len(users.rows))
+	}
+
+	// The username length is counted in characters like VARCHAR(50)
+	if _, err := p.ProvisionUser(claims("dave-sub", strings.Repeat("学", 50), "staff")); err != nil {
+		t.Errorf("ProvisionUser with 50 characters: %v", err)
+	}
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
+	"student-management/logger"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -18,6 +20,36 @@ func GetEnv(key, defaultValue string) string {
 	return value
 }
 
+// GetIntEnv returns the environment variable parsed as an int, or the default value
+// if it is not set or cannot be parsed
+func GetIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("invalid integer in environment, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// GetDurationEnv returns the environment variable parsed as a time.Duration (e.g. "15m"),
+// or the default value if it is not set or cannot be parsed
+func GetDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("invalid duration in environment, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
 // InitDB initializes the database connection
 func InitDB() (*sql.DB, error) {
 	dbUser := GetEnv("DB_USER", "root")
@@ -41,6 +73,6 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
-	log.Println("Connected to database")
+	logger.Info("connected to database", "host", dbHost, "database", dbName)
 	return db, nil
 } 
\ No newline at end of file
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyController 处理系统集成 API 密钥的管理端点
+type APIKeyController struct {
+	DB *sql.DB
+}
+
+// NewAPIKeyController 创建新的 APIKeyController
+func NewAPIKeyController(db *sql.DB) *APIKeyController {
+	return &APIKeyController{DB: db}
+}
+
+// APIKeyRequest 表示创建 API 密钥的表单数据
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永不过期
+}
+
+// APIKeyCreatedResponse 是创建 API 密钥的响应，key 只在此时返回一次
+type APIKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// GetAPIKeys 处理 GET /api/api-keys 获取所有 API 密钥
+func (c *APIKeyController) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := models.GetAllAPIKeys(c.DB)
+	if err != nil {
+		http.Error(w, "获取 API 密钥列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// GetAPIKey 处理 GET /api/api-keys/{id} 获取指定 API 密钥
+func (c *APIKeyController) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseAPIKeyID(w, r)
+	if !ok {
+		return
+	}
+	key, err := models.GetAPIKey(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "API 密钥不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "获取 API 密钥失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// CreateAPIKey 处理 POST /api/api-keys 创建新的 API 密钥
+func (c *APIKeyController) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 验证字段
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > 100 {
+		http.Error(w, "名称为必填项，最多 100 个字符", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "至少需要一个权限", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			http.Error(w, "API 密钥不能授予该权限: "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "过期时间必须晚于当前时间", http.StatusBadRequest)
+		return
+	}
+
+	key := models.APIKey{Name: req.Name, Scopes: req.Scopes, ExpiresAt: req.ExpiresAt}
+	if claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims); ok && !claims.IsAPIKey() {
+		key.CreatedBy = &claims.UserID
+	}
+	tx, ok := beginTx(c.DB, w, "创建 API 密钥失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	plain, err := models.CreateAPIKey(tx, &key)
+	if err != nil {
+		http.Error(w, "创建 API 密钥失败", http.StatusInternalServerError)
+		return
+	}
+	createdKey, err := models.GetAPIKey(tx, key.ID)
+	if err != nil {
+		http.Error(w, "创建 API 密钥失败", http.StatusInternalServerError)
+		return
+	}
+	// 审计日志只记录密钥的元数据，不含密钥明文
+	entry := newAuditEntry(r, models.AuditAPIKeyCreate, models.AuditResourceAPIKey, key.ID)
+	if !commitAudited(tx, w, r, entry, nil, createdKey, "创建 API 密钥失败") {
+		return
+	}
+	logger.FromContext(r.Context()).Info("API 密钥已创建", "key_id", key.ID, "name", key.Name, "scopes", key.Scopes)
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIKeyCreatedResponse{APIKey: createdKey, Key: plain})
+}
+
+// RevokeAPIKey 处理 DELETE /api/api-keys/{id} 吊销 API 密钥，立即生效
+func (c *APIKeyController) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseAPIKeyID(w, r)
+	if !ok {
+		return
+	}
+	tx, ok := beginTx(c.DB, w, "吊销 API 密钥失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.RevokeAPIKey(tx, id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "API 密钥不存在或已被吊销", http.StatusNotFound)
+		} else {
+			http.Error(w, "吊销 API 密钥失败", http.StatusInternalServerError)
+		}
+		return
+	}
+	revokedKey, err := models.GetAPIKey(tx, id)
+	if err != nil {
+		http.Error(w, "吊销 API 密钥失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditAPIKeyRevoke, models.AuditResourceAPIKey, id)
+	if !commitAudited(tx, w, r, entry, nil, revokedKey, "吊销 API 密钥失败") {
+		return
+	}
+	logger.FromContext(r.Context()).Info("API 密钥已吊销", "key_id", id)
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAPIKeyID 从 URL 中解析 API 密钥 ID，失败时写入错误响应并返回 false
+func parseAPIKeyID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "无效的 API 密钥 ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
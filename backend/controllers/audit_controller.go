@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+	"time"
+)
+
+// AuditController 处理审计日志查询的端点
+type AuditController struct {
+	DB *sql.DB
+}
+
+// NewAuditController 创建新的 AuditController
+func NewAuditController(db *sql.DB) *AuditController {
+	return &AuditController{DB: db}
+}
+
+// GetAuditLogs 处理 GET /api/audit 按操作人、操作、对象和时间范围分页查询审计日志
+func (c *AuditController) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Action:     query.Get("action"),
+		Resource:   query.Get("resource"),
+		ResourceID: query.Get("resource_id"),
+	}
+
+	var ok bool
+	if filter.ActorID, ok = parseIDParam(w, query.Get("actor_id"), "actor_id"); !ok {
+		return
+	}
+	if filter.UserID, ok = parseIDParam(w, query.Get("user_id"), "user_id"); !ok {
+		return
+	}
+	if filter.From, ok = parseTimeParam(w, query.Get("from"), "from", false); !ok {
+		return
+	}
+	if filter.To, ok = parseTimeParam(w, query.Get("to"), "to", true); !ok {
+		return
+	}
+
+	// 分页参数
+	page, pageSize := 1, 20
+	if s := query.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "无效的 page 参数", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if s := query.Get("page_size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "无效的 page_size 参数", http.StatusBadRequest)
+			return
+		}
+		pageSize = n
+	}
+
+	logs, total, err := models.GetAuditLogs(c.DB, filter, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取审计日志失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": logs,
+		"pagination": map[string]interface{}{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// parseIDParam 解析可选的 ID 查询参数，失败时写入错误响应并返回 false
+func parseIDParam(w http.ResponseWriter, value, name string) (int64, bool) {
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "无效的 "+name+" 参数", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// parseTimeParam 解析可选的时间查询参数，支持 RFC 3339 和日期（2006-01-02）；
+// endOfDay 为 true 时只有日期的结束时间取次日零点，使该日期包含在范围内
+func parseTimeParam(w http.ResponseWriter, value, name string, endOfDay bool) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		http.Error(w, "无效的 "+name+" 参数，应为 RFC 3339 时间或 YYYY-MM-DD 日期", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// newAuditEntry 根据请求中的身份信息（用户、模拟登录的管理员或 API 密钥）创建一条审计日志
+func newAuditEntry(r *http.Request, action, resource string, resourceID interface{}) models.AuditLog {
+	entry := models.AuditLog{
+		Action:     action,
+		Resource:   resource,
+		ResourceID: fmt.Sprint(resourceID),
+		IP:         middleware.ClientIP(r),
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		return entry
+	}
+	switch {
+	case claims.IsAPIKey():
+		keyID := claims.APIKeyID
+		entry.APIKeyID = &keyID
+	case claims.IsImpersonated():
+		actorID, userID := claims.ActorID, claims.UserID
+		entry.ActorID = &actorID
+		entry.UserID = &userID
+	default:
+		userID := claims.UserID
+		entry.ActorID = &userID
+		entry.UserID = &userID
+	}
+	return entry
+}
+
+// newUserAuditEntry 为尚未携带令牌的请求（登录、重置密码）创建审计日志，操作人为 userID
+func newUserAuditEntry(r *http.Request, userID int64, action, resource string, resourceID interface{}) models.AuditLog {
+	entry := newAuditEntry(r, action, resource, resourceID)
+	entry.ActorID = &userID
+	entry.UserID = &userID
+	return entry
+}
+
+// newSystemAuditEntry 为后台任务（如回收站定期清理）创建审计日志，没有操作人和 IP
+func newSystemAuditEntry(action, resource string, resourceID interface{}) models.AuditLog {
+	return models.AuditLog{
+		Action:     action,
+		Resource:   resource,
+		ResourceID: fmt.Sprint(resourceID),
+	}
+}
+
+// recordAudit 写入审计日志，before 和 after 是操作前后的数据，可以为 nil。
+// db 通常是操作所在的事务，写入失败时由调用方回滚操作，不留下没有审计记录的修改
+func recordAudit(db models.DBTX, entry models.AuditLog, before, after interface{}) error {
+	entry.Before = models.AuditJSON(before)
+	entry.After = models.AuditJSON(after)
+	return models.RecordAudit(db, entry)
+}
+
+// beginTx 开始一个事务，用于把数据修改和审计日志一起提交；失败时返回 500 和 message
+func beginTx(db *sql.DB, w http.ResponseWriter, message string) (*sql.Tx, bool) {
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, message, http.StatusInternalServerError)
+		return nil, false
+	}
+	return tx, true
+}
+
+// commitAudited 在事务 tx 中写入审计日志后提交。任何一步失败都返回 500 和 message，
+// 操作随事务回滚；调用方仍需 defer tx.Rollback()
+func commitAudited(tx *sql.Tx, w http.ResponseWriter, r *http.Request, entry models.AuditLog, before, after interface{}, message string) bool {
+	if err := recordAudit(tx, entry, before, after); err != nil {
+		logger.FromContext(r.Context()).Error("写入审计日志失败", "action", entry.Action, "error", err)
+		http.Error(w, message, http.StatusInternalServerError)
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, message, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// requireAudit 在事务之外写入审计日志，用于导出等不修改数据的操作，须在操作开始前调用：
+// 写入失败时返回 500 和 message，操作不再进行
+func requireAudit(db models.DBTX, w http.ResponseWriter, r *http.Request, entry models.AuditLog, message string) bool {
+	if err := recordAudit(db, entry, nil, nil); err != nil {
+		logger.FromContext(r.Context()).Error("写入审计日志失败", "action", entry.Action, "error", err)
+		http.Error(w, message, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
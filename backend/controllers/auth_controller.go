@@ -3,10 +3,19 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"student-management/auth"
+	"student-management/config"
+	"student-management/logger"
+	"student-management/mailer"
 	"student-management/middleware"
 	"student-management/models"
+	"student-management/validation"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -14,24 +23,132 @@ import (
 
 // AuthController 处理认证相关的端点
 type AuthController struct {
-	DB *sql.DB
+	DB              *sql.DB
+	Authenticator   auth.Authenticator // 验证用户名和密码（本地数据库、LDAP 等）
+	AccessTokenTTL  time.Duration      // 访问令牌（JWT）有效期
+	RefreshTokenTTL time.Duration      // 刷新令牌有效期
+	UserLoginPolicy models.LoginPolicy // 按用户名统计的登录失败策略，达到上限后锁定账号
+	IPLoginPolicy   models.LoginPolicy // 按 IP 统计的登录失败策略，达到上限后拒绝该 IP 的登录请求
+
+	Mailer           mailer.Mailer         // 发送密码重置邮件
+	ForgotPolicy     models.LoginPolicy    // 按邮箱统计的重置密码申请次数限制
+	ForgotIPPolicy   models.LoginPolicy    // 按 IP 统计的重置密码申请次数限制
+	PasswordResetTTL time.Duration         // 密码重置令牌有效期
+	PasswordResetURL string                // 前端重置密码页面地址，令牌以 ?token= 附加
+	PasswordPolicy   models.PasswordPolicy // 修改或重置密码时新密码需满足的策略
+
+	TOTPIssuer      string          // 认证器应用中显示的签发者名称
+	MFAChallengeTTL time.Duration   // 登录两步验证挑战的有效期
+	Require2FARoles map[string]bool // 必须启用两步验证的角色
+
+	OIDC            *auth.OIDCProvider // OpenID Connect 单点登录，未配置时为 nil
+	OIDCStateTTL    time.Duration      // 单点登录授权请求的有效期
+	OIDCFrontendURL string             // 前端单点登录回调页面，结果以 URL 片段附加
+
+	ImpersonationTTL time.Duration // 管理员模拟用户时签发的令牌有效期
 }
 
-// NewAuthController 创建新的 AuthController
+// NewAuthController 创建新的 AuthController，令牌有效期和登录失败策略可通过环境变量配置
 func NewAuthController(db *sql.DB) *AuthController {
-	return &AuthController{DB: db}
+	lockout := config.GetDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	backoff := config.GetDurationEnv("LOGIN_BACKOFF_BASE", time.Second)
+	window := config.GetDurationEnv("LOGIN_FAILURE_WINDOW", time.Hour)
+	forgotWindow := config.GetDurationEnv("FORGOT_PASSWORD_WINDOW", time.Hour)
+
+	authenticator, err := auth.NewFromEnv(db)
+	if err != nil {
+		logger.Fatal("invalid authentication backend configuration", "error", err)
+	}
+	oidcProvider, err := auth.NewOIDCFromEnv(db)
+	if err != nil {
+		logger.Fatal("invalid OpenID Connect configuration", "error", err)
+	}
+
+	return &AuthController{
+		DB:              db,
+		Authenticator:   authenticator,
+		AccessTokenTTL:  config.GetDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: config.GetDurationEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		UserLoginPolicy: models.LoginPolicy{
+			MaxFailures:     config.GetIntEnv("LOGIN_MAX_FAILURES", 5),
+			LockoutDuration: lockout,
+			BackoffBase:     backoff,
+			FailureWindow:   window,
+		},
+		// 同一 IP 后面可能有很多用户（如校园网出口），只在达到上限后封禁，不做退避
+		IPLoginPolicy: models.LoginPolicy{
+			MaxFailures:     config.GetIntEnv("LOGIN_IP_MAX_FAILURES", 20),
+			LockoutDuration: lockout,
+			FailureWindow:   window,
+		},
+		ForgotPolicy: models.LoginPolicy{
+			MaxFailures:     config.GetIntEnv("FORGOT_PASSWORD_MAX_REQUESTS", 3),
+			LockoutDuration: forgotWindow,
+			FailureWindow:   forgotWindow,
+		},
+		ForgotIPPolicy: models.LoginPolicy{
+			MaxFailures:     config.GetIntEnv("FORGOT_PASSWORD_IP_MAX_REQUESTS", 10),
+			LockoutDuration: forgotWindow,
+			FailureWindow:   forgotWindow,
+		},
+		Mailer:           mailer.NewFromEnv(),
+		PasswordResetTTL: config.GetDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetURL: config.GetEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+		PasswordPolicy:   loadPasswordPolicy(),
+		TOTPIssuer:       config.GetEnv("TOTP_ISSUER", "Student Management"),
+		MFAChallengeTTL:  config.GetDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute),
+		Require2FARoles:  parseRoleList(config.GetEnv("REQUIRE_2FA_ROLES", "")),
+		OIDC:             oidcProvider,
+		OIDCStateTTL:     config.GetDurationEnv("OIDC_STATE_TTL", 10*time.Minute),
+		OIDCFrontendURL:  config.GetEnv("OIDC_FRONTEND_URL", "http://localhost:8080/oidc/callback"),
+		ImpersonationTTL: config.GetDurationEnv("IMPERSONATION_TTL", 30*time.Minute),
+	}
 }
 
+// parseRoleList 解析逗号分隔的角色列表
+func parseRoleList(value string) map[string]bool {
+	roles := map[string]bool{}
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles[role] = true
+		}
+	}
+	return roles
+}
+
+// maxUsernameLength 是用户名的最大长度（users.username 列）
+const maxUsernameLength = 50
+
 // LoginRequest 表示登录表单数据
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+// Validate 检查登录表单的各个字段
+func (req LoginRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if errs.Required("username", req.Username, "用户名为必填项") {
+		errs.MaxLength("username", req.Username, maxUsernameLength, fmt.Sprintf("用户名不能超过 %d 个字符", maxUsernameLength))
+	}
+	if req.Password == "" {
+		errs.Add("password", validation.Required, "密码为必填项")
+	}
+	return errs
+}
+
 // LoginResponse 表示登录成功后的响应
 type LoginResponse struct {
-	Token  string           `json:"token"`
-	User   models.UserProfile `json:"user"`
+	Token         string             `json:"token"`
+	RefreshToken  string             `json:"refresh_token"`
+	ExpiresIn     int64              `json:"expires_in"` // 访问令牌剩余有效秒数
+	User          models.UserProfile `json:"user"`
+	RecoveryCodes []string           `json:"recovery_codes,omitempty"` // 登录时完成两步验证设置才会返回
+}
+
+// RefreshRequest 表示刷新令牌或退出登录的请求数据
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // PasswordChangeRequest 表示修改密码的表单数据
@@ -40,68 +157,216 @@ type PasswordChangeRequest struct {
 	NewPassword string `json:"new_password"`
 }
 
+// Validate 检查修改密码表单的必填项；新密码是否符合密码策略在设置时检查
+func (req PasswordChangeRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if req.OldPassword == "" {
+		errs.Add("old_password", validation.Required, "旧密码为必填项")
+	}
+	if req.NewPassword == "" {
+		errs.Add("new_password", validation.Required, "新密码为必填项")
+	}
+	return errs
+}
+
 // Login 处理 POST /api/auth/login 用户认证
 func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	// 解析请求体
+	log := logger.FromContext(r.Context())
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("解析请求体错误: %v", err)
+		log.Debug("解析请求体错误", "error", err)
 		http.Error(w, "无效的请求体", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("用户登录尝试: %s", req.Username)
-	log.Printf("请求体: %+v", req)
+	log.Debug("用户登录尝试", "username", req.Username)
 
 	// 验证必填字段
-	if req.Username == "" || req.Password == "" {
-		log.Printf("缺少必填字段: username=%s, password=%s", req.Username, req.Password)
-		http.Error(w, "用户名和密码为必填项", http.StatusBadRequest)
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+
+	// 检查该用户名和 IP 是否处于退避或锁定状态
+	ip := middleware.ClientIP(r)
+	if !c.checkLoginThrottle(w, r, req.Username, ip) {
 		return
 	}
 
-	// 通过用户名查找用户
-	user, err := models.GetUserByUsername(c.DB, req.Username)
+	// 通过配置的认证后端验证用户名和密码
+	user, err := c.Authenticator.Authenticate(r.Context(), req.Username, req.Password)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("用户不存在: %s", req.Username)
-			http.Error(w, "无效的凭据", http.StatusUnauthorized)
-		} else {
-			log.Printf("数据库错误: %v", err)
-			http.Error(w, "认证失败", http.StatusInternalServerError)
+		switch err {
+		case auth.ErrInvalidCredentials:
+			log.Info("登录失败：用户名或密码错误", "username", req.Username)
+			c.loginFailed(w, r, req.Username, ip)
+		case auth.ErrNoRole:
+			http.Error(w, "该账号没有分配角色，无法登录", http.StatusForbidden)
+		default:
+			log.Error("认证后端出错", "username", req.Username, "error", err)
+			http.Error(w, "认证服务暂不可用", http.StatusServiceUnavailable)
 		}
 		return
 	}
 
-	log.Printf("找到用户: %+v", user)
+	// 被禁用的账号不能登录
+	if user.Disabled {
+		log.Info("登录失败：账号已被禁用", "username", req.Username)
+		http.Error(w, "账号已被禁用", http.StatusForbidden)
+		return
+	}
 
-	// 验证密码
-	if !models.CheckPasswordHash(req.Password, user.Password) {
-		log.Printf("用户密码无效: %s", req.Username)
-		http.Error(w, "无效的凭据", http.StatusUnauthorized)
+	// 已启用两步验证或角色要求两步验证时，先返回登录挑战，验证第二因素后再签发令牌
+	if user.TOTPEnabled || c.requires2FA(user.Role) {
+		c.startMFAChallenge(w, user)
 		return
 	}
 
-	log.Printf("用户密码验证成功: %s", req.Username)
+	// 登录成功，清除失败记录
+	c.loginSucceeded(r, req.Username)
+	log.Info("登录成功", "username", req.Username, "user_id", user.ID)
 
-	// 生成 JWT 令牌
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := middleware.Claims{
-		UserID: user.ID,
-		Role:   user.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	// 生成访问令牌和刷新令牌
+	response, err := c.issueLoginTokens(r, user)
+	if err != nil {
+		log.Error("创建令牌失败", "error", err)
+		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(middleware.JwtKey)
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkLoginThrottle 检查用户名和 IP 是否允许尝试登录。
+// 账号被锁定时返回 423，处于退避期或 IP 被封禁时返回 429，均附带 Retry-After 头。
+func (c *AuthController) checkLoginThrottle(w http.ResponseWriter, r *http.Request, username, ip string) bool {
+	now := time.Now()
+
+	userFailure, err := models.GetLoginFailure(c.DB, models.LoginScopeUser, username)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("查询登录失败记录失败", "error", err)
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return false
+	}
+	if wait, locked := userFailure.Check(c.UserLoginPolicy, now); wait > 0 {
+		writeRetryAfter(w, wait, locked)
+		return false
+	}
+
+	ipFailure, err := models.GetLoginFailure(c.DB, models.LoginScopeIP, ip)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("查询登录失败记录失败", "error", err)
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return false
+	}
+	if wait, _ := ipFailure.Check(c.IPLoginPolicy, now); wait > 0 {
+		// IP 被封禁不代表账号被锁定，统一返回 429
+		writeRetryAfter(w, wait, false)
+		return false
+	}
+	return true
+}
+
+// loginSucceeded 在完成全部认证步骤后清除用户名的登录失败记录。
+// IP 的记录保留到过期：否则攻击者可以在猜测其他账号的间隙用自己的账号登录来清零 IP 计数
+func (c *AuthController) loginSucceeded(r *http.Request, username string) {
+	if err := models.ClearLoginFailures(c.DB, models.LoginScopeUser, username); err != nil {
+		logger.FromContext(r.Context()).Error("清除登录失败记录失败", "error", err)
+	}
+}
+
+// loginFailed 记录一次失败的登录尝试并写入响应；若本次失败导致账号锁定则返回 423
+func (c *AuthController) loginFailed(w http.ResponseWriter, r *http.Request, username, ip string) {
+	log := logger.FromContext(r.Context())
+	userFailure, err := models.RecordLoginFailure(c.DB, models.LoginScopeUser, username, c.UserLoginPolicy)
+	if err != nil {
+		log.Error("记录登录失败失败", "error", err)
+	}
+	if _, err := models.RecordLoginFailure(c.DB, models.LoginScopeIP, ip, c.IPLoginPolicy); err != nil {
+		log.Error("记录登录失败失败", "error", err)
+	}
+
+	if wait, locked := userFailure.Check(c.UserLoginPolicy, time.Now()); locked {
+		log.Warn("登录失败次数过多，账号已锁定", "username", username, "ip", ip)
+		writeRetryAfter(w, wait, true)
+		return
+	}
+	http.Error(w, "无效的凭据", http.StatusUnauthorized)
+}
+
+// writeRetryAfter 写入 423（账号锁定）或 429（请求过于频繁）响应
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration, locked bool) {
+	if !locked {
+		writeTooManyRequests(w, wait, "登录尝试过于频繁，请稍后再试")
+		return
+	}
+	seconds := int64(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusLocked)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "账号因登录失败次数过多已被临时锁定",
+		"retry_after": seconds,
+	})
+}
+
+// writeTooManyRequests 写入附带 Retry-After 头的 429 响应
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       message,
+		"retry_after": seconds,
+	})
+}
+
+// Refresh 处理 POST /api/auth/refresh 使用刷新令牌换取新的令牌对（旧刷新令牌随即失效）
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "刷新令牌为必填项", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, refreshHash, err := models.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 轮换刷新令牌
+	userID, sessionID, err := models.RotateRefreshToken(c.DB, models.HashToken(req.RefreshToken), refreshHash, c.RefreshTokenTTL, middleware.ClientIP(r))
+	if err != nil {
+		switch err {
+		case models.ErrRefreshTokenReused:
+			logger.FromContext(r.Context()).Warn("检测到已吊销的刷新令牌被重复使用，已吊销该用户全部会话")
+			http.Error(w, "无效的刷新令牌", http.StatusUnauthorized)
+		case models.ErrRefreshTokenInvalid:
+			http.Error(w, "无效的刷新令牌", http.StatusUnauthorized)
+		default:
+			http.Error(w, "刷新令牌失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := models.GetUserByID(c.DB, userID)
+	if err != nil || user.Disabled {
+		http.Error(w, "无效的刷新令牌", http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, err := c.newAccessToken(user, sessionID)
 	if err != nil {
 		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
 		return
 	}
 
-	// 获取用户资料信息（不含密码）
 	profile, err := models.GetUserProfile(c.DB, user.ID)
 	if err != nil {
 		http.Error(w, "获取用户资料失败", http.StatusInternalServerError)
@@ -110,13 +375,93 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 
 	// 发送响应
 	response := LoginResponse{
-		Token: tokenString,
-		User:  profile,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(c.AccessTokenTTL.Seconds()),
+		User:         profile,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// issueLoginTokens 为通过认证的用户签发令牌并记录登录审计日志。
+// 会话和审计日志在同一事务中提交，审计日志写入失败时登录失败
+func (c *AuthController) issueLoginTokens(r *http.Request, user models.User) (LoginResponse, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	defer tx.Rollback()
+
+	response, sessionID, err := c.issueTokens(tx, r, user)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	if err := recordAudit(tx, newUserAuditEntry(r, user.ID, models.AuditLogin, models.AuditResourceSession, sessionID), nil, nil); err != nil {
+		return LoginResponse{}, err
+	}
+	return response, tx.Commit()
+}
+
+// issueTokens 在 db（通常是调用方的事务）中为用户开始一个新会话（记录设备和 IP），
+// 并签发访问令牌和刷新令牌，同时返回会话 ID
+func (c *AuthController) issueTokens(db models.DBTX, r *http.Request, user models.User) (LoginResponse, string, error) {
+	refreshToken, refreshHash, err := models.NewOpaqueToken()
+	if err != nil {
+		return LoginResponse{}, "", err
+	}
+	sessionID, err := models.StartSession(db, user.ID, r.UserAgent(), middleware.ClientIP(r), refreshHash, c.RefreshTokenTTL)
+	if err != nil {
+		return LoginResponse{}, "", err
+	}
+
+	accessToken, err := c.newAccessToken(user, sessionID)
+	if err != nil {
+		return LoginResponse{}, "", err
+	}
+
+	// 获取用户资料信息（不含密码）
+	profile, err := models.GetUserProfile(c.DB, user.ID)
+	if err != nil {
+		return LoginResponse{}, "", err
+	}
+
+	return LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(c.AccessTokenTTL.Seconds()),
+		User:         profile,
+	}, sessionID, nil
+}
+
+// newAccessToken 生成短期有效的 JWT 访问令牌，jti 用于吊销单个令牌，sid 用于吊销整个会话
+func (c *AuthController) newAccessToken(user models.User, sessionID string) (string, error) {
+	tokenID, _, err := models.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := middleware.Claims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(c.AccessTokenTTL).Unix(),
+		},
+	}
+
+	return middleware.Keys.Sign(claims)
+}
+
+// JWKS 处理 GET /api/auth/jwks 返回用于验证令牌的公钥（JSON Web Key Set）
+func (c *AuthController) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": middleware.Keys.PublicJWKS()})
+}
+
 // Profile 处理 GET /api/auth/profile 获取当前用户资料
 func (c *AuthController) Profile(w http.ResponseWriter, r *http.Request) {
 	// 从 JWT 声明中获取用户 ID
@@ -159,8 +504,8 @@ func (c *AuthController) ChangePassword(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 验证请求
-	if req.OldPassword == "" || req.NewPassword == "" {
-		http.Error(w, "旧密码和新密码为必填项", http.StatusBadRequest)
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
 		return
 	}
 
@@ -175,29 +520,103 @@ func (c *AuthController) ChangePassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// 目录账号的密码由目录服务管理
+	if user.AuthSource != models.AuthSourceLocal {
+		http.Error(w, "该账号的密码由目录服务管理，请在目录中修改", http.StatusBadRequest)
+		return
+	}
+
 	// 验证旧密码
 	if !models.CheckPasswordHash(req.OldPassword, user.Password) {
 		http.Error(w, "当前密码不正确", http.StatusBadRequest)
 		return
 	}
 
-	// 修改密码
-	err = models.ChangePassword(c.DB, claims.UserID, req.NewPassword)
+	// 修改密码、吊销旧会话、签发新令牌和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "更新密码失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	// 按密码策略修改密码
+	err = models.ChangePassword(tx, claims.UserID, req.NewPassword, c.PasswordPolicy)
 	if err != nil {
+		writePasswordError(w, err, "new_password", "更新密码失败")
+		return
+	}
+
+	// 吊销该用户的全部会话，并为当前客户端签发新的令牌
+	if err := models.RevokeUserTokens(tx, claims.UserID); err != nil {
 		http.Error(w, "更新密码失败", http.StatusInternalServerError)
 		return
 	}
+	tokens, _, err := c.issueTokens(tx, r, user)
+	if err != nil {
+		http.Error(w, "更新密码失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditPasswordChange, models.AuditResourceUser, claims.UserID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "更新密码失败") {
+		return
+	}
 
 	// 发送成功响应
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "密码更新成功"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "密码更新成功",
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
 }
 
-// Logout 处理 POST /api/auth/logout（注意：JWT 令牌是无状态的，这主要是客户端操作）
+// Logout 处理 POST /api/auth/logout，吊销当前访问令牌、当前会话以及请求体中提供的刷新令牌
 func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
-	// 在实际应用中，您可能想要在服务器端将令牌加入黑名单
-	
+	// 从 JWT 声明中获取用户 ID
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	// 请求体是可选的
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 吊销令牌和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "退出登录失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.RevokeAccessToken(tx, claims.Id, claims.UserID, claims.ExpiresAt); err != nil {
+		http.Error(w, "退出登录失败", http.StatusInternalServerError)
+		return
+	}
+	if claims.SessionID != "" {
+		if err := models.RevokeSession(tx, claims.UserID, claims.SessionID); err != nil && err != sql.ErrNoRows {
+			http.Error(w, "退出登录失败", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := models.RevokeRefreshToken(tx, claims.UserID, models.HashToken(req.RefreshToken)); err != nil {
+			http.Error(w, "退出登录失败", http.StatusInternalServerError)
+			return
+		}
+	}
+	entry := newAuditEntry(r, models.AuditLogout, models.AuditResourceSession, claims.SessionID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "退出登录失败") {
+		return
+	}
+
 	// 发送成功响应
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "退出登录成功"})
-} 
\ No newline at end of file
+}
@@ -29,6 +29,19 @@ func (c *ClassController) GetClasses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Teachers only see their own classes
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+	visible := []models.Class{}
+	for _, class := range classes {
+		if scope.allows(class.ID) {
+			visible = append(visible, class)
+		}
+	}
+	classes = visible
+
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(classes)
@@ -43,6 +56,9 @@ func (c *ClassController) GetClassByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid class ID", http.StatusBadRequest)
 		return
 	}
+	if !c.checkClassScope(w, r, id) {
+		return
+	}
 
 	// Get class from database
 	class, err := models.GetClassByID(c.DB, id)
@@ -57,6 +73,7 @@ func (c *ClassController) GetClassByID(w http.ResponseWriter, r *http.Request) {
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(class.RowVersion))
 	json.NewEncoder(w).Encode(class)
 }
 
@@ -69,14 +86,20 @@ func (c *ClassController) CreateClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if class.Name == "" {
-		http.Error(w, "Name is a required field", http.StatusBadRequest)
+	// Validate fields
+	if errs := class.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "Invalid class", errs)
 		return
 	}
 
-	// Create class in database
-	id, err := models.CreateClass(c.DB, &class)
+	// Create the class and its audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to create class")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := models.CreateClass(tx, &class)
 	if err != nil {
 		http.Error(w, "Failed to create class", http.StatusInternalServerError)
 		return
@@ -84,36 +107,27 @@ func (c *ClassController) CreateClass(w http.ResponseWriter, r *http.Request) {
 
 	// Set the class ID and get the full class details
 	class.ID = id
-	createdClass, err := models.GetClassByID(c.DB, id)
+	createdClass, err := models.GetClassByID(tx, id)
 	if err != nil {
-		http.Error(w, "Class created but failed to retrieve details", http.StatusInternalServerError)
+		http.Error(w, "Failed to create class", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditClassCreate, models.AuditResourceClass, id)
+	if !commitAudited(tx, w, r, entry, nil, createdClass, "Failed to create class") {
 		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(createdClass.RowVersion))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdClass)
 }
 
-// UpdateClass handles PUT /api/classes/{id} to update a class
+// UpdateClass handles PUT /api/classes/{id} to replace a class's fields
 func (c *ClassController) UpdateClass(w http.ResponseWriter, r *http.Request) {
-	// Get class ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid class ID", http.StatusBadRequest)
-		return
-	}
-
-	// Check if class exists
-	_, err = models.GetClassByID(c.DB, id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Class not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
-		}
+	existing, version, ok := c.loadClassForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -123,64 +137,138 @@ func (c *ClassController) UpdateClass(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	c.saveClass(w, r, existing, class, version, nil)
+}
 
-	// Set ID to match the URL parameter
-	class.ID = id
+// PatchClass handles PATCH /api/classes/{id} to change only the fields in a JSON Merge Patch (RFC 7396)
+func (c *ClassController) PatchClass(w http.ResponseWriter, r *http.Request) {
+	existing, version, ok := c.loadClassForWrite(w, r)
+	if !ok {
+		return
+	}
 
-	// Update class in database
-	err = models.UpdateClass(c.DB, &class)
-	if err != nil {
-		http.Error(w, "Failed to update class", http.StatusInternalServerError)
+	var class models.Class
+	changed, ok := applyMergePatch(w, r, existing, &class, []string{"name", "description"})
+	if !ok {
 		return
 	}
+	c.saveClass(w, r, existing, class, patchVersion(version, existing.RowVersion), changed)
+}
 
-	// Get updated class
-	updatedClass, err := models.GetClassByID(c.DB, id)
+// DeleteClass handles DELETE /api/classes/{id} to delete a class
+func (c *ClassController) DeleteClass(w http.ResponseWriter, r *http.Request) {
+	existing, version, ok := c.loadClassForWrite(w, r)
+	if !ok {
+		return
+	}
+
+	// Delete the class and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to delete class")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	err := models.DeleteClass(tx, existing.ID, version)
 	if err != nil {
-		http.Error(w, "Class updated but failed to retrieve details", http.StatusInternalServerError)
+		switch err {
+		case sql.ErrNoRows:
+			// Custom error that indicates class has students
+			http.Error(w, "Cannot delete class with associated students", http.StatusConflict)
+		case models.ErrVersionConflict:
+			writeVersionConflict(w)
+		default:
+			http.Error(w, "Failed to delete class", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditClassDelete, models.AuditResourceClass, existing.ID)
+	if !commitAudited(tx, w, r, entry, existing, nil, "Failed to delete class") {
 		return
 	}
 
 	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedClass)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// DeleteClass handles DELETE /api/classes/{id} to delete a class
-func (c *ClassController) DeleteClass(w http.ResponseWriter, r *http.Request) {
+// loadClassForWrite loads the class in the URL for a PUT, PATCH or DELETE, checking it is within
+// the caller's scope and matches If-Match. It returns the class and the row version to write at,
+// and writes an error response and returns false when the write cannot go ahead.
+func (c *ClassController) loadClassForWrite(w http.ResponseWriter, r *http.Request) (models.Class, int64, bool) {
 	// Get class ID from URL
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid class ID", http.StatusBadRequest)
-		return
+		return models.Class{}, 0, false
+	}
+	if !c.checkClassScope(w, r, id) {
+		return models.Class{}, 0, false
 	}
 
 	// Check if class exists
-	_, err = models.GetClassByID(c.DB, id)
+	existing, err := models.GetClassByID(c.DB, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Class not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
 		}
+		return existing, 0, false
+	}
+
+	version, ok := checkIfMatch(w, r, existing.RowVersion)
+	return existing, version, ok
+}
+
+// saveClass writes the new fields of an existing class at the given row version, and responds
+// with the updated class. Like saveStudent, only the changed fields of a PATCH are validated.
+func (c *ClassController) saveClass(w http.ResponseWriter, r *http.Request, existing, class models.Class, version int64, changed []string) {
+	errs := class.Validate()
+	if changed != nil {
+		errs = errs.Only(changed...)
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, "Invalid class", errs)
+		return
+	}
+
+	// Set ID to match the URL parameter
+	class.ID = existing.ID
+	class.RowVersion = version
+
+	// Update the class and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to update class")
+	if !ok {
 		return
 	}
+	defer tx.Rollback()
 
-	// Delete class from database
-	err = models.DeleteClass(c.DB, id)
+	err := models.UpdateClass(tx, &class)
+	if err == models.ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Custom error that indicates class has students
-			http.Error(w, "Cannot delete class with associated students", http.StatusConflict)
-		} else {
-			http.Error(w, "Failed to delete class", http.StatusInternalServerError)
-		}
+		http.Error(w, "Failed to update class", http.StatusInternalServerError)
+		return
+	}
+
+	// Get updated class
+	updatedClass, err := models.GetClassByID(tx, class.ID)
+	if err != nil {
+		http.Error(w, "Failed to update class", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditClassUpdate, models.AuditResourceClass, class.ID)
+	if !commitAudited(tx, w, r, entry, existing, updatedClass, "Failed to update class") {
 		return
 	}
 
 	// Send response
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(updatedClass.RowVersion))
+	json.NewEncoder(w).Encode(updatedClass)
 }
 
 // GetClassStudents handles GET /api/classes/{id}/students to get students in a class
@@ -192,6 +280,9 @@ func (c *ClassController) GetClassStudents(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid class ID", http.StatusBadRequest)
 		return
 	}
+	if !c.checkClassScope(w, r, id) {
+		return
+	}
 
 	// Check if class exists
 	_, err = models.GetClassByID(c.DB, id)
@@ -214,4 +305,18 @@ func (c *ClassController) GetClassStudents(w http.ResponseWriter, r *http.Reques
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(students)
-} 
\ No newline at end of file
+}
+
+// checkClassScope checks that the class is within the caller's class scope,
+// writing an error response and returning false otherwise
+func (c *ClassController) checkClassScope(w http.ResponseWriter, r *http.Request, classID int64) bool {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return false
+	}
+	if !scope.allows(classID) {
+		http.Error(w, "Access to this class is not allowed", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag formats a row version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// checkIfMatch checks the If-Match header of a write against the record's current row version.
+// It returns the version the write has to be made at: the current one when If-Match was sent, so a
+// concurrent change is still caught, and 0 (unconditional) when it was not. When no tag matches it
+// writes 412 with the current ETag and returns false.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int64) (int64, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, true
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match: If-Match uses the strong comparison
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return version, true
+		}
+	}
+	w.Header().Set("ETag", current)
+	http.Error(w, "The record has been changed since it was read, reload it and try again", http.StatusPreconditionFailed)
+	return 0, false
+}
+
+// patchVersion returns the row version a server-side read-modify-write such as PATCH has to be made at.
+// Without If-Match it is the version that was read, so a change made in between is not overwritten
+// with the stale values of the fields that were not patched.
+func patchVersion(ifMatchVersion, readVersion int64) int64 {
+	if ifMatchVersion == 0 {
+		return readVersion
+	}
+	return ifMatchVersion
+}
+
+// writeVersionConflict responds to a write that lost a race with another change after If-Match was checked
+func writeVersionConflict(w http.ResponseWriter) {
+	http.Error(w, "The record was changed by another request, reload it and try again", http.StatusPreconditionFailed)
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-management/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GuardianController handles the guardian endpoints nested under /api/students/{id}/guardians
+type GuardianController struct {
+	DB *sql.DB
+}
+
+// NewGuardianController creates a new GuardianController instance
+func NewGuardianController(db *sql.DB) *GuardianController {
+	return &GuardianController{DB: db}
+}
+
+// GuardianRequest is the request body for adding a guardian to a student.
+// When GuardianID is set, that existing guardian (e.g. a sibling's parent) is linked instead of creating a new one.
+type GuardianRequest struct {
+	GuardianID int64 `json:"guardian_id"`
+	models.Guardian
+}
+
+// GetGuardians handles GET /api/students/{id}/guardians to list a student's guardians
+func (c *GuardianController) GetGuardians(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findStudent(w, r)
+	if !ok {
+		return
+	}
+
+	guardians, err := models.GetStudentGuardians(c.DB, student.ID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve guardians", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guardians)
+}
+
+// GetGuardian handles GET /api/students/{id}/guardians/{guardianId} to retrieve one guardian of a student
+func (c *GuardianController) GetGuardian(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findStudent(w, r)
+	if !ok {
+		return
+	}
+	guardian, ok := c.findGuardian(w, r, student.ID)
+	if !ok {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guardian)
+}
+
+// CreateGuardian handles POST /api/students/{id}/guardians to add a new or existing guardian to a student
+func (c *GuardianController) CreateGuardian(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findStudent(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var req GuardianRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Link or create the guardian and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to create guardian")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	id := req.GuardianID
+	action := models.AuditGuardianCreate
+	if id > 0 {
+		// Link an existing guardian
+		action = models.AuditGuardianLink
+		if _, err := models.GetGuardianByID(c.DB, id); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Guardian not found: "+strconv.FormatInt(id, 10), http.StatusBadRequest)
+			} else {
+				http.Error(w, "Failed to retrieve guardian", http.StatusInternalServerError)
+			}
+			return
+		}
+		// Linking shows the guardian's details and lets a parent account see this student,
+		// so the guardian has to belong only to students the caller may access
+		if !c.checkGuardianScope(w, r, id) {
+			return
+		}
+		if err := models.LinkGuardian(tx, student.ID, id); err != nil {
+			http.Error(w, "Failed to link guardian", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// Create a new guardian
+		guardian := req.Guardian
+		if !validateGuardian(w, &guardian) {
+			return
+		}
+		var err error
+		id, err = models.CreateGuardian(tx, student.ID, &guardian)
+		if err != nil {
+			http.Error(w, "Failed to create guardian", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	createdGuardian, err := models.GetGuardianByID(tx, id)
+	if err != nil {
+		http.Error(w, "Failed to create guardian", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, action, models.AuditResourceGuardian, id)
+	entry.Details = models.AuditJSON(map[string]int64{"student_id": student.ID})
+	if !commitAudited(tx, w, r, entry, nil, createdGuardian, "Failed to create guardian") {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdGuardian)
+}
+
+// UpdateGuardian handles PUT /api/students/{id}/guardians/{guardianId} to update a guardian.
+// Guardians are shared, so the change is visible from every student the guardian is linked to.
+func (c *GuardianController) UpdateGuardian(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findStudent(w, r)
+	if !ok {
+		return
+	}
+	existing, ok := c.findGuardian(w, r, student.ID)
+	if !ok {
+		return
+	}
+	// The change is seen from every linked student, so all of them have to be within the caller's scope
+	if !c.checkGuardianScope(w, r, existing.ID) {
+		return
+	}
+
+	// Parse request body
+	var guardian models.Guardian
+	if err := json.NewDecoder(r.Body).Decode(&guardian); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validateGuardian(w, &guardian) {
+		return
+	}
+
+	// Set ID to match the URL parameter
+	guardian.ID = existing.ID
+
+	// Update the guardian and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to update guardian")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UpdateGuardian(tx, &guardian); err != nil {
+		http.Error(w, "Failed to update guardian", http.StatusInternalServerError)
+		return
+	}
+
+	// Get updated guardian
+	updatedGuardian, err := models.GetGuardianByID(tx, existing.ID)
+	if err != nil {
+		http.Error(w, "Failed to update guardian", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditGuardianUpdate, models.AuditResourceGuardian, existing.ID)
+	if !commitAudited(tx, w, r, entry, existing, updatedGuardian, "Failed to update guardian") {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedGuardian)
+}
+
+// DeleteGuardian handles DELETE /api/students/{id}/guardians/{guardianId} to remove a guardian from a student.
+// The guardian itself is deleted once it is no longer linked to any student.
+func (c *GuardianController) DeleteGuardian(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findStudent(w, r)
+	if !ok {
+		return
+	}
+	existing, ok := c.findGuardian(w, r, student.ID)
+	if !ok {
+		return
+	}
+
+	// Unlink the guardian and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to delete guardian")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UnlinkGuardian(tx, student.ID, existing.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Guardian not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete guardian", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditGuardianUnlink, models.AuditResourceGuardian, existing.ID)
+	entry.Details = models.AuditJSON(map[string]int64{"student_id": student.ID})
+	if !commitAudited(tx, w, r, entry, existing, nil, "Failed to delete guardian") {
+		return
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findStudent loads the student from the URL and checks it is within the caller's class scope,
+// writing an error response and returning false on failure
+func (c *GuardianController) findStudent(w http.ResponseWriter, r *http.Request) (models.Student, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		return models.Student{}, false
+	}
+
+	student, err := models.GetStudentByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		}
+		return models.Student{}, false
+	}
+
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return models.Student{}, false
+	}
+	if !scope.allows(student.ClassID) {
+		http.Error(w, "Access to students of this class is not allowed", http.StatusForbidden)
+		return models.Student{}, false
+	}
+	return student, true
+}
+
+// findGuardian loads the guardian from the URL, which must be linked to the given student,
+// writing an error response and returning false on failure
+func (c *GuardianController) findGuardian(w http.ResponseWriter, r *http.Request, studentID int64) (models.Guardian, bool) {
+	guardianID, err := strconv.ParseInt(mux.Vars(r)["guardianId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid guardian ID", http.StatusBadRequest)
+		return models.Guardian{}, false
+	}
+
+	guardian, err := models.GetStudentGuardian(c.DB, studentID, guardianID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Guardian not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve guardian", http.StatusInternalServerError)
+		}
+		return models.Guardian{}, false
+	}
+	return guardian, true
+}
+
+// checkGuardianScope checks that every student the guardian is linked to is within the caller's
+// class scope, writing an error response and returning false otherwise
+func (c *GuardianController) checkGuardianScope(w http.ResponseWriter, r *http.Request, guardianID int64) bool {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return false
+	}
+	if !scope.restricted {
+		return true
+	}
+	classIDs, err := models.GetGuardianClassIDs(c.DB, guardianID)
+	if err != nil {
+		http.Error(w, "Failed to check access", http.StatusInternalServerError)
+		return false
+	}
+	for _, classID := range classIDs {
+		if !scope.allows(classID) {
+			http.Error(w, "This guardian is also linked to students you cannot access", http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// validateGuardian trims and checks the guardian fields, writing a 422 response
+// with every invalid field and returning false when they are invalid
+func validateGuardian(w http.ResponseWriter, guardian *models.Guardian) bool {
+	guardian.Name = strings.TrimSpace(guardian.Name)
+	guardian.Relationship = strings.TrimSpace(guardian.Relationship)
+	guardian.Phone = strings.TrimSpace(guardian.Phone)
+	guardian.Email = strings.TrimSpace(guardian.Email)
+
+	if errs := guardian.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "Invalid guardian", errs)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ImpersonationResponse 表示开始模拟用户后的响应；模拟令牌不能刷新，过期后需要重新发起
+type ImpersonationResponse struct {
+	Token     string             `json:"token"`
+	ExpiresIn int64              `json:"expires_in"` // 模拟令牌剩余有效秒数
+	User      models.UserProfile `json:"user"`       // 被模拟的用户
+	ActorID   int64              `json:"actor_id"`   // 发起模拟的管理员
+}
+
+// Impersonate 处理 POST /api/users/{id}/impersonate，管理员以指定用户的身份获取一个短期访问令牌，
+// 用于复现该用户看到的内容；模拟期间的每个请求都会写入审计日志
+func (c *AuthController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil || claims.Role != models.RoleAdmin {
+		http.Error(w, "只有管理员可以模拟其他用户", http.StatusForbidden)
+		return
+	}
+	if claims.SessionID == "" {
+		http.Error(w, "当前令牌不属于任何会话，请重新登录", http.StatusConflict)
+		return
+	}
+
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, err := models.GetUserByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "用户不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "获取用户失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	switch {
+	case user.ID == claims.UserID:
+		http.Error(w, "不能模拟自己", http.StatusBadRequest)
+		return
+	case user.Role == models.RoleAdmin:
+		http.Error(w, "不能模拟其他管理员", http.StatusForbidden)
+		return
+	case user.Disabled:
+		http.Error(w, "不能模拟已禁用的账号", http.StatusBadRequest)
+		return
+	}
+
+	token, err := c.newImpersonationToken(user, claims)
+	if err != nil {
+		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
+		return
+	}
+	profile, err := models.GetUserProfile(c.DB, user.ID)
+	if err != nil {
+		http.Error(w, "获取用户资料失败", http.StatusInternalServerError)
+		return
+	}
+
+	actorID, userID := claims.UserID, user.ID
+	entry := models.AuditLog{
+		ActorID:    &actorID,
+		UserID:     &userID,
+		Action:     models.AuditImpersonationStart,
+		Resource:   models.AuditResourceUser,
+		ResourceID: strconv.FormatInt(user.ID, 10),
+		Details:    models.AuditJSON(map[string]interface{}{"username": user.Username, "role": user.Role}),
+		IP:         middleware.ClientIP(r),
+	}
+	if err := models.RecordAudit(c.DB, entry); err != nil {
+		// 无法留下审计记录时不允许模拟
+		log.Error("记录模拟登录失败", "error", err)
+		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
+		return
+	}
+	log.Info("管理员开始模拟用户", "target_user_id", user.ID, "target_username", user.Username)
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ImpersonationResponse{
+		Token:     token,
+		ExpiresIn: int64(c.ImpersonationTTL.Seconds()),
+		User:      profile,
+		ActorID:   claims.UserID,
+	})
+}
+
+// newImpersonationToken 生成以 user 身份访问的 JWT，act 和 act_sid 记录发起模拟的管理员及其会话，
+// 管理员退出登录或被禁用时令牌随之失效
+func (c *AuthController) newImpersonationToken(user models.User, actor *middleware.Claims) (string, error) {
+	tokenID, _, err := models.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := middleware.Claims{
+		UserID:         user.ID,
+		Role:           user.Role,
+		ActorID:        actor.UserID,
+		ActorSessionID: actor.SessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(c.ImpersonationTTL).Unix(),
+		},
+	}
+
+	return middleware.Keys.Sign(claims)
+}
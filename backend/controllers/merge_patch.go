@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// applyMergePatch applies the JSON Merge Patch (RFC 7396) in the request body to the JSON encoding
+// of current and decodes the result into patched. Only the editable fields may be patched.
+// It returns the fields the patch contains, never nil, and writes an error response and returns
+// false when the request is not a valid patch.
+func applyMergePatch(w http.ResponseWriter, r *http.Request, current interface{}, patched interface{}, editable []string) ([]string, bool) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			http.Error(w, "Unsupported content type, expected application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return nil, false
+		}
+	}
+
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		http.Error(w, "Invalid request body, expected a JSON object", http.StatusBadRequest)
+		return nil, false
+	}
+	changed := make([]string, 0, len(patch))
+	var readOnly []string
+	for field := range patch {
+		changed = append(changed, field)
+		if !containsString(editable, field) {
+			readOnly = append(readOnly, field)
+		}
+	}
+	if len(readOnly) > 0 {
+		sort.Strings(readOnly)
+		http.Error(w, "These fields cannot be changed: "+strings.Join(readOnly, ", "), http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Round-trip the current record through JSON so the patch applies to its API representation
+	data, err := json.Marshal(current)
+	if err != nil {
+		http.Error(w, "Failed to apply patch", http.StatusInternalServerError)
+		return nil, false
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		http.Error(w, "Failed to apply patch", http.StatusInternalServerError)
+		return nil, false
+	}
+	if data, err = json.Marshal(mergePatch(doc, patch)); err == nil {
+		err = json.Unmarshal(data, patched)
+	}
+	if err != nil {
+		http.Error(w, "Invalid field type in patch", http.StatusBadRequest)
+		return nil, false
+	}
+	return changed, true
+}
+
+// mergePatch implements the MergePatch function of RFC 7396: members of an object patch replace
+// those of the target, recursively for objects, and null members are removed
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
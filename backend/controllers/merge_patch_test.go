@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		// RFC 7396 appendix A
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// RFC 7396 section 3
+		{
+			`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`,
+			`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`,
+			`{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`,
+		},
+	}
+
+	decode := func(s string) interface{} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatalf("invalid test JSON %s: %v", s, err)
+		}
+		return v
+	}
+	for _, tt := range tests {
+		got := mergePatch(decode(tt.target), decode(tt.patch))
+		if want := decode(tt.want); !reflect.DeepEqual(got, want) {
+			data, _ := json.Marshal(got)
+			t.Errorf("mergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, data, tt.want)
+		}
+	}
+}
+
+type patchRecord struct {
+	Name    string   `json:"name"`
+	Age     int      `json:"age"`
+	Email   *string  `json:"email"`
+	Tags    []string `json:"tags"`
+	Version int      `json:"version"`
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	email := "old@example.com"
+	current := patchRecord{Name: "Alice", Age: 20, Email: &email, Tags: []string{"a", "b"}, Version: 3}
+	editable := []string{"name", "age", "email", "tags"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantChanged []string
+		want        patchRecord
+	}{
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"name":"Bob","tags":["c"]}`,
+			wantStatus:  http.StatusOK,
+			wantChanged: []string{"name", "tags"},
+			want:        patchRecord{Name: "Bob", Age: 20, Email: &email, Tags: []string{"c"}, Version: 3},
+		},
+		{
+			name:        "null clears a field",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"email":null}`,
+			wantStatus:  http.StatusOK,
+			wantChanged: []string{"email"},
+			want:        patchRecord{Name: "Alice", Age: 20, Tags: []string{"a", "b"}, Version: 3},
+		},
+		{
+			name:        "empty patch",
+			body:        `{}`,
+			wantStatus:  http.StatusOK,
+			wantChanged: []string{},
+			want:        current,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        `{"name":"Bob"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "read-only field",
+			body:       `{"name":"Bob","version":4}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong type",
+			body:       `{"age":"twenty"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "null document",
+			body:       `null`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "array document",
+			body:       `[{"name":"Bob"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed JSON",
+			body:       `{"name":`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			var patched patchRecord
+			changed, ok := applyMergePatch(w, r, current, &patched, editable)
+			if ok != (tt.wantStatus == http.StatusOK) || w.Code != tt.wantStatus {
+				t.Fatalf("applyMergePatch = %v with status %d, want status %d", ok, w.Code, tt.wantStatus)
+			}
+			if !ok {
+				return
+			}
+			sort.Strings(changed)
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(patched, tt.want) {
+				t.Errorf("patched = %+v, want %+v", patched, tt.want)
+			}
+		})
+	}
+
+	// The current record is left untouched
+	if current.Name != "Alice" || *current.Email != "old@example.com" || len(current.Tags) != 2 {
+		t.Errorf("current was modified: %+v", current)
+	}
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"student-management/auth"
+	"student-management/logger"
+	"student-management/models"
+)
+
+// oidcStateCookie 把授权请求绑定到发起登录的浏览器，防止登录 CSRF
+const oidcStateCookie = "oidc_state"
+
+// oidcCookiePath 限定 state Cookie 只随单点登录端点发送
+const oidcCookiePath = "/api/auth/oidc"
+
+// OIDCLogin 处理 GET /api/auth/oidc/login，生成 state、nonce 和 PKCE 参数后重定向到身份提供方
+func (c *AuthController) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	if c.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, stateHash, err := models.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "发起单点登录失败", http.StatusInternalServerError)
+		return
+	}
+	nonce, _, err := models.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "发起单点登录失败", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := auth.NewPKCEVerifier()
+	if err != nil {
+		http.Error(w, "发起单点登录失败", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := c.OIDC.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Error("获取身份提供方配置失败", "error", err)
+		http.Error(w, "单点登录服务暂不可用", http.StatusServiceUnavailable)
+		return
+	}
+	if err := models.CreateOIDCState(c.DB, stateHash, models.OIDCState{Nonce: nonce, CodeVerifier: verifier}, c.OIDCStateTTL); err != nil {
+		log.Error("保存单点登录状态失败", "error", err)
+		http.Error(w, "发起单点登录失败", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   int(c.OIDCStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback 处理 GET /api/auth/oidc/callback：校验 state，用授权码换取并验证 ID 令牌，
+// 映射为本地用户后按与 Login 相同的规则签发令牌，结果通过 URL 片段交给前端回调页面
+func (c *AuthController) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	if c.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// state Cookie 只使用一次
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		log.Info("身份提供方拒绝了单点登录", "error", providerError, "description", query.Get("error_description"))
+		c.oidcRedirect(w, r, url.Values{"error": {"单点登录已取消或被拒绝"}})
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+		c.oidcRedirect(w, r, url.Values{"error": {"单点登录请求无效或已过期，请重试"}})
+		return
+	}
+	state, err := models.ConsumeOIDCState(c.DB, models.HashToken(cookie.Value))
+	if err != nil {
+		if err != models.ErrOIDCStateInvalid {
+			log.Error("读取单点登录状态失败", "error", err)
+		}
+		c.oidcRedirect(w, r, url.Values{"error": {"单点登录请求无效或已过期，请重试"}})
+		return
+	}
+
+	// 用授权码和 PKCE 校验码换取 ID 令牌
+	claims, err := c.OIDC.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Warn("单点登录换取令牌失败", "error", err)
+		c.oidcRedirect(w, r, url.Values{"error": {"单点登录失败"}})
+		return
+	}
+
+	user, err := c.OIDC.ProvisionUser(claims)
+	if err != nil {
+		switch {
+		case err == auth.ErrNoRole:
+			log.Warn("单点登录用户没有映射到任何角色", "subject", claims["sub"])
+			c.oidcRedirect(w, r, url.Values{"error": {"该账号没有分配角色，无法登录"}})
+		case err == models.ErrAuthSourceConflict, err == models.ErrUsernameTaken:
+			log.Warn("单点登录用户名已被其他账号使用", "subject", claims["sub"], "error", err)
+			c.oidcRedirect(w, r, url.Values{"error": {"该用户名已被其他账号使用"}})
+		case errors.Is(err, auth.ErrInvalidIDToken):
+			log.Warn("单点登录身份信息无效", "error", err)
+			c.oidcRedirect(w, r, url.Values{"error": {"单点登录失败"}})
+		default:
+			log.Error("同步单点登录用户失败", "error", err)
+			c.oidcRedirect(w, r, url.Values{"error": {"单点登录失败"}})
+		}
+		return
+	}
+
+	// 被禁用的账号不能登录
+	if user.Disabled {
+		log.Info("单点登录失败：账号已被禁用", "username", user.Username)
+		c.oidcRedirect(w, r, url.Values{"error": {"账号已被禁用"}})
+		return
+	}
+
+	// 与 Login 相同：已启用两步验证或角色要求两步验证时，先交给前端完成第二步验证
+	if user.TOTPEnabled || c.requires2FA(user.Role) {
+		token, tokenHash, err := models.NewOpaqueToken()
+		if err == nil {
+			err = models.CreateMFAChallenge(c.DB, user.ID, tokenHash, c.MFAChallengeTTL)
+		}
+		if err != nil {
+			log.Error("创建登录挑战失败", "error", err)
+			c.oidcRedirect(w, r, url.Values{"error": {"单点登录失败"}})
+			return
+		}
+		c.oidcRedirect(w, r, url.Values{
+			"mfa_token":          {token},
+			"mfa_setup_required": {strconv.FormatBool(!user.TOTPEnabled)},
+		})
+		return
+	}
+
+	log.Info("单点登录成功", "username", user.Username, "user_id", user.ID)
+	response, err := c.issueLoginTokens(r, user)
+	if err != nil {
+		log.Error("创建令牌失败", "error", err)
+		c.oidcRedirect(w, r, url.Values{"error": {"创建令牌失败"}})
+		return
+	}
+	c.oidcRedirect(w, r, url.Values{
+		"token":         {response.Token},
+		"refresh_token": {response.RefreshToken},
+		"expires_in":    {strconv.FormatInt(response.ExpiresIn, 10)},
+	})
+}
+
+// oidcRedirect 重定向到前端回调页面；参数放在 URL 片段中，不会发送给服务器或出现在访问日志里
+func (c *AuthController) oidcRedirect(w http.ResponseWriter, r *http.Request, params url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, c.OIDCFrontendURL+"#"+params.Encode(), http.StatusFound)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"student-management/config"
+	"student-management/models"
+	"student-management/validation"
+)
+
+// loadPasswordPolicy 从环境变量读取密码策略
+func loadPasswordPolicy() models.PasswordPolicy {
+	return models.PasswordPolicy{
+		MinLength:   config.GetIntEnv("PASSWORD_MIN_LENGTH", 8),
+		MinClasses:  config.GetIntEnv("PASSWORD_MIN_CLASSES", 2),
+		HistorySize: config.GetIntEnv("PASSWORD_HISTORY", 5),
+	}
+}
+
+// writePasswordError 写入设置密码失败的响应；密码不符合策略或与最近的密码重复时返回 422，
+// 每个问题作为密码字段 field 的一条错误
+func writePasswordError(w http.ResponseWriter, err error, field, message string) {
+	if policyErr, ok := err.(*models.PasswordPolicyError); ok {
+		var errs validation.Errors
+		for _, problem := range policyErr.Problems {
+			errs.Add(field, validation.WeakPassword, problem)
+		}
+		writeValidationErrors(w, "密码不符合要求："+strings.Join(policyErr.Problems, "；"), errs)
+		return
+	}
+	if err == models.ErrPasswordReused {
+		writeValidationErrors(w, "新密码不能与最近使用过的密码相同", validation.Errors{
+			{Field: field, Code: validation.PasswordReused, Message: "新密码不能与最近使用过的密码相同"},
+		})
+		return
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
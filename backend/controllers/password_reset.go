@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"student-management/logger"
+	"student-management/mailer"
+	"student-management/middleware"
+	"student-management/models"
+	"student-management/validation"
+	"time"
+)
+
+// maxEmailLength 是 users.email 的长度，也是重置密码申请记录中邮箱标识的长度上限
+const maxEmailLength = 100
+
+// ForgotPasswordRequest 表示申请重置密码的表单数据
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate 检查邮箱为必填项且格式正确
+func (req ForgotPasswordRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if errs.Required("email", req.Email, "邮箱为必填项") &&
+		errs.MaxLength("email", req.Email, maxEmailLength, fmt.Sprintf("邮箱不能超过 %d 个字符", maxEmailLength)) {
+		errs.Email("email", req.Email, "邮箱格式不正确")
+	}
+	return errs
+}
+
+// ResetPasswordRequest 表示使用重置令牌设置新密码的表单数据
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Validate 检查重置密码表单的必填项；新密码是否符合密码策略在设置时检查
+func (req ResetPasswordRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if req.Token == "" {
+		errs.Add("token", validation.Required, "重置令牌为必填项")
+	}
+	if req.NewPassword == "" {
+		errs.Add("new_password", validation.Required, "新密码为必填项")
+	}
+	return errs
+}
+
+// ForgotPassword 处理 POST /api/auth/forgot-password，向该邮箱对应的账号发送一次性重置链接。
+// 无论邮箱是否存在都返回相同的响应，避免泄露账号信息。
+func (c *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+	if !c.checkForgotThrottle(w, r, req.Email) {
+		return
+	}
+
+	users, err := models.GetUsersByEmail(c.DB, req.Email)
+	if err != nil {
+		http.Error(w, "申请重置密码失败", http.StatusInternalServerError)
+		return
+	}
+
+	log := logger.FromContext(r.Context())
+	for _, user := range users {
+		// 目录账号的密码不能在本系统中重置
+		if user.Disabled || user.AuthSource != models.AuthSourceLocal {
+			continue
+		}
+
+		token, tokenHash, err := models.NewOpaqueToken()
+		if err != nil {
+			http.Error(w, "申请重置密码失败", http.StatusInternalServerError)
+			return
+		}
+		if err := models.CreatePasswordResetToken(c.DB, user.ID, tokenHash, c.PasswordResetTTL); err != nil {
+			http.Error(w, "申请重置密码失败", http.StatusInternalServerError)
+			return
+		}
+
+		// 异步发送，避免响应时间暴露邮箱是否存在
+		msg := mailer.Message{
+			To:      user.Email,
+			Subject: "重置您的密码",
+			Body: fmt.Sprintf("%s，您好：\n\n请在 %d 分钟内打开以下链接重置密码：\n%s?token=%s\n\n如果这不是您本人的操作，请忽略此邮件。",
+				user.Username, int(c.PasswordResetTTL.Minutes()), c.PasswordResetURL, token),
+		}
+		go func(username string) {
+			if err := c.Mailer.Send(msg); err != nil {
+				log.Error("发送密码重置邮件失败", "username", username, "error", err)
+			}
+		}(user.Username)
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "如果该邮箱已注册，您将收到一封重置密码的邮件"})
+}
+
+// checkForgotThrottle 按邮箱和 IP 限制申请重置密码的次数，复用登录失败记录：每次申请都计数，
+// 在窗口期内达到上限后返回 429。无论邮箱是否存在都同样计数，响应不会暴露账号信息
+func (c *AuthController) checkForgotThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	log := logger.FromContext(r.Context())
+	now := time.Now()
+	limits := []struct {
+		scope, identifier string
+		policy            models.LoginPolicy
+	}{
+		{models.LoginScopeForgot, strings.ToLower(email), c.ForgotPolicy},
+		{models.LoginScopeForgotIP, middleware.ClientIP(r), c.ForgotIPPolicy},
+	}
+
+	for _, limit := range limits {
+		record, err := models.GetLoginFailure(c.DB, limit.scope, limit.identifier)
+		if err != nil {
+			log.Error("查询重置密码申请记录失败", "error", err)
+			http.Error(w, "申请重置密码失败", http.StatusInternalServerError)
+			return false
+		}
+		if wait, _ := record.Check(limit.policy, now); wait > 0 {
+			log.Warn("重置密码申请过于频繁", "scope", limit.scope)
+			writeTooManyRequests(w, wait, "重置密码申请过于频繁，请稍后再试")
+			return false
+		}
+	}
+	for _, limit := range limits {
+		if _, err := models.RecordLoginFailure(c.DB, limit.scope, limit.identifier, limit.policy); err != nil {
+			log.Error("记录重置密码申请失败", "error", err)
+		}
+	}
+	return true
+}
+
+// ResetPassword 处理 POST /api/auth/reset-password，使用一次性令牌设置新密码并吊销该用户的全部会话
+func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+
+	// 重置密码和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "重置密码失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	userID, err := models.ResetPasswordWithToken(tx, models.HashToken(req.Token), req.NewPassword, c.PasswordPolicy)
+	if err != nil {
+		if err == models.ErrResetTokenInvalid {
+			http.Error(w, "重置链接无效或已过期", http.StatusBadRequest)
+		} else {
+			writePasswordError(w, err, "new_password", "重置密码失败")
+		}
+		return
+	}
+	entry := newUserAuditEntry(r, userID, models.AuditPasswordReset, models.AuditResourceUser, userID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "重置密码失败") {
+		return
+	}
+
+	// 重置成功后解除因登录失败导致的锁定
+	if user, err := models.GetUserByID(c.DB, userID); err == nil {
+		if err := models.ClearLoginFailures(c.DB, models.LoginScopeUser, user.Username); err != nil {
+			logger.FromContext(r.Context()).Error("清除登录失败记录失败", "error", err)
+		}
+	}
+
+	// 发送成功响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "密码已重置，请使用新密码登录"})
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"student-management/models"
+
+	"github.com/gorilla/mux"
+)
+
+// RoleController 处理角色和权限定义相关的端点
+type RoleController struct {
+	DB *sql.DB
+}
+
+// NewRoleController 创建新的 RoleController
+func NewRoleController(db *sql.DB) *RoleController {
+	return &RoleController{DB: db}
+}
+
+// RoleRequest 表示创建或更新角色的表单数据
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// GetPermissions 处理 GET /api/permissions 获取系统支持的全部权限
+func (c *RoleController) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Permissions)
+}
+
+// GetRoles 处理 GET /api/roles 获取所有角色
+func (c *RoleController) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := models.GetAllRoles(c.DB)
+	if err != nil {
+		http.Error(w, "获取角色列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// GetRole 处理 GET /api/roles/{name} 获取指定角色
+func (c *RoleController) GetRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := c.findRole(w, mux.Vars(r)["name"])
+	if !ok {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// CreateRole 处理 POST /api/roles 创建新角色
+func (c *RoleController) CreateRole(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 验证字段
+	if !models.IsValidRoleName(req.Name) {
+		http.Error(w, "角色名只能包含小写字母、数字和下划线，且以字母开头，最多 20 个字符", http.StatusBadRequest)
+		return
+	}
+	if !validPermissions(w, req.Permissions) {
+		return
+	}
+
+	exists, err := models.RoleExists(c.DB, req.Name)
+	if err != nil {
+		http.Error(w, "创建角色失败", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "角色已存在", http.StatusConflict)
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "创建角色失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	role := models.Role{Name: req.Name, Description: req.Description, Permissions: req.Permissions}
+	if err := models.CreateRole(tx, &role); err != nil {
+		http.Error(w, "创建角色失败", http.StatusInternalServerError)
+		return
+	}
+	createdRole, err := models.GetRole(tx, req.Name)
+	if err != nil {
+		http.Error(w, "创建角色失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditRoleCreate, models.AuditResourceRole, req.Name)
+	if !commitAudited(tx, w, r, entry, nil, createdRole, "创建角色失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdRole)
+}
+
+// UpdateRole 处理 PUT /api/roles/{name} 更新角色的描述和权限
+func (c *RoleController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	existing, ok := c.findRole(w, name)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	if !validPermissions(w, req.Permissions) {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "更新角色失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	role := models.Role{Name: name, Description: req.Description, Permissions: req.Permissions}
+	if err := models.UpdateRole(tx, &role); err != nil {
+		if err == models.ErrRoleImmutable {
+			http.Error(w, "管理员、学生和家长角色的权限是固定的，不能修改", http.StatusConflict)
+		} else {
+			http.Error(w, "更新角色失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	updatedRole, err := models.GetRole(tx, name)
+	if err != nil {
+		http.Error(w, "更新角色失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditRoleUpdate, models.AuditResourceRole, name)
+	if !commitAudited(tx, w, r, entry, existing, updatedRole, "更新角色失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedRole)
+}
+
+// DeleteRole 处理 DELETE /api/roles/{name} 删除自定义角色
+func (c *RoleController) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	existing, ok := c.findRole(w, name)
+	if !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "删除角色失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.DeleteRole(tx, name); err != nil {
+		switch err {
+		case models.ErrRoleBuiltin:
+			http.Error(w, "内置角色不能删除", http.StatusConflict)
+		case models.ErrRoleInUse:
+			http.Error(w, "仍有用户使用该角色，不能删除", http.StatusConflict)
+		default:
+			http.Error(w, "删除角色失败", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditRoleDelete, models.AuditResourceRole, name)
+	if !commitAudited(tx, w, r, entry, existing, nil, "删除角色失败") {
+		return
+	}
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findRole 获取指定角色，失败时写入错误响应并返回 false
+func (c *RoleController) findRole(w http.ResponseWriter, name string) (models.Role, bool) {
+	role, err := models.GetRole(c.DB, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "角色不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "获取角色失败", http.StatusInternalServerError)
+		}
+		return role, false
+	}
+	return role, true
+}
+
+// validPermissions 检查权限列表是否都由系统定义，否则写入错误响应并返回 false
+func validPermissions(w http.ResponseWriter, permissions []string) bool {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			http.Error(w, "无效的权限: "+p, http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
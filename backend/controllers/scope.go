@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"student-management/middleware"
+	"student-management/models"
+)
+
+// classScope describes which classes the caller may access.
+// Teachers are restricted to the classes assigned to them, and students and parents to none at all
+// (they only reach their own records through /api/me); other roles are not restricted.
+type classScope struct {
+	restricted bool
+	classIDs   map[int64]bool
+}
+
+// loadClassScope loads the class scope of the authenticated caller
+func loadClassScope(db *sql.DB, r *http.Request) (classScope, error) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if ok && claims != nil && models.IsPortalRole(claims.Role) {
+		return classScope{restricted: true, classIDs: map[int64]bool{}}, nil
+	}
+	if !ok || claims == nil || claims.Role != models.RoleTeacher {
+		return classScope{}, nil
+	}
+
+	ids, err := models.GetTeacherClassIDs(db, claims.UserID)
+	if err != nil {
+		return classScope{}, err
+	}
+	scope := classScope{restricted: true, classIDs: map[int64]bool{}}
+	for _, id := range ids {
+		scope.classIDs[id] = true
+	}
+	return scope, nil
+}
+
+// allows reports whether the caller may access the given class
+func (s classScope) allows(classID int64) bool {
+	return !s.restricted || s.classIDs[classID]
+}
+
+// ids returns the accessible class IDs, or nil when the caller is not restricted
+func (s classScope) ids() []int64 {
+	if !s.restricted {
+		return nil
+	}
+	ids := make([]int64, 0, len(s.classIDs))
+	for id := range s.classIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// requireClassScope loads the caller's class scope, writing an error response and returning false on failure
+func requireClassScope(db *sql.DB, w http.ResponseWriter, r *http.Request) (classScope, bool) {
+	scope, err := loadClassScope(db, r)
+	if err != nil {
+		http.Error(w, "Failed to check access", http.StatusInternalServerError)
+		return scope, false
+	}
+	return scope, true
+}
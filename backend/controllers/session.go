@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSessions 处理 GET /api/auth/sessions 获取当前用户的有效会话（登录设备）
+func (c *AuthController) GetSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := models.GetUserSessions(c.DB, claims.UserID)
+	if err != nil {
+		http.Error(w, "获取会话列表失败", http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession 处理 DELETE /api/auth/sessions/{id} 注销当前用户的一个会话；注销当前会话等同于退出登录
+func (c *AuthController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := mux.Vars(r)["id"]
+
+	// 注销会话和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "注销会话失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.RevokeSession(tx, claims.UserID, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "会话不存在或已失效", http.StatusNotFound)
+		} else {
+			http.Error(w, "注销会话失败", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditSessionRevoke, models.AuditResourceSession, sessionID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "注销会话失败") {
+		return
+	}
+	logger.FromContext(r.Context()).Info("会话已注销", "revoked_session_id", sessionID)
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions 处理 POST /api/auth/sessions/revoke-others 注销当前用户除当前会话以外的全部会话
+func (c *AuthController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+	if claims.SessionID == "" {
+		http.Error(w, "当前令牌不属于任何会话，请重新登录", http.StatusConflict)
+		return
+	}
+
+	// 注销会话和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "注销会话失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	revoked, err := models.RevokeOtherSessions(tx, claims.UserID, claims.SessionID)
+	if err != nil {
+		http.Error(w, "注销会话失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditSessionRevokeOthers, models.AuditResourceSession, claims.SessionID)
+	entry.Details = models.AuditJSON(map[string]int64{"revoked": revoked})
+	if !commitAudited(tx, w, r, entry, nil, nil, "注销会话失败") {
+		return
+	}
+	logger.FromContext(r.Context()).Info("已注销其他会话", "revoked", revoked)
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": revoked})
+}
@@ -0,0 +1,465 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+	"unicode/utf8"
+)
+
+const (
+	maxBulkItems = 1000 // Largest number of students in one bulk operation
+	maxBulkTags  = 20   // Largest number of tags added or removed at once
+	maxTagLength = 50   // student_tags.tag is VARCHAR(50)
+)
+
+// Bulk actions
+const (
+	bulkUpdate = "update"
+	bulkDelete = "delete"
+	bulkTag    = "tag"
+	bulkUntag  = "untag"
+)
+
+// Per-student statuses in a bulk report
+const (
+	bulkStatusUpdated   = "updated"
+	bulkStatusDeleted   = "deleted"
+	bulkStatusTagged    = "tagged"
+	bulkStatusUntagged  = "untagged"
+	bulkStatusUnchanged = "unchanged" // Nothing to do, e.g. the student already has the values
+	bulkStatusSkipped   = "skipped"   // Valid, but not applied because other students failed
+	bulkStatusError     = "error"
+)
+
+// BulkStudentRequest is the body of POST /api/students/bulk. The students are given either as
+// IDs or as a filter with the same fields as the student list.
+type BulkStudentRequest struct {
+	Action string                     `json:"action"` // update, delete, tag or untag
+	IDs    []int64                    `json:"ids,omitempty"`
+	Filter *BulkStudentFilter         `json:"filter,omitempty"`
+	Fields map[string]json.RawMessage `json:"fields,omitempty"` // update: class_id, email, phone or address
+	Tags   []string                   `json:"tags,omitempty"`   // tag and untag
+	DryRun bool                       `json:"dry_run"`
+}
+
+// BulkStudentFilter selects the students of a bulk operation; at least one field must be set
+type BulkStudentFilter struct {
+	ClassID   int64  `json:"class_id"`
+	StudentID string `json:"student_id"` // partial match
+	Name      string `json:"name"`       // partial match
+	Tag       string `json:"tag"`
+}
+
+// BulkItemResult is the outcome of a bulk operation for one student
+type BulkItemResult struct {
+	ID      int64  `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// BulkReport is the result of a bulk operation
+type BulkReport struct {
+	Action    string           `json:"action"`
+	DryRun    bool             `json:"dry_run"`
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"` // Students changed, or that would be changed in a dry run
+	Unchanged int              `json:"unchanged"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// BulkStudents handles POST /api/students/bulk to update, delete, tag or untag many students at once.
+// Every student is checked first; the changes are then made in one transaction, and only when no
+// student failed. Otherwise nothing is changed and the report comes back with 422.
+func (c *StudentController) BulkStudents(w http.ResponseWriter, r *http.Request) {
+	var req BulkStudentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Check the action and its parameters
+	var fields models.BulkStudentFields
+	switch req.Action {
+	case bulkUpdate:
+		var ok bool
+		if fields, ok = c.parseBulkFields(w, r, req.Fields); !ok {
+			return
+		}
+	case bulkDelete:
+		// Deleting needs students:delete on top of the students:write the route requires
+		allowed, err := middleware.HasPermission(c.DB, r, models.PermStudentsDelete)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+	case bulkTag, bulkUntag:
+		var ok bool
+		if req.Tags, ok = parseBulkTags(w, req.Tags); !ok {
+			return
+		}
+	default:
+		http.Error(w, "Invalid action, expected update, delete, tag or untag", http.StatusBadRequest)
+		return
+	}
+
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+	ids, ok := c.resolveBulkIDs(w, req, scope)
+	if !ok {
+		return
+	}
+	students, err := models.GetStudentsByIDs(c.DB, ids)
+	if err != nil {
+		http.Error(w, "Failed to retrieve students", http.StatusInternalServerError)
+		return
+	}
+
+	// Work out what happens to each student
+	report := BulkReport{Action: req.Action, DryRun: req.DryRun, Total: len(ids), Results: make([]BulkItemResult, len(ids))}
+	var changed []int64
+	for i, id := range ids {
+		result := BulkItemResult{ID: id}
+		student, found := students[id]
+		switch {
+		case !found:
+			result.Status, result.Message = bulkStatusError, "Student not found"
+		case !scope.allows(student.ClassID):
+			result.Status, result.Message = bulkStatusError, "Access to this student is not allowed"
+		case !bulkChanges(req, fields, student):
+			result.Status = bulkStatusUnchanged
+		default:
+			result.Status = map[string]string{
+				bulkUpdate: bulkStatusUpdated,
+				bulkDelete: bulkStatusDeleted,
+				bulkTag:    bulkStatusTagged,
+				bulkUntag:  bulkStatusUntagged,
+			}[req.Action]
+			changed = append(changed, id)
+		}
+		report.Results[i] = result
+	}
+	for _, result := range report.Results {
+		switch result.Status {
+		case bulkStatusError:
+			report.Failed++
+		case bulkStatusUnchanged:
+			report.Unchanged++
+		default:
+			report.Succeeded++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Failed > 0 {
+		// All or nothing: the students that could have been changed are reported as skipped
+		report.Succeeded = 0
+		for i := range report.Results {
+			if report.Results[i].Status != bulkStatusError && report.Results[i].Status != bulkStatusUnchanged {
+				report.Results[i].Status = bulkStatusSkipped
+			}
+		}
+		if !req.DryRun {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if req.DryRun || len(changed) == 0 {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	// Apply the changes and their audit entries in one transaction
+	tx, err := c.DB.Begin()
+	if err != nil {
+		w.Header().Del("Content-Type")
+		http.Error(w, "Failed to apply bulk operation, nothing was changed", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Check the students again with their rows locked: another request may have moved one out of
+	// the caller's scope or deleted it since the checks above
+	locked, err := models.LockStudentsByIDs(tx, changed)
+	if err != nil {
+		w.Header().Del("Content-Type")
+		http.Error(w, "Failed to apply bulk operation, nothing was changed", http.StatusInternalServerError)
+		return
+	}
+	for _, id := range changed {
+		if student, found := locked[id]; !found || !scope.allows(student.ClassID) {
+			writeBulkConflict(w, id)
+			return
+		}
+	}
+
+	switch req.Action {
+	case bulkUpdate:
+		err = models.BulkUpdateStudents(tx, changed, fields)
+	case bulkDelete:
+		err = models.BulkDeleteStudents(tx, changed)
+	case bulkTag:
+		err = models.AddStudentTags(tx, changed, req.Tags)
+	case bulkUntag:
+		err = models.RemoveStudentTags(tx, changed, req.Tags)
+	}
+	var gone *models.StudentGoneError
+	if errors.As(err, &gone) {
+		writeBulkConflict(w, gone.ID)
+		return
+	}
+	if err == nil {
+		err = c.auditBulk(tx, r, req, fields, changed, locked)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("bulk operation failed", "action", req.Action, "error", err)
+		w.Header().Del("Content-Type")
+		http.Error(w, "Failed to apply bulk operation, nothing was changed", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeBulkConflict responds to a bulk operation that found a student changed by another request
+// while it was being applied
+func writeBulkConflict(w http.ResponseWriter, id int64) {
+	w.Header().Del("Content-Type")
+	http.Error(w, fmt.Sprintf("Student %d was changed by another request, nothing was changed", id), http.StatusConflict)
+}
+
+// auditBulk records an audit entry for each changed student like a single change, in the
+// transaction of the bulk operation
+func (c *StudentController) auditBulk(tx *sql.Tx, r *http.Request, req BulkStudentRequest, fields models.BulkStudentFields, changed []int64, students map[int64]models.Student) error {
+	for _, id := range changed {
+		before := students[id]
+		var entry models.AuditLog
+		var after interface{}
+		switch req.Action {
+		case bulkUpdate:
+			entry = newAuditEntry(r, models.AuditStudentUpdate, models.AuditResourceStudent, id)
+			entry.Details = models.AuditJSON(map[string]interface{}{"bulk": true})
+			after = fields.Apply(before)
+		case bulkDelete:
+			entry = newAuditEntry(r, models.AuditStudentDelete, models.AuditResourceStudent, id)
+			entry.Details = models.AuditJSON(map[string]interface{}{"bulk": true})
+		case bulkTag, bulkUntag:
+			action := models.AuditStudentTag
+			if req.Action == bulkUntag {
+				action = models.AuditStudentUntag
+			}
+			entry = newAuditEntry(r, action, models.AuditResourceStudent, id)
+			entry.Details = models.AuditJSON(map[string]interface{}{"bulk": true, "tags": req.Tags})
+			tagged := before
+			tagged.Tags = applyTags(req.Action, before.Tags, req.Tags)
+			after = tagged
+		}
+		if err := recordAudit(tx, entry, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveBulkIDs returns the IDs of the students in a bulk request, without duplicates. Filters only
+// match students in the caller's scope. It writes an error response and returns false when the
+// request names no students or too many.
+func (c *StudentController) resolveBulkIDs(w http.ResponseWriter, req BulkStudentRequest, scope classScope) ([]int64, bool) {
+	var ids []int64
+	switch {
+	case len(req.IDs) > 0 && req.Filter != nil:
+		http.Error(w, "Give either ids or filter, not both", http.StatusBadRequest)
+		return nil, false
+	case len(req.IDs) > 0:
+		seen := map[int64]bool{}
+		for _, id := range req.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	case req.Filter != nil:
+		f := req.Filter
+		if f.ClassID == 0 && f.StudentID == "" && f.Name == "" && f.Tag == "" {
+			http.Error(w, "The filter must have at least one of class_id, student_id, name or tag", http.StatusBadRequest)
+			return nil, false
+		}
+		filter := models.StudentFilter{ClassID: f.ClassID, StudentID: f.StudentID, Name: f.Name, Tag: f.Tag, ClassIDs: scope.ids()}
+		var err error
+		if ids, err = models.GetStudentIDs(c.DB, filter, maxBulkItems+1); err != nil {
+			http.Error(w, "Failed to retrieve students", http.StatusInternalServerError)
+			return nil, false
+		}
+		if len(ids) == 0 {
+			http.Error(w, "No students match the filter", http.StatusBadRequest)
+			return nil, false
+		}
+	default:
+		http.Error(w, "Missing ids or filter", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if len(ids) > maxBulkItems {
+		http.Error(w, fmt.Sprintf("Too many students, at most %d can be changed at once", maxBulkItems), http.StatusBadRequest)
+		return nil, false
+	}
+	return ids, true
+}
+
+// parseBulkFields parses the fields of a bulk update. The values are checked by the same rules as
+// a single student, with every invalid field listed in a 422 response, and the target class must
+// exist and be in the caller's scope. It writes an error response and returns false otherwise.
+func (c *StudentController) parseBulkFields(w http.ResponseWriter, r *http.Request, raw map[string]json.RawMessage) (models.BulkStudentFields, bool) {
+	var fields models.BulkStudentFields
+	if len(raw) == 0 {
+		http.Error(w, "Missing fields to update", http.StatusBadRequest)
+		return fields, false
+	}
+
+	changed := make([]string, 0, len(raw))
+	for name, value := range raw {
+		changed = append(changed, name)
+		if name == "class_id" {
+			var classID int64
+			if err := json.Unmarshal(value, &classID); err != nil {
+				http.Error(w, "Invalid class_id, expected a number", http.StatusBadRequest)
+				return fields, false
+			}
+			fields.ClassID = &classID
+			continue
+		}
+
+		var target **string
+		switch name {
+		case "email":
+			target = &fields.Email
+		case "phone":
+			target = &fields.Phone
+		case "address":
+			target = &fields.Address
+		default:
+			http.Error(w, "Field cannot be updated in bulk: "+name, http.StatusBadRequest)
+			return fields, false
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s, expected a string", name), http.StatusBadRequest)
+			return fields, false
+		}
+		s = strings.TrimSpace(s)
+		*target = &s
+	}
+
+	// The values are set on an otherwise empty student, and only the fields given are validated
+	student := fields.Apply(models.Student{})
+
+	errs, err := models.ValidateStudentReferences(c.DB, student, student.Validate().Only(changed...), changed...)
+	if err != nil {
+		http.Error(w, "Failed to validate fields", http.StatusInternalServerError)
+		return fields, false
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, "Invalid fields", errs)
+		return fields, false
+	}
+
+	// Teachers can only move students into their own classes
+	if fields.ClassID != nil && !c.checkStudentScope(w, r, *fields.ClassID) {
+		return fields, false
+	}
+	return fields, true
+}
+
+// parseBulkTags trims and deduplicates the tags of a tag or untag request. It writes an error
+// response and returns false when a tag is invalid.
+func parseBulkTags(w http.ResponseWriter, tags []string) ([]string, bool) {
+	var result []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+			http.Error(w, "Tags cannot be empty", http.StatusBadRequest)
+			return nil, false
+		case utf8.RuneCountInString(tag) > maxTagLength:
+			http.Error(w, fmt.Sprintf("Tags cannot be longer than %d characters", maxTagLength), http.StatusBadRequest)
+			return nil, false
+		case strings.Contains(tag, ","):
+			http.Error(w, "Tags cannot contain commas", http.StatusBadRequest)
+			return nil, false
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	if len(result) == 0 {
+		http.Error(w, "Missing tags", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(result) > maxBulkTags {
+		http.Error(w, fmt.Sprintf("Too many tags, at most %d at once", maxBulkTags), http.StatusBadRequest)
+		return nil, false
+	}
+	return result, true
+}
+
+// bulkChanges reports whether a bulk request changes the student
+func bulkChanges(req BulkStudentRequest, fields models.BulkStudentFields, student models.Student) bool {
+	switch req.Action {
+	case bulkUpdate:
+		after := fields.Apply(student)
+		return after.ClassID != student.ClassID || after.Email != student.Email ||
+			after.Phone != student.Phone || after.Address != student.Address
+	case bulkTag, bulkUntag:
+		has := map[string]bool{}
+		for _, t := range student.Tags {
+			has[t] = true
+		}
+		for _, t := range req.Tags {
+			// Tagging changes students missing a tag, untagging those having one
+			if has[t] != (req.Action == bulkTag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// applyTags returns the sorted tags of a student after tagging or untagging
+func applyTags(action string, current, tags []string) []string {
+	set := map[string]bool{}
+	for _, t := range current {
+		set[t] = true
+	}
+	for _, t := range tags {
+		set[t] = action == bulkTag
+	}
+	var result []string
+	for t, ok := range set {
+		if ok {
+			result = append(result, t)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
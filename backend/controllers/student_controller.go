@@ -26,6 +26,7 @@ func (c *StudentController) GetStudents(w http.ResponseWriter, r *http.Request)
 	classIDStr := r.URL.Query().Get("class_id")
 	studentID := r.URL.Query().Get("student_id")
 	name := r.URL.Query().Get("name")
+	tag := r.URL.Query().Get("tag")
 	pageStr := r.URL.Query().Get("page")
 	pageSizeStr := r.URL.Query().Get("page_size")
 
@@ -62,8 +63,15 @@ func (c *StudentController) GetStudents(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	// Teachers only see students in their own classes
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+
 	// Get students from the database
-	students, total, err := models.GetAllStudents(c.DB, classID, studentID, name, page, pageSize)
+	filter := models.StudentFilter{ClassID: classID, StudentID: studentID, Name: name, Tag: tag, ClassIDs: scope.ids()}
+	students, total, err := models.GetAllStudents(c.DB, filter, page, pageSize)
 	if err != nil {
 		http.Error(w, "Failed to retrieve students", http.StatusInternalServerError)
 		return
@@ -84,8 +92,14 @@ func (c *StudentController) GetStudents(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetStudentByID handles GET /api/students/{id} to retrieve a specific student
+// GetStudentByID handles GET /api/students/{id} to retrieve a specific student,
+// or with ?as_of= the student as it was at that time
 func (c *StudentController) GetStudentByID(w http.ResponseWriter, r *http.Request) {
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		c.getStudentAsOf(w, r, asOf)
+		return
+	}
+
 	// Get student ID from URL
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -105,8 +119,14 @@ func (c *StudentController) GetStudentByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Check the student's class is within the caller's scope
+	if !c.checkStudentScope(w, r, student.ClassID) {
+		return
+	}
+
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(student.RowVersion))
 	json.NewEncoder(w).Encode(student)
 }
 
@@ -119,14 +139,28 @@ func (c *StudentController) CreateStudent(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Validate required fields
-	if student.Name == "" || student.StudentID == "" {
-		http.Error(w, "Name and StudentID are required fields", http.StatusBadRequest)
+	// Validate fields
+	if !c.validateStudent(w, student, nil) {
+		return
+	}
+
+	// Teachers can only add students to their own classes
+	if !c.checkStudentScope(w, r, student.ClassID) {
 		return
 	}
 
-	// Create student in database
-	id, err := models.CreateStudent(c.DB, &student)
+	// Create the student and its audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to create student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := models.CreateStudent(tx, &student)
+	if err == models.ErrStudentNumberTaken {
+		writeStudentNumberTaken(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to create student", http.StatusInternalServerError)
 		return
@@ -134,36 +168,27 @@ func (c *StudentController) CreateStudent(w http.ResponseWriter, r *http.Request
 
 	// Set the student ID and get the full student details
 	student.ID = id
-	createdStudent, err := models.GetStudentByID(c.DB, id)
+	createdStudent, err := models.GetStudentByID(tx, id)
 	if err != nil {
-		http.Error(w, "Student created but failed to retrieve details", http.StatusInternalServerError)
+		http.Error(w, "Failed to create student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentCreate, models.AuditResourceStudent, id)
+	if !commitAudited(tx, w, r, entry, nil, createdStudent, "Failed to create student") {
 		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(createdStudent.RowVersion))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdStudent)
 }
 
-// UpdateStudent handles PUT /api/students/{id} to update a student
+// UpdateStudent handles PUT /api/students/{id} to replace a student's fields
 func (c *StudentController) UpdateStudent(w http.ResponseWriter, r *http.Request) {
-	// Get student ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid student ID", http.StatusBadRequest)
-		return
-	}
-
-	// Check if student exists
-	_, err = models.GetStudentByID(c.DB, id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Student not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
-		}
+	existing, version, ok := c.loadStudentForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -173,57 +198,152 @@ func (c *StudentController) UpdateStudent(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	c.saveStudent(w, r, existing, student, version, nil)
+}
 
-	// Set ID to match the URL parameter
-	student.ID = id
+// PatchStudent handles PATCH /api/students/{id} to change only the fields in a JSON Merge Patch
+// (RFC 7396), e.g. {"phone": "13800000000", "email": null} to set the phone and clear the email
+func (c *StudentController) PatchStudent(w http.ResponseWriter, r *http.Request) {
+	existing, version, ok := c.loadStudentForWrite(w, r)
+	if !ok {
+		return
+	}
 
-	// Update student in database
-	err = models.UpdateStudent(c.DB, &student)
-	if err != nil {
-		http.Error(w, "Failed to update student", http.StatusInternalServerError)
+	var student models.Student
+	editable := []string{"student_id", "name", "class_id", "email", "phone", "address"}
+	changed, ok := applyMergePatch(w, r, existing, &student, editable)
+	if !ok {
 		return
 	}
+	c.saveStudent(w, r, existing, student, patchVersion(version, existing.RowVersion), changed)
+}
 
-	// Get updated student
-	updatedStudent, err := models.GetStudentByID(c.DB, id)
+// DeleteStudent handles DELETE /api/students/{id} to delete a student
+func (c *StudentController) DeleteStudent(w http.ResponseWriter, r *http.Request) {
+	existing, version, ok := c.loadStudentForWrite(w, r)
+	if !ok {
+		return
+	}
+
+	// Delete the student and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to delete student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	err := models.DeleteStudent(tx, existing.ID, version)
+	if err == models.ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Student updated but failed to retrieve details", http.StatusInternalServerError)
+		http.Error(w, "Failed to delete student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentDelete, models.AuditResourceStudent, existing.ID)
+	if !commitAudited(tx, w, r, entry, existing, nil, "Failed to delete student") {
 		return
 	}
 
 	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedStudent)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// DeleteStudent handles DELETE /api/students/{id} to delete a student
-func (c *StudentController) DeleteStudent(w http.ResponseWriter, r *http.Request) {
+// loadStudentForWrite loads the student in the URL for a PUT, PATCH or DELETE, checking it is within
+// the caller's scope and matches If-Match. It returns the student and the row version to write at,
+// and writes an error response and returns false when the write cannot go ahead.
+func (c *StudentController) loadStudentForWrite(w http.ResponseWriter, r *http.Request) (models.Student, int64, bool) {
 	// Get student ID from URL
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid student ID", http.StatusBadRequest)
-		return
+		return models.Student{}, 0, false
 	}
 
 	// Check if student exists
-	_, err = models.GetStudentByID(c.DB, id)
+	existing, err := models.GetStudentByID(c.DB, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Student not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
 		}
+		return existing, 0, false
+	}
+	if !c.checkStudentScope(w, r, existing.ClassID) {
+		return existing, 0, false
+	}
+
+	version, ok := checkIfMatch(w, r, existing.RowVersion)
+	return existing, version, ok
+}
+
+// saveStudent writes the new fields of an existing student at the given row version, and responds
+// with the updated student. changed lists the fields of a PATCH, which are the only ones validated;
+// it is nil for a PUT, which validates every field.
+func (c *StudentController) saveStudent(w http.ResponseWriter, r *http.Request, existing, student models.Student, version int64, changed []string) {
+	// Set ID to match the URL parameter, so the student's own number does not count as taken
+	student.ID = existing.ID
+	student.RowVersion = version
+	if !c.validateStudent(w, student, changed) {
+		return
+	}
+
+	// Teachers can only move students between their own classes
+	if !c.checkStudentScope(w, r, student.ClassID) {
+		return
+	}
+
+	// Update the student and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to update student")
+	if !ok {
 		return
 	}
+	defer tx.Rollback()
 
-	// Delete student from database
-	err = models.DeleteStudent(c.DB, id)
+	err := models.UpdateStudent(tx, &student)
+	if err == models.ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
+	if err == models.ErrStudentNumberTaken {
+		writeStudentNumberTaken(w)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to delete student", http.StatusInternalServerError)
+		http.Error(w, "Failed to update student", http.StatusInternalServerError)
+		return
+	}
+
+	// Get updated student
+	updatedStudent, err := models.GetStudentByID(tx, student.ID)
+	if err != nil {
+		http.Error(w, "Failed to update student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentUpdate, models.AuditResourceStudent, student.ID)
+	if !commitAudited(tx, w, r, entry, existing, updatedStudent, "Failed to update student") {
 		return
 	}
 
 	// Send response
-	w.WriteHeader(http.StatusNoContent)
-} 
\ No newline at end of file
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(updatedStudent.RowVersion))
+	json.NewEncoder(w).Encode(updatedStudent)
+}
+
+// checkStudentScope checks that a student in the given class is within the caller's class scope,
+// writing an error response and returning false otherwise
+func (c *StudentController) checkStudentScope(w http.ResponseWriter, r *http.Request, classID int64) bool {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return false
+	}
+	if !scope.allows(classID) {
+		http.Error(w, "Access to students of this class is not allowed", http.StatusForbidden)
+		return false
+	}
+	return true
+}
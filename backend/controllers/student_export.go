@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-management/logger"
+	"student-management/models"
+	"student-management/pdf"
+	"student-management/spreadsheet"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// exportColumn is a student field that can be exported. Headers match the import defaults,
+// so an exported CSV or XLSX file can be imported again.
+type exportColumn struct {
+	key    string
+	header string
+	width  float64 // Relative column width in PDF exports
+	value  func(models.Student) string
+}
+
+var exportColumns = []exportColumn{
+	{"id", "ID", 0.8, func(s models.Student) string { return strconv.FormatInt(s.ID, 10) }},
+	{"student_id", "学号", 1.6, func(s models.Student) string { return s.StudentID }},
+	{"name", "姓名", 1.6, func(s models.Student) string { return s.Name }},
+	{"class_name", "班级", 2, func(s models.Student) string { return s.ClassName }},
+	{"email", "邮箱", 3, func(s models.Student) string { return s.Email }},
+	{"phone", "电话", 1.8, func(s models.Student) string { return s.Phone }},
+	{"address", "地址", 4, func(s models.Student) string { return s.Address }},
+	{"created_at", "创建时间", 2, func(s models.Student) string { return s.CreatedAt.Format("2006-01-02 15:04") }},
+	{"updated_at", "更新时间", 2, func(s models.Student) string { return s.UpdatedAt.Format("2006-01-02 15:04") }},
+}
+
+// defaultExportColumns are exported when no columns are requested
+var defaultExportColumns = []string{"student_id", "name", "class_name", "email", "phone", "address"}
+
+// Export formats with their content types
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"pdf":  "application/pdf",
+}
+
+// ExportStudents handles GET /api/students/export to download the students matching the
+// class_id, student_id, name and tag filters of GET /api/students, without pagination.
+// "format" is csv (default), xlsx or pdf, and "columns" a comma-separated list of fields.
+func (c *StudentController) ExportStudents(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	var classID int64
+	if classIDStr := r.URL.Query().Get("class_id"); classIDStr != "" {
+		var err error
+		classID, err = strconv.ParseInt(classIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid class_id parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	format, columns, ok := parseExportParams(w, r)
+	if !ok {
+		return
+	}
+
+	// Teachers only export students in their own classes
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+	filter := models.StudentFilter{
+		ClassID:   classID,
+		StudentID: r.URL.Query().Get("student_id"),
+		Name:      r.URL.Query().Get("name"),
+		Tag:       r.URL.Query().Get("tag"),
+		ClassIDs:  scope.ids(),
+	}
+
+	// The entry is written before the download starts, so an export that cannot be audited fails
+	entry := newAuditEntry(r, models.AuditStudentExport, models.AuditResourceStudent, "")
+	entry.Details = models.AuditJSON(map[string]interface{}{
+		"format":     format,
+		"class_id":   classID,
+		"student_id": filter.StudentID,
+		"name":       filter.Name,
+		"tag":        filter.Tag,
+	})
+	if !requireAudit(c.DB, w, r, entry, "Failed to export students") {
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	filename := "students-" + strings.ReplaceAll(today, "-", "")
+	out, err := startExport(w, format, filename, "学生名单（"+today+"）", columns)
+	if err != nil {
+		http.Error(w, "Failed to export students", http.StatusInternalServerError)
+		return
+	}
+
+	// Rows are streamed, so errors from here on can only cut the download short
+	count := 0
+	err = models.EachStudent(c.DB, filter, func(s models.Student) error {
+		count++
+		return out.WriteRow(exportRow(columns, s))
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("student export failed", "rows", count, "error", err)
+	}
+}
+
+// ExportRoster handles GET /api/classes/{id}/roster to download the students of a class,
+// with the same format and columns parameters as GET /api/students/export
+func (c *ClassController) ExportRoster(w http.ResponseWriter, r *http.Request) {
+	// Get class ID from URL
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid class ID", http.StatusBadRequest)
+		return
+	}
+	format, columns, ok := parseExportParams(w, r)
+	if !ok {
+		return
+	}
+	if !c.checkClassScope(w, r, id) {
+		return
+	}
+
+	class, err := models.GetClassByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Class not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
+		}
+		return
+	}
+	students, err := models.GetStudentsByClassID(c.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve students", http.StatusInternalServerError)
+		return
+	}
+
+	entry := newAuditEntry(r, models.AuditStudentExport, models.AuditResourceClass, id)
+	entry.Details = models.AuditJSON(map[string]interface{}{"format": format, "count": len(students)})
+	if !requireAudit(c.DB, w, r, entry, "Failed to export roster") {
+		return
+	}
+
+	out, err := startExport(w, format, fmt.Sprintf("class-%d-roster", id), "班级名册："+class.Name, columns)
+	if err != nil {
+		http.Error(w, "Failed to export roster", http.StatusInternalServerError)
+		return
+	}
+	for _, s := range students {
+		if err = out.WriteRow(exportRow(columns, s)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("roster export failed", "class_id", id, "error", err)
+	}
+}
+
+// parseExportParams reads the format and columns query parameters, writing an error response
+// and returning false when either is invalid
+func parseExportParams(w http.ResponseWriter, r *http.Request) (string, []exportColumn, bool) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		http.Error(w, "Invalid format parameter, expected csv, xlsx or pdf", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	keys := defaultExportColumns
+	if param := r.URL.Query().Get("columns"); param != "" {
+		keys = strings.Split(param, ",")
+	}
+	var columns []exportColumn
+	seen := map[string]bool{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		found := false
+		for _, col := range exportColumns {
+			if col.key == key {
+				columns = append(columns, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "Unknown column: "+key, http.StatusBadRequest)
+			return "", nil, false
+		}
+		seen[key] = true
+	}
+	if len(columns) == 0 {
+		http.Error(w, "No columns to export", http.StatusBadRequest)
+		return "", nil, false
+	}
+	return format, columns, true
+}
+
+// startExport sets the download headers and returns a writer for the file, with the header row
+// written for spreadsheets. The PDF title is also used as the worksheet name.
+func startExport(w http.ResponseWriter, format, filename, title string, columns []exportColumn) (spreadsheet.Writer, error) {
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	if format == "pdf" {
+		pdfColumns := make([]pdf.Column, len(columns))
+		for i, col := range columns {
+			pdfColumns[i] = pdf.Column{Header: col.header, Width: col.width}
+		}
+		table, err := pdf.NewTableWriter(w, title, pdfColumns)
+		if err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+
+	out := spreadsheet.NewCSVWriter(w)
+	if format == "xlsx" {
+		var err error
+		if out, err = spreadsheet.NewXLSXWriter(w, title); err != nil {
+			return nil, err
+		}
+	}
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.header
+	}
+	if err := out.WriteRow(headers); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func exportRow(columns []exportColumn, s models.Student) []string {
+	row := make([]string, len(columns))
+	for i, col := range columns {
+		row[i] = col.value(s)
+	}
+	return row
+}
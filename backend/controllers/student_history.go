@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"student-management/models"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetStudentHistory handles GET /api/students/{id}/history to list every version of a student with per-field changes
+func (c *StudentController) GetStudentHistory(w http.ResponseWriter, r *http.Request) {
+	history, ok := c.findStudentHistory(w, r)
+	if !ok {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// getStudentAsOf handles GET /api/students/{id}?as_of= to retrieve a student as it was at the given time.
+// A date without a time means the end of that day.
+func (c *StudentController) getStudentAsOf(w http.ResponseWriter, r *http.Request, asOf string) {
+	t, ok := parseAsOf(w, asOf)
+	if !ok {
+		return
+	}
+	history, ok := c.findStudentHistory(w, r)
+	if !ok {
+		return
+	}
+
+	version, found := models.StudentVersionAt(history, t)
+	if !found || version.Deleted() {
+		http.Error(w, "Student did not exist at the given time", http.StatusNotFound)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Student)
+}
+
+// RevertStudent handles POST /api/students/{id}/history/{version}/revert to restore the fields of an earlier version.
+// The revert is saved as a new version, so it can itself be reverted.
+func (c *StudentController) RevertStudent(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+	history, ok := c.findStudentHistory(w, r)
+	if !ok {
+		return
+	}
+
+	var target *models.StudentVersion
+	for i := range history {
+		if history[i].Version == number {
+			target = &history[i]
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if target.Deleted() {
+		http.Error(w, "Cannot revert to a deleted version", http.StatusBadRequest)
+		return
+	}
+
+	// The student itself has to exist to be reverted
+	existing, err := models.GetStudentByID(c.DB, target.Student.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The old class must still exist and be within the caller's scope
+	student := target.Student
+	if student.ClassID > 0 {
+		if _, err := models.GetClassByID(c.DB, student.ClassID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "The class of this version no longer exists", http.StatusConflict)
+			} else {
+				http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+	if !c.checkStudentScope(w, r, student.ClassID) {
+		return
+	}
+
+	// Revert the student and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to revert student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UpdateStudent(tx, &student); err != nil {
+		if err == models.ErrStudentNumberTaken {
+			http.Error(w, "The student number of this version is now used by another student", http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to revert student", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Get updated student
+	updatedStudent, err := models.GetStudentByID(tx, student.ID)
+	if err != nil {
+		http.Error(w, "Failed to revert student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentRevert, models.AuditResourceStudent, student.ID)
+	entry.Details = models.AuditJSON(map[string]int{"version": number})
+	if !commitAudited(tx, w, r, entry, existing, updatedStudent, "Failed to revert student") {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedStudent)
+}
+
+// findStudentHistory loads the history of the student in the URL and checks the student's current
+// (or, once deleted, last) class is within the caller's scope, writing an error response and returning false on failure
+func (c *StudentController) findStudentHistory(w http.ResponseWriter, r *http.Request) ([]models.StudentVersion, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	history, err := models.GetStudentHistory(c.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve student history", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(history) == 0 {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !c.checkStudentScope(w, r, history[0].Student.ClassID) {
+		return nil, false
+	}
+	return history, true
+}
+
+// parseAsOf parses the as_of query parameter, either an RFC 3339 time or a date (2006-01-02) meaning the end of that day,
+// writing an error response and returning false when it is invalid
+func parseAsOf(w http.ResponseWriter, value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		// Include changes made within the same second
+		return t.Add(time.Second), true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid as_of parameter, expected an RFC 3339 time or a YYYY-MM-DD date", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t.AddDate(0, 0, 1), true
+}
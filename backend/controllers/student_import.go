@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"student-management/models"
+	"student-management/spreadsheet"
+)
+
+const (
+	maxImportSize = 10 << 20 // Largest accepted upload in bytes
+	maxImportRows = 5000     // Largest number of students in one import
+	maxImportCols = 100      // Columns read from an import file, far more than there are fields
+)
+
+// importColumns are the columns that can be imported, with the headers recognised for each
+// when no column mapping is given. Headers are matched case-insensitively.
+var importColumns = []struct {
+	field    string
+	required bool
+	headers  []string
+}{
+	{"student_id", true, []string{"student_id", "学号"}},
+	{"name", true, []string{"name", "姓名"}},
+	{"class", true, []string{"class", "class_name", "班级"}},
+	{"email", false, []string{"email", "邮箱"}},
+	{"phone", false, []string{"phone", "电话"}},
+	{"address", false, []string{"address", "地址"}},
+}
+
+// ImportError describes a problem with one row of an import file
+type ImportError struct {
+	Row     int    `json:"row"` // 1-based row number in the file, the header being row 1
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport is the result of an import or a dry run
+type ImportReport struct {
+	DryRun     bool          `json:"dry_run"`
+	Total      int           `json:"total"`    // Data rows in the file, blank rows excluded
+	Valid      int           `json:"valid"`    // Rows without errors
+	Imported   int           `json:"imported"` // Students created, 0 for a dry run or when any row is invalid
+	StudentIDs []int64       `json:"student_ids,omitempty"`
+	Errors     []ImportError `json:"errors"`
+}
+
+// ImportStudents handles POST /api/students/import to create students from a CSV or XLSX file.
+// The multipart form has the file in "file", an optional JSON column mapping in "mapping"
+// (e.g. {"student_id": "Student No.", "class": "Form"}), and "dry_run=true" to only validate.
+// Students are only created when every row is valid, in a single transaction.
+func (c *StudentController) ImportStudents(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("File is too large, the limit is %d MB", maxImportSize>>20), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		}
+		return
+	}
+	dryRun := r.FormValue("dry_run") == "true" || r.FormValue("dry_run") == "1"
+
+	// Read the uploaded file
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	// The header row comes on top of the students
+	rows, err := spreadsheet.Read(header.Filename, data, spreadsheet.Limits{Rows: maxImportRows + 1, Columns: maxImportCols})
+	if err == spreadsheet.ErrTooManyRows {
+		http.Error(w, fmt.Sprintf("Too many rows, at most %d students can be imported at once", maxImportRows), http.StatusBadRequest)
+		return
+	}
+	if err == spreadsheet.ErrTooManyColumns {
+		http.Error(w, fmt.Sprintf("Too many columns, at most %d columns are read", maxImportCols), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, "The file is empty", http.StatusBadRequest)
+		return
+	}
+
+	// Work out which column holds which field
+	columns, ok := importColumnIndexes(w, rows[0], r.FormValue("mapping"))
+	if !ok {
+		return
+	}
+
+	// Classes are looked up by name
+	classes, err := models.GetAllClasses(c.DB)
+	if err != nil {
+		http.Error(w, "Failed to retrieve classes", http.StatusInternalServerError)
+		return
+	}
+	classesByName := map[string][]int64{}
+	for _, class := range classes {
+		key := strings.ToLower(strings.TrimSpace(class.Name))
+		classesByName[key] = append(classesByName[key], class.ID)
+	}
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+
+	// Validate every row
+	report := ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	var students []models.Student
+	var studentRows []int
+	firstRow := map[string]int{} // lower-cased student_id -> first row it appears in
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+		if isBlankRow(row) {
+			continue
+		}
+		report.Total++
+		if report.Total > maxImportRows {
+			http.Error(w, fmt.Sprintf("Too many rows, at most %d students can be imported at once", maxImportRows), http.StatusBadRequest)
+			return
+		}
+
+		cell := func(field string) string {
+			if idx, ok := columns[field]; ok && idx < len(row) {
+				return strings.TrimSpace(row[idx])
+			}
+			return ""
+		}
+		student := models.Student{
+			StudentID: cell("student_id"),
+			Name:      cell("name"),
+			Email:     cell("email"),
+			Phone:     cell("phone"),
+			Address:   cell("address"),
+		}
+		rowErrors := validateImportRow(rowNum, &student)
+
+		// Look up the class
+		className := cell("class")
+		switch ids := classesByName[strings.ToLower(className)]; {
+		case className == "":
+			rowErrors = append(rowErrors, ImportError{rowNum, "class", "Class is required"})
+		case len(ids) == 0:
+			rowErrors = append(rowErrors, ImportError{rowNum, "class", "Class not found: " + className})
+		case len(ids) > 1:
+			rowErrors = append(rowErrors, ImportError{rowNum, "class", "Class name is ambiguous: " + className})
+		case !scope.allows(ids[0]):
+			rowErrors = append(rowErrors, ImportError{rowNum, "class", "Access to students of this class is not allowed"})
+		default:
+			student.ClassID = ids[0]
+		}
+
+		// Duplicates within the file
+		if student.StudentID != "" {
+			key := strings.ToLower(student.StudentID)
+			if first, seen := firstRow[key]; seen {
+				rowErrors = append(rowErrors, ImportError{rowNum, "student_id", fmt.Sprintf("Duplicate student_id, also in row %d", first)})
+			} else {
+				firstRow[key] = rowNum
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			report.Errors = append(report.Errors, rowErrors...)
+			continue
+		}
+		students = append(students, student)
+		studentRows = append(studentRows, rowNum)
+	}
+	if report.Total == 0 {
+		http.Error(w, "The file has no students", http.StatusBadRequest)
+		return
+	}
+
+	// Duplicates of existing students, including those in the trash
+	numbers := make([]string, len(students))
+	for i, s := range students {
+		numbers[i] = s.StudentID
+	}
+	taken, err := models.GetTakenStudentNumbers(c.DB, numbers)
+	if err != nil {
+		http.Error(w, "Failed to check existing students", http.StatusInternalServerError)
+		return
+	}
+	takenLower := map[string]bool{}
+	for n := range taken {
+		takenLower[strings.ToLower(n)] = true
+	}
+	valid := students[:0]
+	for i, s := range students {
+		if takenLower[strings.ToLower(s.StudentID)] {
+			report.Errors = append(report.Errors, ImportError{studentRows[i], "student_id", "A student with this student_id already exists"})
+			continue
+		}
+		valid = append(valid, s)
+	}
+	students = valid
+	report.Valid = len(students)
+
+	w.Header().Set("Content-Type", "application/json")
+	if dryRun {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if len(report.Errors) > 0 {
+		// All or nothing: nothing is imported while any row is invalid
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	// Create the students and the audit entry in one transaction
+	w.Header().Del("Content-Type")
+	tx, ok := beginTx(c.DB, w, "Failed to import students")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	ids, err := models.CreateStudents(tx, students)
+	if err != nil {
+		http.Error(w, "Failed to import students", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentImport, models.AuditResourceStudent, "")
+	entry.Details = models.AuditJSON(map[string]interface{}{"file": header.Filename, "student_ids": ids})
+	if !commitAudited(tx, w, r, entry, nil, nil, "Failed to import students") {
+		return
+	}
+	report.Imported = len(ids)
+	report.StudentIDs = ids
+
+	w.Header().Set("Content-Type", "application/json")
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// importColumnIndexes maps each importable field to its column in the header row, using the JSON mapping
+// of field to header when given and the default headers otherwise. It writes an error response and
+// returns false when the mapping is invalid or a required column is missing.
+func importColumnIndexes(w http.ResponseWriter, header []string, mappingJSON string) (map[string]int, bool) {
+	headerIndex := map[string]int{}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(h))
+		if _, exists := headerIndex[key]; !exists && key != "" {
+			headerIndex[key] = i
+		}
+	}
+
+	var mapping map[string]string
+	if mappingJSON != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			http.Error(w, "Invalid column mapping, expected a JSON object of field to column header", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	for field := range mapping {
+		known := false
+		for _, col := range importColumns {
+			known = known || col.field == field
+		}
+		if !known {
+			http.Error(w, "Unknown field in column mapping: "+field, http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	columns := map[string]int{}
+	var missing []string
+	for _, col := range importColumns {
+		if h, mapped := mapping[col.field]; mapped {
+			idx, found := headerIndex[strings.ToLower(strings.TrimSpace(h))]
+			if !found {
+				http.Error(w, fmt.Sprintf("Column %q mapped to %s is not in the file", h, col.field), http.StatusBadRequest)
+				return nil, false
+			}
+			columns[col.field] = idx
+			continue
+		}
+		for _, h := range col.headers {
+			if idx, found := headerIndex[h]; found {
+				columns[col.field] = idx
+				break
+			}
+		}
+		if _, found := columns[col.field]; !found && col.required {
+			missing = append(missing, col.field)
+		}
+	}
+	if len(missing) > 0 {
+		http.Error(w, "Missing required columns: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return nil, false
+	}
+	return columns, true
+}
+
+// validateImportRow checks the fields of an imported student like a student created through the API
+func validateImportRow(rowNum int, student *models.Student) []ImportError {
+	var errs []ImportError
+	for _, fieldErr := range student.Validate() {
+		// The class is looked up by name after this
+		if fieldErr.Field == "class_id" {
+			continue
+		}
+		errs = append(errs, ImportError{rowNum, fieldErr.Field, fieldErr.Message})
+	}
+	return errs
+}
+
+// isBlankRow reports whether every cell of a row is empty
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
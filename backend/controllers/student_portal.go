@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+
+	"github.com/gorilla/mux"
+)
+
+// selfEditableStudentFields are the only fields a student may change on their own record
+var selfEditableStudentFields = []string{"phone", "email", "address"}
+
+// GetOwnStudent handles GET /api/me/student to retrieve the student record linked to the caller's account
+func (c *StudentController) GetOwnStudent(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findOwnStudent(w, r)
+	if !ok {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(student.RowVersion))
+	json.NewEncoder(w).Encode(student)
+}
+
+// UpdateOwnStudent handles PATCH /api/me/student to update the caller's phone, email and address.
+// Fields that are not in the request body are left unchanged; any other field is rejected.
+func (c *StudentController) UpdateOwnStudent(w http.ResponseWriter, r *http.Request) {
+	student, ok := c.findOwnStudent(w, r)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(w, r, student.RowVersion)
+	if !ok {
+		return
+	}
+	student.RowVersion = patchVersion(version, student.RowVersion)
+
+	before := student
+
+	// Parse request body
+	var changes map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Reject fields students may not change before applying anything
+	var forbidden []string
+	for field := range changes {
+		if !containsString(selfEditableStudentFields, field) {
+			forbidden = append(forbidden, field)
+		}
+	}
+	if len(forbidden) > 0 {
+		sort.Strings(forbidden)
+		http.Error(w, "These fields cannot be changed: "+strings.Join(forbidden, ", "), http.StatusForbidden)
+		return
+	}
+
+	for field, raw := range changes {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			http.Error(w, "Field "+field+" must be a string", http.StatusBadRequest)
+			return
+		}
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "phone":
+			student.Phone = value
+		case "email":
+			student.Email = value
+		case "address":
+			student.Address = value
+		}
+	}
+
+	// Only the changed fields are checked, the rest of the record is not the student's to fix
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	if errs := student.Validate().Only(fields...); len(errs) > 0 {
+		writeValidationErrors(w, "Invalid student", errs)
+		return
+	}
+
+	// Update the student and record the audit entry in one transaction
+	tx, ok := beginTx(c.DB, w, "Failed to update student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UpdateStudent(tx, &student); err != nil {
+		if err == models.ErrVersionConflict {
+			writeVersionConflict(w)
+		} else {
+			http.Error(w, "Failed to update student", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Get updated student
+	updatedStudent, err := models.GetStudentByID(tx, student.ID)
+	if err != nil {
+		http.Error(w, "Failed to update student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentUpdate, models.AuditResourceStudent, student.ID)
+	if !commitAudited(tx, w, r, entry, before, updatedStudent, "Failed to update student") {
+		return
+	}
+	logger.FromContext(r.Context()).Info("student updated own record", "student_id", student.ID)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(updatedStudent.RowVersion))
+	json.NewEncoder(w).Encode(updatedStudent)
+}
+
+// GetOwnChildren handles GET /api/me/children to list the students linked to the caller's parent account
+func (c *StudentController) GetOwnChildren(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requirePortalRole(w, r, models.RoleParent)
+	if !ok {
+		return
+	}
+
+	children, err := models.GetGuardianChildren(c.DB, claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve students", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(children)
+}
+
+// GetOwnChild handles GET /api/me/children/{id} to retrieve one of the caller's children
+func (c *StudentController) GetOwnChild(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requirePortalRole(w, r, models.RoleParent)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		return
+	}
+
+	// Students that are not the caller's children are reported as not found
+	linked, err := models.IsGuardianOf(c.DB, claims.UserID, id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		return
+	}
+	if !linked {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+
+	student, err := models.GetStudentByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(student)
+}
+
+// requirePortalRole checks that the caller has the given self-service role,
+// writing an error response and returning false otherwise
+func requirePortalRole(w http.ResponseWriter, r *http.Request, role string) (*middleware.Claims, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if claims.Role != role {
+		http.Error(w, "This endpoint is only available to "+role+" accounts", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// findOwnStudent loads the student record linked to the caller, who must have the student role,
+// writing an error response and returning false on failure
+func (c *StudentController) findOwnStudent(w http.ResponseWriter, r *http.Request) (models.Student, bool) {
+	claims, ok := requirePortalRole(w, r, models.RoleStudent)
+	if !ok {
+		return models.Student{}, false
+	}
+
+	studentID, err := models.GetLinkedStudentID(c.DB, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No student record is linked to this account", http.StatusNotFound)
+		return models.Student{}, false
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		return models.Student{}, false
+	}
+
+	student, err := models.GetStudentByID(c.DB, studentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		}
+		return models.Student{}, false
+	}
+	return student, true
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"student-management/config"
+	"student-management/models"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TrashController handles the /api/trash endpoints for deleted students and classes
+type TrashController struct {
+	DB        *sql.DB
+	Retention time.Duration // How long deleted items are kept before they are purged automatically
+}
+
+// NewTrashController creates a new TrashController instance
+func NewTrashController(db *sql.DB) *TrashController {
+	return &TrashController{
+		DB:        db,
+		Retention: config.GetDurationEnv("TRASH_RETENTION", models.DefaultTrashRetention),
+	}
+}
+
+// PurgeExpiredTrash permanently removes the students and classes deleted before the given time. It is run
+// by the retention job rather than a request, so each item gets an audit entry without an actor, written in
+// the same transaction as the purge.
+func PurgeExpiredTrash(db *sql.DB, before time.Time) (students, classes int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	purgedStudents, purgedClasses, err := models.PurgeExpiredTrash(tx, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	details := models.AuditJSON(map[string]interface{}{"retention": true})
+	for _, s := range purgedStudents {
+		entry := newSystemAuditEntry(models.AuditStudentPurge, models.AuditResourceStudent, s.ID)
+		entry.Details = details
+		if err := recordAudit(tx, entry, s, nil); err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, c := range purgedClasses {
+		entry := newSystemAuditEntry(models.AuditClassPurge, models.AuditResourceClass, c.ID)
+		entry.Details = details
+		if err := recordAudit(tx, entry, c, nil); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return len(purgedStudents), len(purgedClasses), nil
+}
+
+// TrashedStudent is a student in the trash together with the time it will be purged
+type TrashedStudent struct {
+	models.Student
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// TrashedClass is a class in the trash together with the time it will be purged
+type TrashedClass struct {
+	models.Class
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// GetDeletedStudents handles GET /api/trash/students to list deleted students
+func (c *TrashController) GetDeletedStudents(w http.ResponseWriter, r *http.Request) {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+
+	students, err := models.GetDeletedStudents(c.DB, scope.ids())
+	if err != nil {
+		http.Error(w, "Failed to retrieve deleted students", http.StatusInternalServerError)
+		return
+	}
+	items := make([]TrashedStudent, len(students))
+	for i, s := range students {
+		items[i] = TrashedStudent{Student: s, PurgeAt: s.DeletedAt.Add(c.Retention)}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// RestoreStudent handles POST /api/trash/students/{id}/restore to take a student out of the trash
+func (c *TrashController) RestoreStudent(w http.ResponseWriter, r *http.Request) {
+	existing, ok := c.findDeletedStudent(w, r)
+	if !ok {
+		return
+	}
+
+	// A student cannot be restored into a deleted class
+	if existing.ClassID > 0 {
+		if _, err := models.GetClassByID(c.DB, existing.ClassID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "The student's class is deleted, restore the class first", http.StatusConflict)
+			} else {
+				http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	tx, ok := beginTx(c.DB, w, "Failed to restore student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.RestoreStudent(tx, existing.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found in trash", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to restore student", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	restoredStudent, err := models.GetStudentByID(tx, existing.ID)
+	if err != nil {
+		http.Error(w, "Failed to restore student", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentRestore, models.AuditResourceStudent, existing.ID)
+	if !commitAudited(tx, w, r, entry, nil, restoredStudent, "Failed to restore student") {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restoredStudent)
+}
+
+// PurgeStudent handles DELETE /api/trash/students/{id} to permanently delete a student in the trash
+func (c *TrashController) PurgeStudent(w http.ResponseWriter, r *http.Request) {
+	existing, ok := c.findDeletedStudent(w, r)
+	if !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "Failed to purge student")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.PurgeStudent(tx, existing.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found in trash", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to purge student", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditStudentPurge, models.AuditResourceStudent, existing.ID)
+	if !commitAudited(tx, w, r, entry, existing, nil, "Failed to purge student") {
+		return
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeletedClasses handles GET /api/trash/classes to list deleted classes
+func (c *TrashController) GetDeletedClasses(w http.ResponseWriter, r *http.Request) {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return
+	}
+
+	classes, err := models.GetDeletedClasses(c.DB, scope.ids())
+	if err != nil {
+		http.Error(w, "Failed to retrieve deleted classes", http.StatusInternalServerError)
+		return
+	}
+	items := make([]TrashedClass, len(classes))
+	for i, class := range classes {
+		items[i] = TrashedClass{Class: class, PurgeAt: class.DeletedAt.Add(c.Retention)}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// RestoreClass handles POST /api/trash/classes/{id}/restore to take a class out of the trash
+func (c *TrashController) RestoreClass(w http.ResponseWriter, r *http.Request) {
+	existing, ok := c.findDeletedClass(w, r)
+	if !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "Failed to restore class")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.RestoreClass(tx, existing.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Class not found in trash", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to restore class", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	restoredClass, err := models.GetClassByID(tx, existing.ID)
+	if err != nil {
+		http.Error(w, "Failed to restore class", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditClassRestore, models.AuditResourceClass, existing.ID)
+	if !commitAudited(tx, w, r, entry, nil, restoredClass, "Failed to restore class") {
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restoredClass)
+}
+
+// PurgeClass handles DELETE /api/trash/classes/{id} to permanently delete a class in the trash
+func (c *TrashController) PurgeClass(w http.ResponseWriter, r *http.Request) {
+	existing, ok := c.findDeletedClass(w, r)
+	if !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "Failed to purge class")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.PurgeClass(tx, existing.ID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "Class not found in trash", http.StatusNotFound)
+		case models.ErrClassHasStudents:
+			http.Error(w, "The class still has students in the trash, restore or purge them first", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to purge class", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditClassPurge, models.AuditResourceClass, existing.ID)
+	if !commitAudited(tx, w, r, entry, existing, nil, "Failed to purge class") {
+		return
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findDeletedStudent loads the deleted student from the URL and checks it is within the caller's class scope,
+// writing an error response and returning false on failure
+func (c *TrashController) findDeletedStudent(w http.ResponseWriter, r *http.Request) (models.Student, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		return models.Student{}, false
+	}
+
+	student, err := models.GetDeletedStudentByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Student not found in trash", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve student", http.StatusInternalServerError)
+		}
+		return models.Student{}, false
+	}
+	if !c.checkScope(w, r, student.ClassID) {
+		return models.Student{}, false
+	}
+	return student, true
+}
+
+// findDeletedClass loads the deleted class from the URL and checks it is within the caller's class scope,
+// writing an error response and returning false on failure
+func (c *TrashController) findDeletedClass(w http.ResponseWriter, r *http.Request) (models.Class, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid class ID", http.StatusBadRequest)
+		return models.Class{}, false
+	}
+
+	class, err := models.GetDeletedClassByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Class not found in trash", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve class", http.StatusInternalServerError)
+		}
+		return models.Class{}, false
+	}
+	if !c.checkScope(w, r, class.ID) {
+		return models.Class{}, false
+	}
+	return class, true
+}
+
+// checkScope checks that the class is within the caller's class scope,
+// writing an error response and returning false otherwise
+func (c *TrashController) checkScope(w http.ResponseWriter, r *http.Request, classID int64) bool {
+	scope, ok := requireClassScope(c.DB, w, r)
+	if !ok {
+		return false
+	}
+	if !scope.allows(classID) {
+		http.Error(w, "Access to this class is not allowed", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"student-management/dbtest"
+	"student-management/models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type trashStudent struct {
+	name    string
+	classID int64 // 0 is NULL
+	deleted bool
+	expired bool // deleted before the retention period
+}
+
+type trashClass struct {
+	name    string
+	deleted bool
+	expired bool
+}
+
+type trashAudit struct {
+	action, resourceID string
+	actorID            driver.Value
+}
+
+// fakeTrash is an in-memory students and classes table with the statements the trash endpoints run.
+// Like MySQL, a NULL class_id is only turned into 0 by COALESCE, and purging a class sets the class_id
+// of its students to NULL (ON DELETE SET NULL).
+type fakeTrash struct {
+	students map[int64]*trashStudent
+	classes  map[int64]*trashClass
+	audits   []trashAudit
+}
+
+func (f *fakeTrash) handle(query string, args []driver.Value) (*dbtest.Result, error) {
+	now := time.Now()
+	nullable := func(id int64) driver.Value {
+		if id == 0 {
+			return nil
+		}
+		return id
+	}
+
+	switch {
+	case strings.HasPrefix(query, "SELECT s.id, s.student_id, s.name,"):
+		id := args[0].(int64)
+		s, ok := f.students[id]
+		if !ok || s.deleted != strings.Contains(query, "s.deleted_at IS NOT NULL") {
+			return dbtest.Rows(nil), nil
+		}
+		var classID, className driver.Value = nullable(s.classID), nil
+		if c, ok := f.classes[s.classID]; ok {
+			className = c.name
+		}
+		if strings.Contains(query, "COALESCE(s.class_id, 0), COALESCE(c.name, '')") {
+			classID, className = s.classID, ""
+			if c, ok := f.classes[s.classID]; ok {
+				className = c.name
+			}
+		}
+		if s.deleted {
+			return dbtest.Rows(
+				[]string{"id", "student_id", "name", "class_id", "class_name", "email", "phone", "address", "created_at", "updated_at", "deleted_at"},
+				[]driver.Value{id, "S1", s.name, classID, className, "", "", "", now, now, now},
+			), nil
+		}
+		return dbtest.Rows(
+			[]string{"id", "student_id", "name", "class_id", "class_name", "email", "phone", "address", "tags", "created_at", "updated_at", "row_version"},
+			[]driver.Value{id, "S1", s.name, classID, className, "", "", "", nil, now, now, int64(1)},
+		), nil
+	case strings.HasPrefix(query, "SELECT c.id, c.name,"):
+		id := args[0].(int64)
+		c, ok := f.classes[id]
+		if !ok || c.deleted != strings.Contains(query, "c.deleted_at IS NOT NULL") {
+			return dbtest.Rows(nil), nil
+		}
+		if c.deleted {
+			return dbtest.Rows(
+				[]string{"id", "name", "description", "created_at", "updated_at", "student_count", "deleted_at"},
+				[]driver.Value{id, c.name, "", now, now, int64(0), now},
+			), nil
+		}
+		return dbtest.Rows(
+			[]string{"id", "name", "description", "created_at", "updated_at", "student_count", "row_version"},
+			[]driver.Value{id, c.name, "", now, now, int64(0), int64(1)},
+		), nil
+	case strings.HasPrefix(query, "UPDATE students SET deleted_at = NULL WHERE id = ?"):
+		s, ok := f.students[args[0].(int64)]
+		if !ok || !s.deleted {
+			return &dbtest.Result{}, nil
+		}
+		s.deleted = false
+		return &dbtest.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "DELETE FROM classes WHERE id = ? AND deleted_at IS NOT NULL"):
+		id := args[0].(int64)
+		c, ok := f.classes[id]
+		if !ok || !c.deleted || (strings.Contains(query, "NOT EXISTS") && f.referenced(id)) {
+			return &dbtest.Result{}, nil
+		}
+		delete(f.classes, id)
+		for _, s := range f.students {
+			if s.classID == id {
+				s.classID = 0
+			}
+		}
+		return &dbtest.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "SELECT id FROM students WHERE deleted_at IS NOT NULL AND deleted_at < ?"):
+		result := dbtest.Rows([]string{"id"})
+		for id, s := range f.students {
+			if s.deleted && s.expired {
+				result.Rows = append(result.Rows, []driver.Value{id})
+			}
+		}
+		return result, nil
+	case strings.HasPrefix(query, "SELECT id FROM classes WHERE deleted_at IS NOT NULL AND deleted_at < ?"):
+		result := dbtest.Rows([]string{"id"})
+		for id, c := range f.classes {
+			if c.deleted && c.expired && (!strings.Contains(query, "NOT EXISTS") || !f.referenced(id)) {
+				result.Rows = append(result.Rows, []driver.Value{id})
+			}
+		}
+		return result, nil
+	case strings.HasPrefix(query, "DELETE FROM students WHERE id = ? AND deleted_at IS NOT NULL"):
+		id := args[0].(int64)
+		if s, ok := f.students[id]; !ok || !s.deleted {
+			return &dbtest.Result{}, nil
+		}
+		delete(f.students, id)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "SELECT EXISTS (SELECT 1 FROM students WHERE class_id = ?)"):
+		return dbtest.Rows([]string{"exists"}, []driver.Value{f.referenced(args[0].(int64))}), nil
+	case strings.HasPrefix(query, "INSERT INTO audit_logs"):
+		f.audits = append(f.audits, trashAudit{action: args[3].(string), resourceID: args[5].(string), actorID: args[0]})
+		return &dbtest.Result{LastInsertID: int64(len(f.audits)), RowsAffected: 1}, nil
+	}
+	return nil, dbtest.Unexpected(query)
+}
+
+func (f *fakeTrash) referenced(classID int64) bool {
+	for _, s := range f.students {
+		if s.classID == classID {
+			return true
+		}
+	}
+	return false
+}
+
+func newFakeTrash() *fakeTrash {
+	return &fakeTrash{
+		students: map[int64]*trashStudent{1: {name: "Alice", classID: 10, deleted: true}},
+		classes:  map[int64]*trashClass{10: {name: "Class 1", deleted: true}},
+	}
+}
+
+func trashRequest(method, path, id string) *http.Request {
+	return mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{"id": id})
+}
+
+func TestPurgeClassWithStudentsInTrash(t *testing.T) {
+	f := newFakeTrash()
+	c := &TrashController{DB: dbtest.Open(t, f.handle)}
+
+	w := httptest.NewRecorder()
+	c.PurgeClass(w, trashRequest(http.MethodDelete, "/api/trash/classes/10", "10"))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("PurgeClass status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if _, ok := f.classes[10]; !ok || f.students[1].classID != 10 {
+		t.Fatal("PurgeClass removed a class that a student in the trash belongs to")
+	}
+	if len(f.audits) != 0 {
+		t.Errorf("audit entries %v for a refused purge", f.audits)
+	}
+
+	// Once its students are gone the class can be purged
+	delete(f.students, 1)
+	w = httptest.NewRecorder()
+	c.PurgeClass(w, trashRequest(http.MethodDelete, "/api/trash/classes/10", "10"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PurgeClass status = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body)
+	}
+	if len(f.audits) != 1 || f.audits[0].action != models.AuditClassPurge {
+		t.Errorf("audit entries = %v, want [%s]", f.audits, models.AuditClassPurge)
+	}
+}
+
+func TestRestoreStudentAfterClassPurged(t *testing.T) {
+	// A class purged before purging checked for students left its students without a class
+	f := newFakeTrash()
+	f.students[1].classID = 0
+	delete(f.classes, 10)
+	c := &TrashController{DB: dbtest.Open(t, f.handle)}
+
+	w := httptest.NewRecorder()
+	c.RestoreStudent(w, trashRequest(http.MethodPost, "/api/trash/students/1/restore", "1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("RestoreStudent status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var restored models.Student
+	if err := json.NewDecoder(w.Body).Decode(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != 1 || restored.ClassID != 0 || restored.ClassName != "" {
+		t.Errorf("restored student = %+v, want ID 1 without a class", restored)
+	}
+	if f.students[1].deleted {
+		t.Error("student is still in the trash")
+	}
+	if len(f.audits) != 1 || f.audits[0].action != models.AuditStudentRestore {
+		t.Errorf("audit entries = %v, want [%s]", f.audits, models.AuditStudentRestore)
+	}
+}
+
+func TestRestoreStudentIntoDeletedClass(t *testing.T) {
+	f := newFakeTrash()
+	c := &TrashController{DB: dbtest.Open(t, f.handle)}
+
+	w := httptest.NewRecorder()
+	c.RestoreStudent(w, trashRequest(http.MethodPost, "/api/trash/students/1/restore", "1"))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("RestoreStudent status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if !f.students[1].deleted {
+		t.Error("student was restored into a deleted class")
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	f := &fakeTrash{
+		students: map[int64]*trashStudent{
+			1: {name: "Alice", classID: 10, deleted: true, expired: true},
+			2: {name: "Bob", classID: 20, deleted: true},
+		},
+		classes: map[int64]*trashClass{
+			10: {name: "Class 1", deleted: true, expired: true},
+			20: {name: "Class 2", deleted: true, expired: true},
+		},
+	}
+
+	students, classes, err := PurgeExpiredTrash(dbtest.Open(t, f.handle), time.Now())
+	if err != nil {
+		t.Fatalf("PurgeExpiredTrash: %v", err)
+	}
+	// Class 2 is kept because Bob, who has not expired yet, still belongs to it
+	if students != 1 || classes != 1 {
+		t.Errorf("PurgeExpiredTrash = %d students, %d classes, want 1 and 1", students, classes)
+	}
+	if _, ok := f.students[1]; ok {
+		t.Error("expired student was not purged")
+	}
+	if _, ok := f.classes[10]; ok {
+		t.Error("expired class was not purged")
+	}
+	if _, ok := f.classes[20]; !ok || f.students[2].classID != 20 {
+		t.Error("a class that a student in the trash belongs to was purged")
+	}
+
+	want := []trashAudit{
+		{action: models.AuditStudentPurge, resourceID: "1"},
+		{action: models.AuditClassPurge, resourceID: "10"},
+	}
+	if !reflect.DeepEqual(f.audits, want) {
+		t.Errorf("audit entries = %+v, want %+v without an actor", f.audits, want)
+	}
+}
@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"student-management/auth"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
+	"student-management/totp"
+	"student-management/validation"
+	"time"
+)
+
+// recoveryCodeCount 是每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// totpSkew 允许验证码前后各偏差一个时间步，以容忍客户端时钟误差
+const totpSkew = 1
+
+// TwoFactorLoginRequest 表示登录第二步（两步验证）的表单数据，code 与 recovery_code 二选一
+type TwoFactorLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Validate 检查登录第二步的表单：验证码与恢复码须填写其一，验证码须为数字
+func (req TwoFactorLoginRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if req.Code == "" && req.RecoveryCode == "" {
+		errs.Add("code", validation.Required, "验证码或恢复码为必填项")
+	} else if req.Code != "" {
+		validateTOTPCode(&errs, req.Code)
+	}
+	return errs
+}
+
+// TwoFactorRequest 表示已登录用户管理两步验证时提交的表单数据
+type TwoFactorRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// totpCodePattern 匹配认证器应用生成的验证码
+var totpCodePattern = regexp.MustCompile(fmt.Sprintf(`^[0-9]{%d}$`, totp.Digits))
+
+// validateTOTPCode 检查验证码为必填项且为 totp.Digits 位数字，与 totp.Validate 一样忽略其中的空格
+func validateTOTPCode(errs *validation.Errors, code string) {
+	if errs.Required("code", code, "验证码为必填项") {
+		errs.Match("code", strings.ReplaceAll(strings.TrimSpace(code), " ", ""), totpCodePattern,
+			fmt.Sprintf("验证码应为 %d 位数字", totp.Digits))
+	}
+}
+
+// requires2FA 判断该角色是否被配置为必须启用两步验证
+func (c *AuthController) requires2FA(role string) bool {
+	return c.Require2FARoles[role]
+}
+
+// startMFAChallenge 在密码验证通过后创建登录挑战，客户端需携带 mfa_token 完成第二步验证
+func (c *AuthController) startMFAChallenge(w http.ResponseWriter, user models.User) {
+	token, tokenHash, err := models.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return
+	}
+	if err := models.CreateMFAChallenge(c.DB, user.ID, tokenHash, c.MFAChallengeTTL); err != nil {
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required":       true,
+		"mfa_setup_required": !user.TOTPEnabled,
+		"mfa_token":          token,
+		"expires_in":         int64(c.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// LoginTwoFactor 处理 POST /api/auth/login/2fa，验证登录挑战的第二因素后签发令牌。
+// 对于角色要求两步验证但尚未启用的用户，首次验证成功即完成启用并返回恢复码。
+func (c *AuthController) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+
+	log := logger.FromContext(r.Context())
+	challengeHash := models.HashToken(req.MFAToken)
+	user, ok := c.findChallengeUser(w, challengeHash)
+	if !ok {
+		return
+	}
+
+	// 第二步验证同样受登录退避和锁定策略约束
+	ip := middleware.ClientIP(r)
+	if !c.checkLoginThrottle(w, r, user.Username, ip) {
+		return
+	}
+	if _, err := models.GetMFAChallengeUser(c.DB, challengeHash); err != nil {
+		writeChallengeError(w, err)
+		return
+	}
+
+	state, err := models.GetTOTPState(c.DB, user.ID)
+	if err != nil {
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return
+	}
+
+	var recoveryCodes []string
+	switch {
+	case state.Enabled && req.RecoveryCode != "":
+		ok, err = models.UseRecoveryCode(c.DB, user.ID, req.RecoveryCode)
+		if ok {
+			log.Warn("用户使用恢复码登录", "username", user.Username)
+		}
+	case state.Enabled:
+		ok, err = c.useTOTPCode(user.ID, state, req.Code)
+	case c.requires2FA(user.Role):
+		if state.Secret == "" {
+			http.Error(w, "请先设置两步验证", http.StatusBadRequest)
+			return
+		}
+		// 登录时按角色要求启用两步验证，启用和审计日志在同一事务中提交
+		tx, txOK := beginTx(c.DB, w, "认证失败")
+		if !txOK {
+			return
+		}
+		defer tx.Rollback()
+		recoveryCodes, ok, err = c.enableTOTP(tx, user.ID, state, req.Code)
+		if err == nil && ok {
+			entry := newUserAuditEntry(r, user.ID, models.AuditTwoFactorEnable, models.AuditResourceUser, user.ID)
+			if !commitAudited(tx, w, r, entry, nil, nil, "认证失败") {
+				return
+			}
+		}
+	default:
+		// 挑战创建后两步验证被关闭且角色不再要求，需要重新登录
+		writeChallengeError(w, models.ErrMFAChallenge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "认证失败", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		log.Info("登录失败：两步验证未通过", "username", user.Username)
+		c.loginFailed(w, r, user.Username, ip)
+		return
+	}
+
+	// 登录成功，挑战作废并清除失败记录
+	if err := models.DeleteMFAChallenge(c.DB, challengeHash); err != nil {
+		log.Error("删除登录挑战失败", "error", err)
+	}
+	c.loginSucceeded(r, user.Username)
+	log.Info("登录成功", "username", user.Username, "user_id", user.ID)
+
+	// 生成访问令牌和刷新令牌
+	response, err := c.issueLoginTokens(r, user)
+	if err != nil {
+		log.Error("创建令牌失败", "error", err)
+		http.Error(w, "创建令牌失败", http.StatusInternalServerError)
+		return
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// LoginTwoFactorSetup 处理 POST /api/auth/login/2fa/setup，为角色要求两步验证但尚未启用的用户在登录过程中生成密钥
+func (c *AuthController) LoginTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := c.findChallengeUser(w, models.HashToken(req.MFAToken))
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "两步验证已启用", http.StatusConflict)
+		return
+	}
+
+	c.beginTOTPSetup(w, user)
+}
+
+// SetupTwoFactor 处理 POST /api/auth/2fa/setup，为当前用户生成新的待验证密钥
+func (c *AuthController) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "两步验证已启用", http.StatusConflict)
+		return
+	}
+
+	c.beginTOTPSetup(w, user)
+}
+
+// EnableTwoFactor 处理 POST /api/auth/2fa/enable，验证认证器应用生成的验证码后启用两步验证并返回恢复码
+func (c *AuthController) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	var errs validation.Errors
+	validateTOTPCode(&errs, req.Code)
+	if len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+
+	state, err := models.GetTOTPState(c.DB, user.ID)
+	if err != nil {
+		http.Error(w, "启用两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	if state.Enabled {
+		http.Error(w, "两步验证已启用", http.StatusConflict)
+		return
+	}
+	if state.Secret == "" {
+		http.Error(w, "请先设置两步验证", http.StatusBadRequest)
+		return
+	}
+
+	// 启用两步验证和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "启用两步验证失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	codes, ok, err := c.enableTOTP(tx, user.ID, state, req.Code)
+	if err != nil {
+		http.Error(w, "启用两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "验证码不正确", http.StatusBadRequest)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditTwoFactorEnable, models.AuditResourceUser, user.ID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "启用两步验证失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "两步验证已启用",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor 处理 POST /api/auth/2fa/disable，验证当前密码后关闭两步验证
+func (c *AuthController) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		writeValidationErrors(w, "请检查填写的内容", validation.Errors{
+			{Field: "password", Code: validation.Required, Message: "当前密码为必填项"},
+		})
+		return
+	}
+
+	if c.requires2FA(user.Role) {
+		http.Error(w, "该角色必须启用两步验证", http.StatusForbidden)
+		return
+	}
+	// 目录账号的密码同样需要通过认证后端验证
+	verified, err := c.Authenticator.Authenticate(r.Context(), user.Username, req.Password)
+	if err != nil || verified.ID != user.ID {
+		if err != nil && err != auth.ErrInvalidCredentials && err != auth.ErrNoRole {
+			http.Error(w, "认证服务暂不可用", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "当前密码不正确", http.StatusBadRequest)
+		return
+	}
+
+	// 关闭两步验证和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "关闭两步验证失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.DisableTOTP(tx, user.ID); err != nil {
+		http.Error(w, "关闭两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditTwoFactorDisable, models.AuditResourceUser, user.ID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "关闭两步验证失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 处理 POST /api/auth/2fa/recovery-codes，验证当前验证码后生成新的恢复码，旧恢复码全部失效
+func (c *AuthController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	var errs validation.Errors
+	validateTOTPCode(&errs, req.Code)
+	if len(errs) > 0 {
+		writeValidationErrors(w, "请检查填写的内容", errs)
+		return
+	}
+
+	state, err := models.GetTOTPState(c.DB, user.ID)
+	if err != nil {
+		http.Error(w, "生成恢复码失败", http.StatusInternalServerError)
+		return
+	}
+	if !state.Enabled {
+		http.Error(w, "两步验证未启用", http.StatusBadRequest)
+		return
+	}
+
+	ok, err = c.useTOTPCode(user.ID, state, req.Code)
+	if err != nil {
+		http.Error(w, "生成恢复码失败", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "验证码不正确", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := models.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "生成恢复码失败", http.StatusInternalServerError)
+		return
+	}
+	// 替换恢复码和审计日志在同一事务中提交
+	tx, ok := beginTx(c.DB, w, "生成恢复码失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.ReplaceRecoveryCodes(tx, user.ID, hashes); err != nil {
+		http.Error(w, "生成恢复码失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditRecoveryCodesRenew, models.AuditResourceUser, user.ID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "生成恢复码失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// beginTOTPSetup 生成新的待验证密钥并返回密钥和用于生成二维码的 otpauth:// 地址
+func (c *AuthController) beginTOTPSetup(w http.ResponseWriter, user models.User) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "设置两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	if err := models.SetPendingTOTPSecret(c.DB, user.ID, secret); err != nil {
+		if err == models.ErrTOTPAlreadyEnabled {
+			http.Error(w, "两步验证已启用", http.StatusConflict)
+		} else {
+			http.Error(w, "设置两步验证失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(c.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// enableTOTP 使用待验证密钥校验验证码，通过后启用两步验证并返回新生成的恢复码
+func (c *AuthController) enableTOTP(db models.DBTX, userID int64, state models.TOTPState, code string) ([]string, bool, error) {
+	step, ok := totp.Validate(state.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, false, nil
+	}
+
+	codes, hashes, err := models.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := models.EnableTOTP(db, userID, step, hashes); err != nil {
+		if err == models.ErrTOTPNotPending {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// useTOTPCode 校验已启用用户的验证码；同一时间步的验证码只能使用一次
+func (c *AuthController) useTOTPCode(userID int64, state models.TOTPState, code string) (bool, error) {
+	step, ok := totp.Validate(state.Secret, code, time.Now(), totpSkew)
+	if !ok || step <= state.LastStep {
+		return false, nil
+	}
+	return models.UseTOTPStep(c.DB, userID, step)
+}
+
+// findChallengeUser 查找登录挑战所属的用户，挑战无效或用户已被禁用时写入错误响应并返回 false
+func (c *AuthController) findChallengeUser(w http.ResponseWriter, challengeHash string) (models.User, bool) {
+	userID, err := models.PeekMFAChallengeUser(c.DB, challengeHash)
+	if err != nil {
+		writeChallengeError(w, err)
+		return models.User{}, false
+	}
+
+	user, err := models.GetUserByID(c.DB, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeChallengeError(w, models.ErrMFAChallenge)
+		} else {
+			http.Error(w, "认证失败", http.StatusInternalServerError)
+		}
+		return models.User{}, false
+	}
+	if user.Disabled {
+		http.Error(w, "账号已被禁用", http.StatusForbidden)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// currentUser 获取当前登录的用户，失败时写入错误响应并返回 false
+func (c *AuthController) currentUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return models.User{}, false
+	}
+
+	user, err := models.GetUserByID(c.DB, claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "用户不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "获取用户失败", http.StatusInternalServerError)
+		}
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// writeChallengeError 写入登录挑战无效或已过期的响应
+func writeChallengeError(w http.ResponseWriter, err error) {
+	if err == models.ErrMFAChallenge {
+		http.Error(w, "登录验证已过期，请重新登录", http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, "认证失败", http.StatusInternalServerError)
+}
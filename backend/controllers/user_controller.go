@@ -0,0 +1,769 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"student-management/middleware"
+	"student-management/models"
+
+	"github.com/gorilla/mux"
+)
+
+// UserController 处理用户管理相关的端点（需要 users:manage 权限）
+type UserController struct {
+	DB             *sql.DB
+	PasswordPolicy models.PasswordPolicy // 创建用户时初始密码需满足的策略
+}
+
+// NewUserController 创建新的 UserController
+func NewUserController(db *sql.DB) *UserController {
+	return &UserController{DB: db, PasswordPolicy: loadPasswordPolicy()}
+}
+
+// CreateUserRequest 表示创建用户的表单数据
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+// UpdateUserRequest 表示更新用户的表单数据
+type UpdateUserRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// GetUsers 处理 GET /api/users 获取用户列表
+func (c *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := models.GetAllUsers(c.DB)
+	if err != nil {
+		http.Error(w, "获取用户列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// GetUserByID 处理 GET /api/users/{id} 获取指定用户
+func (c *UserController) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// CreateUser 处理 POST /api/users 创建新用户
+func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
+	// 解析请求体
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 验证必填字段
+	if req.Username == "" || req.Password == "" || req.Email == "" {
+		http.Error(w, "用户名、密码和邮箱为必填项", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if !c.checkRole(w, req.Role) {
+		return
+	}
+	if req.Role == models.RoleAdmin && !requireAdmin(w, r) {
+		return
+	}
+	if err := c.PasswordPolicy.Validate(req.Password, req.Username); err != nil {
+		writePasswordError(w, err, "password", "创建用户失败")
+		return
+	}
+
+	// 检查用户名是否已存在
+	_, err := models.GetUserByUsername(c.DB, req.Username)
+	if err == nil {
+		http.Error(w, "用户名已存在", http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		http.Error(w, "创建用户失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 创建用户（密码在模型层进行哈希处理）
+	user := models.User{
+		Username: req.Username,
+		Password: req.Password,
+		Email:    req.Email,
+		Role:     req.Role,
+	}
+	tx, ok := beginTx(c.DB, w, "创建用户失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := models.CreateUser(tx, &user)
+	if err != nil {
+		http.Error(w, "创建用户失败", http.StatusInternalServerError)
+		return
+	}
+	createdUser, err := models.GetUserByID(tx, id)
+	if err != nil {
+		http.Error(w, "创建用户失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserCreate, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, nil, createdUser, "创建用户失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdUser)
+}
+
+// UpdateUser 处理 PUT /api/users/{id} 更新用户的邮箱和角色
+func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	existing, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	user := existing
+
+	// 解析请求体
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	if (user.Role == models.RoleAdmin || req.Role == models.RoleAdmin) && !requireAdmin(w, r) {
+		return
+	}
+
+	// 未提供的字段保持不变
+	if req.Email != "" {
+		user.Email = req.Email
+	}
+	if req.Role != "" {
+		if !c.checkRole(w, req.Role) {
+			return
+		}
+		user.Role = req.Role
+	}
+
+	tx, ok := beginTx(c.DB, w, "更新用户失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UpdateUser(tx, &user); err != nil {
+		writeUserError(w, err, "更新用户失败")
+		return
+	}
+	updatedUser, err := models.GetUserByID(tx, id)
+	if err != nil {
+		http.Error(w, "更新用户失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserUpdate, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, existing, updatedUser, "更新用户失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedUser)
+}
+
+// TeacherClassesRequest 表示设置教师班级分配的表单数据
+type TeacherClassesRequest struct {
+	ClassIDs []int64 `json:"class_ids"`
+}
+
+// GetUserClasses 处理 GET /api/users/{id}/classes 获取分配给教师的班级
+func (c *UserController) GetUserClasses(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := c.findUser(w, id); !ok {
+		return
+	}
+
+	classIDs, err := models.GetTeacherClassIDs(c.DB, id)
+	if err != nil {
+		http.Error(w, "获取班级分配失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"class_ids": classIDs})
+}
+
+// SetUserClasses 处理 PUT /api/users/{id}/classes 设置分配给教师的班级
+func (c *UserController) SetUserClasses(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleTeacher {
+		http.Error(w, "只能为教师分配班级", http.StatusBadRequest)
+		return
+	}
+
+	// 解析请求体
+	var req TeacherClassesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 检查班级是否存在
+	for _, classID := range req.ClassIDs {
+		if _, err := models.GetClassByID(c.DB, classID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "班级不存在: "+strconv.FormatInt(classID, 10), http.StatusBadRequest)
+			} else {
+				http.Error(w, "获取班级失败", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	tx, ok := beginTx(c.DB, w, "更新班级分配失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	previous, err := models.GetTeacherClassIDs(tx, id)
+	if err != nil {
+		http.Error(w, "更新班级分配失败", http.StatusInternalServerError)
+		return
+	}
+	if err := models.SetTeacherClasses(tx, id, req.ClassIDs); err != nil {
+		http.Error(w, "更新班级分配失败", http.StatusInternalServerError)
+		return
+	}
+	classIDs, err := models.GetTeacherClassIDs(tx, id)
+	if err != nil {
+		http.Error(w, "更新班级分配失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserClasses, models.AuditResourceUser, id)
+	before := map[string]interface{}{"class_ids": previous}
+	after := map[string]interface{}{"class_ids": classIDs}
+	if !commitAudited(tx, w, r, entry, before, after, "更新班级分配失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
+
+// StudentLinkRequest 表示关联学生账号与学生记录的表单数据
+type StudentLinkRequest struct {
+	StudentID int64 `json:"student_id"` // students 表的 id
+}
+
+// GetUserStudent 处理 GET /api/users/{id}/student 获取学生账号关联的学生记录
+func (c *UserController) GetUserStudent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := c.findUser(w, id); !ok {
+		return
+	}
+
+	studentID, err := models.GetLinkedStudentID(c.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "该账号没有关联学生记录", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "获取关联的学生记录失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StudentLinkRequest{StudentID: studentID})
+}
+
+// SetUserStudent 处理 PUT /api/users/{id}/student 将学生账号关联到学生记录
+func (c *UserController) SetUserStudent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleStudent {
+		http.Error(w, "只能为学生账号关联学生记录", http.StatusBadRequest)
+		return
+	}
+
+	// 解析请求体
+	var req StudentLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	// 检查学生记录是否存在
+	if _, err := models.GetStudentByID(c.DB, req.StudentID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "学生不存在: "+strconv.FormatInt(req.StudentID, 10), http.StatusBadRequest)
+		} else {
+			http.Error(w, "获取学生失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "关联学生记录失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	before, ok := linkedStudent(tx, w, id, "关联学生记录失败")
+	if !ok {
+		return
+	}
+	if err := models.LinkStudentAccount(tx, id, req.StudentID); err != nil {
+		if err == models.ErrStudentAlreadyLinked {
+			http.Error(w, "该学生记录已关联到其他账号", http.StatusConflict)
+		} else {
+			http.Error(w, "关联学生记录失败", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserLinkStudent, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, before, req, "关联学生记录失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// UnlinkUserStudent 处理 DELETE /api/users/{id}/student 解除学生账号与学生记录的关联
+func (c *UserController) UnlinkUserStudent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := c.findUser(w, id); !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "解除关联失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	before, ok := linkedStudent(tx, w, id, "解除关联失败")
+	if !ok {
+		return
+	}
+	if err := models.UnlinkStudentAccount(tx, id); err != nil {
+		http.Error(w, "解除关联失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserUnlinkStudent, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, before, nil, "解除关联失败") {
+		return
+	}
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GuardianLinkRequest 表示关联家长账号与监护人的表单数据
+type GuardianLinkRequest struct {
+	GuardianID int64 `json:"guardian_id"` // guardians 表的 id
+}
+
+// GetUserGuardian 处理 GET /api/users/{id}/guardian 获取家长账号关联的监护人
+func (c *UserController) GetUserGuardian(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := c.findUser(w, id); !ok {
+		return
+	}
+
+	guardianID, err := models.GetGuardianIDByUser(c.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "该账号没有关联监护人", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "获取关联的监护人失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GuardianLinkRequest{GuardianID: guardianID})
+}
+
+// SetUserGuardian 处理 PUT /api/users/{id}/guardian 将家长账号关联到监护人，家长即可查看该监护人的全部子女
+func (c *UserController) SetUserGuardian(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleParent {
+		http.Error(w, "只能为家长账号关联监护人", http.StatusBadRequest)
+		return
+	}
+
+	// 解析请求体
+	var req GuardianLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "关联监护人失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	before, ok := linkedGuardian(tx, w, id, "关联监护人失败")
+	if !ok {
+		return
+	}
+	if err := models.LinkGuardianUser(tx, id, req.GuardianID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			http.Error(w, "监护人不存在: "+strconv.FormatInt(req.GuardianID, 10), http.StatusBadRequest)
+		case models.ErrGuardianAlreadyLinked:
+			http.Error(w, "该监护人已关联到其他账号", http.StatusConflict)
+		default:
+			http.Error(w, "关联监护人失败", http.StatusInternalServerError)
+		}
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserLinkGuardian, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, before, req, "关联监护人失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// UnlinkUserGuardian 处理 DELETE /api/users/{id}/guardian 解除家长账号与监护人的关联
+func (c *UserController) UnlinkUserGuardian(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := c.findUser(w, id); !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "解除关联失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	before, ok := linkedGuardian(tx, w, id, "解除关联失败")
+	if !ok {
+		return
+	}
+	if err := models.UnlinkGuardianUser(tx, id); err != nil {
+		http.Error(w, "解除关联失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserUnlinkGuardian, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, before, nil, "解除关联失败") {
+		return
+	}
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DisableUser 处理 POST /api/users/{id}/disable 禁用用户
+func (c *UserController) DisableUser(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, true)
+}
+
+// EnableUser 处理 POST /api/users/{id}/enable 重新启用用户
+func (c *UserController) EnableUser(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, false)
+}
+
+// UnlockUser 处理 POST /api/users/{id}/unlock 清除用户的登录失败记录，解除锁定
+func (c *UserController) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "解除锁定失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.ClearLoginFailures(tx, models.LoginScopeUser, user.Username); err != nil {
+		http.Error(w, "解除锁定失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserUnlock, models.AuditResourceUser, user.ID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "解除锁定失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "账号已解除锁定"})
+}
+
+// ResetTwoFactor 处理 POST /api/users/{id}/2fa/reset，为丢失认证器和恢复码的用户关闭两步验证并注销其全部会话
+func (c *UserController) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleAdmin && !requireAdmin(w, r) {
+		return
+	}
+
+	// 关闭两步验证、吊销会话和审计日志在同一事务中提交：重置前登录的会话可能来自丢失的设备
+	tx, ok := beginTx(c.DB, w, "重置两步验证失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.DisableTOTP(tx, user.ID); err != nil {
+		http.Error(w, "重置两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	if err := models.RevokeUserTokens(tx, user.ID); err != nil {
+		http.Error(w, "重置两步验证失败", http.StatusInternalServerError)
+		return
+	}
+	entry := newAuditEntry(r, models.AuditTwoFactorReset, models.AuditResourceUser, user.ID)
+	if !commitAudited(tx, w, r, entry, nil, nil, "重置两步验证失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "两步验证已重置"})
+}
+
+// DeleteUser 处理 DELETE /api/users/{id} 删除用户
+func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleAdmin && !requireAdmin(w, r) {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "删除用户失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.DeleteUser(tx, id); err != nil {
+		writeUserError(w, err, "删除用户失败")
+		return
+	}
+	entry := newAuditEntry(r, models.AuditUserDelete, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, user, nil, "删除用户失败") {
+		return
+	}
+
+	// 发送响应
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setDisabled 是 DisableUser 和 EnableUser 的共同实现
+func (c *UserController) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := c.findUser(w, id)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleAdmin && !requireAdmin(w, r) {
+		return
+	}
+
+	tx, ok := beginTx(c.DB, w, "更新用户状态失败")
+	if !ok {
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.SetUserDisabled(tx, id, disabled); err != nil {
+		writeUserError(w, err, "更新用户状态失败")
+		return
+	}
+	updatedUser, err := models.GetUserByID(tx, id)
+	if err != nil {
+		http.Error(w, "更新用户状态失败", http.StatusInternalServerError)
+		return
+	}
+	action := models.AuditUserEnable
+	if disabled {
+		action = models.AuditUserDisable
+	}
+	entry := newAuditEntry(r, action, models.AuditResourceUser, id)
+	if !commitAudited(tx, w, r, entry, user, updatedUser, "更新用户状态失败") {
+		return
+	}
+
+	// 发送响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedUser)
+}
+
+// findUser 获取指定用户，失败时写入错误响应并返回 false
+func (c *UserController) findUser(w http.ResponseWriter, id int64) (models.User, bool) {
+	user, err := models.GetUserByID(c.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "用户不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "获取用户失败", http.StatusInternalServerError)
+		}
+		return user, false
+	}
+	return user, true
+}
+
+// linkedStudent 获取账号当前关联的学生记录，作为审计日志中修改前的数据；没有关联时返回 nil
+func linkedStudent(db models.DBTX, w http.ResponseWriter, userID int64, message string) (interface{}, bool) {
+	studentID, err := models.GetLinkedStudentID(db, userID)
+	if err == sql.ErrNoRows {
+		return nil, true
+	} else if err != nil {
+		http.Error(w, message, http.StatusInternalServerError)
+		return nil, false
+	}
+	return StudentLinkRequest{StudentID: studentID}, true
+}
+
+// linkedGuardian 获取账号当前关联的监护人，作为审计日志中修改前的数据；没有关联时返回 nil
+func linkedGuardian(db models.DBTX, w http.ResponseWriter, userID int64, message string) (interface{}, bool) {
+	guardianID, err := models.GetGuardianIDByUser(db, userID)
+	if err == sql.ErrNoRows {
+		return nil, true
+	} else if err != nil {
+		http.Error(w, message, http.StatusInternalServerError)
+		return nil, false
+	}
+	return GuardianLinkRequest{GuardianID: guardianID}, true
+}
+
+// checkRole 检查角色是否存在，不存在时写入错误响应并返回 false
+func (c *UserController) checkRole(w http.ResponseWriter, role string) bool {
+	exists, err := models.RoleExists(c.DB, role)
+	if err != nil {
+		http.Error(w, "获取角色失败", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, "无效的角色", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// requireAdmin 检查调用者是否为管理员，否则写入错误响应并返回 false。
+// 只有管理员才能授予管理员角色或修改管理员账号，防止通过 users:manage 权限提权。
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok || claims == nil || claims.Role != models.RoleAdmin {
+		http.Error(w, "只有管理员可以管理管理员账号", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// parseUserID 从 URL 中解析用户 ID，失败时写入错误响应并返回 false
+func parseUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "无效的用户 ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeUserError 将用户管理操作的模型层错误转换为 HTTP 响应
+func writeUserError(w http.ResponseWriter, err error, message string) {
+	if err == models.ErrLastAdmin {
+		http.Error(w, "不能删除、禁用或降级最后一个管理员", http.StatusConflict)
+		return
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"student-management/models"
+	"student-management/validation"
+)
+
+// writeValidationErrors responds 422 with every field that failed validation, e.g.
+// {"error": "Invalid student", "errors": [{"field": "email", "code": "invalid_format", "message": "..."}]}
+func writeValidationErrors(w http.ResponseWriter, message string, errs validation.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  message,
+		"errors": errs,
+	})
+}
+
+// validateStudent checks a student's fields and the records they refer to. It writes a 422 response
+// listing every failing field and returns false when the student is invalid. When changed is not nil
+// only those fields are checked, so a partial update is not held up by older data in the others.
+func (c *StudentController) validateStudent(w http.ResponseWriter, student models.Student, changed []string) bool {
+	errs := student.Validate()
+	if changed != nil {
+		if len(changed) == 0 {
+			return true
+		}
+		errs = errs.Only(changed...)
+	}
+	errs, err := models.ValidateStudentReferences(c.DB, student, errs, changed...)
+	if err != nil {
+		http.Error(w, "Failed to validate student", http.StatusInternalServerError)
+		return false
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, "Invalid student", errs)
+		return false
+	}
+	return true
+}
+
+// writeStudentNumberTaken responds to a write that lost a race for its student number
+// after validateStudent found it free
+func writeStudentNumberTaken(w http.ResponseWriter) {
+	var errs validation.Errors
+	errs.Add("student_id", validation.Taken, "Student number is already in use")
+	writeValidationErrors(w, "Invalid student", errs)
+}
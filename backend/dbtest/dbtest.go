@@ -0,0 +1,143 @@
+// Package dbtest provides a database/sql connection whose statements are answered by the test itself,
+// for testing code that takes a *sql.DB without a MySQL server.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Result is the answer to one statement: Columns and Rows for queries, LastInsertID and RowsAffected for Exec
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	LastInsertID int64
+	RowsAffected int64
+}
+
+// Handler answers a statement. The query has its whitespace collapsed to single spaces,
+// so tests can match it with strings.HasPrefix or strings.Contains.
+type Handler func(query string, args []driver.Value) (*Result, error)
+
+// Open returns a *sql.DB that sends every statement to handler. Statements are handled one at a time;
+// transactions are accepted but not isolated, so a handler sees their statements as they run.
+func Open(t testing.TB, handler Handler) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(&connector{handler: handler})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Rows is a shorthand for a query Result
+func Rows(columns []string, rows ...[]driver.Value) *Result {
+	return &Result{Columns: columns, Rows: rows}
+}
+
+// Unexpected is the error a Handler returns for a statement the test does not expect
+func Unexpected(query string) error {
+	return fmt.Errorf("dbtest: unexpected statement %q", query)
+}
+
+type connector struct {
+	mu      sync.Mutex
+	handler Handler
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return dbtestDriver{} }
+
+// dbtestDriver only exists for connector.Driver; connections come from Open
+type dbtestDriver struct{}
+
+func (dbtestDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("dbtest: use dbtest.Open")
+}
+
+func (c *connector) handle(query string, args []driver.Value) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, err := c.handler(strings.Join(strings.Fields(query), " "), args)
+	if err == nil && result == nil {
+		result = &Result{}
+	}
+	return result, err
+}
+
+type conn struct {
+	connector *connector
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := s.conn.connector.handle(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return execResult{result}, nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	result, err := s.conn.connector.handle(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{result: result}, nil
+}
+
+type execResult struct {
+	result *Result
+}
+
+func (r execResult) LastInsertId() (int64, error) { return r.result.LastInsertID, nil }
+func (r execResult) RowsAffected() (int64, error) { return r.result.RowsAffected, nil }
+
+type rows struct {
+	result *Result
+	next   int
+}
+
+func (r *rows) Columns() []string { return r.result.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	row := r.result.Rows[r.next]
+	if len(row) != len(dest) {
+		return errors.New("dbtest: row does not match the columns")
+	}
+	copy(dest, row)
+	r.next++
+	return nil
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// requestLogger holds the logger of a request; fields added by inner handlers
+// (e.g. the authenticated user) are visible to the access log written afterwards
+type requestLogger struct {
+	mu     sync.Mutex
+	logger *Logger
+}
+
+// NewContext returns a context carrying the given logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestLogger{logger: l})
+}
+
+// FromContext returns the logger of the request, or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if holder, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		return holder.logger
+	}
+	return defaultLogger
+}
+
+// AddFields adds key/value pairs to the logger carried by the context
+func AddFields(ctx context.Context, keyvals ...interface{}) {
+	if holder, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		holder.logger = holder.logger.With(keyvals...)
+	}
+}
@@ -0,0 +1,252 @@
+// Package logger writes leveled, structured log entries as JSON lines.
+//
+// Fields are passed as alternating key/value pairs. Values of keys that look
+// sensitive (passwords, tokens, secrets, ...) are replaced with "[REDACTED]",
+// including keys nested inside maps and structs, so a request body or model
+// can be logged without leaking credentials.
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// Log levels, from least to most severe
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses a level name ("debug", "info", "warn" or "error")
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("logger: unknown level %q", name)
+}
+
+// Redacted replaces the value of sensitive fields
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of field names whose values are never logged
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie",
+	"api_key", "apikey", "recovery_code", "private_key", "hash", "code",
+}
+
+// IsSensitive reports whether values of the given field name are redacted
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Logger writes JSON log entries at or above its level
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	fields []field
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// New creates a logger writing to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+var defaultLogger = New(os.Stderr, LevelInfo)
+
+// Default returns the process-wide logger
+func Default() *Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces the process-wide logger
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// With returns a logger that adds the given key/value pairs to every entry
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.fields = append(append([]field{}, l.fields...), pairs(keyvals)...)
+	return &child
+}
+
+// Enabled reports whether entries at the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug logs at debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+
+// Info logs at info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) { l.log(LevelInfo, msg, keyvals) }
+
+// Warn logs at warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) { l.log(LevelWarn, msg, keyvals) }
+
+// Error logs at error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+// Debug logs at debug level with the default logger
+func Debug(msg string, keyvals ...interface{}) { defaultLogger.log(LevelDebug, msg, keyvals) }
+
+// Info logs at info level with the default logger
+func Info(msg string, keyvals ...interface{}) { defaultLogger.log(LevelInfo, msg, keyvals) }
+
+// Warn logs at warn level with the default logger
+func Warn(msg string, keyvals ...interface{}) { defaultLogger.log(LevelWarn, msg, keyvals) }
+
+// Error logs at error level with the default logger
+func Error(msg string, keyvals ...interface{}) { defaultLogger.log(LevelError, msg, keyvals) }
+
+// Fatal logs at error level with the default logger and exits the process
+func Fatal(msg string, keyvals ...interface{}) {
+	defaultLogger.log(LevelError, msg, keyvals)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, msg)
+	for _, f := range append(append([]field{}, l.fields...), pairs(keyvals)...) {
+		buf.WriteByte(',')
+		writeJSON(&buf, f.key)
+		buf.WriteByte(':')
+		writeJSON(&buf, redactField(f.key, f.value))
+	}
+	buf.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// pairs converts alternating key/value arguments to fields; a trailing key without a value is kept with a nil value
+func pairs(keyvals []interface{}) []field {
+	fields := make([]field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		var value interface{}
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fields = append(fields, field{key: key, value: value})
+	}
+	return fields
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
+
+// redactField returns the value to log for the given field
+func redactField(key string, value interface{}) interface{} {
+	if IsSensitive(key) {
+		return Redacted
+	}
+	return Redact(value)
+}
+
+// Redact returns a copy of v that is safe to log: errors and Stringers become strings,
+// and maps and structs are converted to their JSON form with sensitive fields replaced.
+func Redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value
+	case time.Time, time.Duration:
+		return fmt.Sprint(value)
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%T", v)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Sprintf("%T", v)
+	}
+	return redactJSON(decoded)
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if IsSensitive(key) {
+				value[key] = Redacted
+			} else {
+				value[key] = redactJSON(item)
+			}
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = redactJSON(value[i])
+		}
+		return value
+	default:
+		return value
+	}
+}
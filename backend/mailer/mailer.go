@@ -0,0 +1,126 @@
+package mailer
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"student-management/config"
+	"student-management/logger"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewFromEnv creates the mailer selected by the MAILER environment variable:
+//   - "smtp": SMTPMailer configured by SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM
+//   - "file": FileMailer appending to MAIL_FILE (default "mail.log")
+//   - anything else (default "log"): LogMailer, which writes messages to the server log;
+//     bodies are only logged with MAIL_LOG_BODY=true
+func NewFromEnv() Mailer {
+	from := config.GetEnv("MAIL_FROM", "no-reply@example.com")
+
+	switch config.GetEnv("MAILER", "log") {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     config.GetEnv("SMTP_HOST", "localhost"),
+			Port:     config.GetEnv("SMTP_PORT", "587"),
+			Username: config.GetEnv("SMTP_USERNAME", ""),
+			Password: config.GetEnv("SMTP_PASSWORD", ""),
+			From:     from,
+		}
+	case "file":
+		return &FileMailer{Path: config.GetEnv("MAIL_FILE", "mail.log"), From: from}
+	default:
+		return &LogMailer{From: from, LogBody: config.GetEnv("MAIL_LOG_BODY", "false") == "true"}
+	}
+}
+
+// SMTPMailer sends messages through an SMTP server (STARTTLS is used when the server offers it)
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send sends the message over SMTP
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{stripNewlines(msg.To)}, format(m.From, msg))
+}
+
+// FileMailer appends messages to a file, for local development and testing
+type FileMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+// Send appends the message to the file
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\r\n\r\n", format(m.From, msg))
+	return err
+}
+
+// LogMailer writes messages to the server log instead of sending them.
+// Bodies can hold secrets such as password reset links, so only the recipient and subject are logged
+// unless LogBody is set, which is meant for local development only.
+type LogMailer struct {
+	From    string
+	LogBody bool
+}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	if m.LogBody {
+		logger.Info("mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+	logger.Info("mail", "to", msg.To, "subject", msg.Subject, "body_length", len(msg.Body))
+	return nil
+}
+
+// format renders the message as an RFC 5322 email
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + stripNewlines(msg.To) + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", stripNewlines(msg.Subject)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// stripNewlines prevents header injection through user-controlled header values
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
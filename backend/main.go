@@ -1,25 +1,79 @@
 package main
 
 import (
-	"fmt"
-	"log"
 	"net/http"
+	"os"
 	"student-management/config"
+	"student-management/controllers"
+	"student-management/logger"
+	"student-management/middleware"
+	"student-management/models"
 	"student-management/routes"
+	"time"
 )
 
 func main() {
+	// Structured JSON logging to stdout
+	level, err := logger.ParseLevel(config.GetEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		logger.Warn("invalid LOG_LEVEL, using info", "error", err)
+	}
+	logger.SetDefault(logger.New(os.Stdout, level))
+
 	// Initialize the database connection
 	db, err := config.InitDB()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logger.Fatal("failed to connect to database", "error", err)
+	}
+	
+	// Load the JWT signing keys
+	keys, err := middleware.LoadKeySet()
+	if err != nil {
+		logger.Fatal("failed to load JWT keys", "error", err)
 	}
+	middleware.Keys = keys
+	
+	// Configure how new passwords are hashed; older hashes are upgraded on login
+	models.PasswordHashing = models.PasswordHashConfig{
+		Algorithm:     config.GetEnv("PASSWORD_HASH", models.HashBcrypt),
+		BcryptCost:    config.GetIntEnv("BCRYPT_COST", models.PasswordHashing.BcryptCost),
+		Argon2Memory:  uint32(config.GetIntEnv("ARGON2_MEMORY", int(models.PasswordHashing.Argon2Memory))),
+		Argon2Time:    uint32(config.GetIntEnv("ARGON2_TIME", int(models.PasswordHashing.Argon2Time))),
+		Argon2Threads: uint8(config.GetIntEnv("ARGON2_THREADS", int(models.PasswordHashing.Argon2Threads))),
+	}
+	if alg := models.PasswordHashing.Algorithm; alg != models.HashBcrypt && alg != models.HashArgon2id {
+		logger.Fatal("unsupported PASSWORD_HASH", "algorithm", alg)
+	}
+	
+	// Periodically purge expired refresh tokens and revocation entries
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := models.DeleteExpiredTokens(db); err != nil {
+				logger.Error("failed to purge expired tokens", "error", err)
+			}
+		}
+	}()
+	
+	// Permanently delete students and classes that have been in the trash longer than the retention period
+	trashRetention := config.GetDurationEnv("TRASH_RETENTION", models.DefaultTrashRetention)
+	go func() {
+		for range time.Tick(time.Hour) {
+			students, classes, err := controllers.PurgeExpiredTrash(db, time.Now().Add(-trashRetention))
+			if err != nil {
+				logger.Error("failed to purge expired trash", "error", err)
+			} else if students > 0 || classes > 0 {
+				logger.Info("purged expired trash", "students", students, "classes", classes)
+			}
+		}
+	}()
 	
 	// Setup routes
 	router := routes.SetupRouter(db)
 	
 	// Start the server
 	port := config.GetEnv("PORT", "8080")
-	fmt.Printf("Server running on port %s...\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	logger.Info("server running", "port", port)
+	if err := http.ListenAndServe(":"+port, router); err != nil {
+		logger.Fatal("server stopped", "error", err)
+	}
 } 
\ No newline at end of file
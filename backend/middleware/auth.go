@@ -2,20 +2,48 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"strings"
+	"student-management/logger"
+	"student-management/models"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-// Key for JWT signing
-var JwtKey = []byte("your_secret_key") // Note: In a production app, use a secure, environment-specific key
-
-// Claims holds the JWT claims data
+// Claims holds the JWT claims data. StandardClaims.Id carries the token ID (jti)
+// used for revocation, and SessionID the login session the token was issued for.
+//
+// Impersonation tokens act as UserID and Role but also carry the admin who requested
+// them in ActorID, together with that admin's session in ActorSessionID; they have no
+// session of their own and cannot be refreshed.
+//
+// Requests authenticated with an API key get Claims with APIKeyID and Scopes set
+// and no user or role; such Claims are never signed into a token.
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+
+	ActorID        int64  `json:"act,omitempty"`
+	ActorSessionID string `json:"act_sid,omitempty"`
 	jwt.StandardClaims
+
+	APIKeyID int64    `json:"-"`
+	Scopes   []string `json:"-"`
+}
+
+// APIKeyHeader carries an API key as an alternative to a Bearer token
+const APIKeyHeader = "X-API-Key"
+
+// IsAPIKey reports whether the request was authenticated with an API key rather than a user's token
+func (c *Claims) IsAPIKey() bool {
+	return c.APIKeyID != 0
+}
+
+// IsImpersonated reports whether the token was issued to an admin acting as another user
+func (c *Claims) IsImpersonated() bool {
+	return c.ActorID != 0
 }
 
 // ContextKey is a type for context keys
@@ -24,44 +52,107 @@ type ContextKey string
 // UserContextKey is the key used to store user claims in request context
 const UserContextKey ContextKey = "user"
 
-// AuthMiddleware checks for a valid JWT token in the Authorization header
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
+// AuthMiddleware creates middleware that checks for a valid JWT token in the Authorization header
+// and rejects tokens that have been revoked (logout, password change, disabled account).
+// Integrations may instead send an API key in the X-API-Key header.
+// Every request made with an impersonation token is recorded in the audit log.
+func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+				key, err := models.AuthenticateAPIKey(db, apiKey)
+				if err == models.ErrAPIKeyInvalid {
+					http.Error(w, "Invalid, revoked or expired API key", http.StatusUnauthorized)
+					return
+				} else if err != nil {
+					http.Error(w, "Failed to validate API key", http.StatusInternalServerError)
+					return
+				}
 
-		// The header should be in the format "Bearer <token>"
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
-			return
-		}
+				logger.AddFields(r.Context(), "key_id", key.ID, "key_name", key.Name)
+				claims := &Claims{APIKeyID: key.ID, Scopes: key.Scopes}
+				ctx := context.WithValue(r.Context(), UserContextKey, claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 
-		tokenString := headerParts[1]
+			// Extract the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				return
+			}
 
-		// Parse the JWT token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return JwtKey, nil
-		})
+			// The header should be in the format "Bearer <token>"
+			headerParts := strings.Split(authHeader, " ")
+			if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+				return
+			}
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
-		}
+			tokenString := headerParts[1]
 
-		// Add the claims to the request context
-		ctx := context.WithValue(r.Context(), UserContextKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			// Parse the JWT token
+			claims := &Claims{}
+			token, err := Keys.Parse(tokenString, claims)
+
+			if err != nil || !token.Valid {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			// Check the revocation list
+			revoked, err := models.IsTokenRevoked(db, claims.Id, claims.SessionID, claims.UserID, claims.IssuedAt)
+			if err != nil {
+				http.Error(w, "Failed to validate token", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			// Tag the request's log entries with the caller, then add the claims to the request context
+			logger.AddFields(r.Context(), "user_id", claims.UserID, "role", claims.Role, "session_id", claims.SessionID)
+			ctx := context.WithValue(r.Context(), UserContextKey, claims)
+			if !claims.IsImpersonated() {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// Impersonation ends as soon as the admin logs out, is disabled or loses the admin role
+			revoked, err = models.IsTokenRevoked(db, "", claims.ActorSessionID, claims.ActorID, claims.IssuedAt)
+			if err != nil {
+				http.Error(w, "Failed to validate token", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			logger.AddFields(r.Context(), "actor_id", claims.ActorID)
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			actorID, userID := claims.ActorID, claims.UserID
+			entry := models.AuditLog{
+				ActorID: &actorID,
+				UserID:  &userID,
+				Action:  models.AuditImpersonationRequest,
+				Details: models.AuditJSON(map[string]interface{}{"method": r.Method, "path": r.URL.Path, "status": recorder.status}),
+				IP:      ClientIP(r),
+			}
+			if err := models.RecordAudit(db, entry); err != nil {
+				logger.FromContext(r.Context()).Error("failed to record impersonated request", "error", err)
+			}
+		})
+	}
 }
 
-// RoleCheck creates middleware to check if the user has the required role
-func RoleCheck(requiredRole string) func(http.Handler) http.Handler {
+// RequirePermission creates middleware that checks if the user's role grants the required permission.
+// Role definitions are read from the database on every request, so edits take effect immediately.
+func RequirePermission(db *sql.DB, permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get claims from context
@@ -71,8 +162,12 @@ func RoleCheck(requiredRole string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Check if user has the required role
-			if claims.Role != requiredRole && claims.Role != "admin" { // Admin always has access
+			allowed, err := HasPermission(db, r, permission)
+			if err != nil {
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
 				http.Error(w, "Permission denied", http.StatusForbidden)
 				return
 			}
@@ -80,4 +175,52 @@ func RoleCheck(requiredRole string) func(http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 		})
 	}
-} 
\ No newline at end of file
+}
+
+// HasPermission reports whether the authenticated caller has a permission, for handlers whose
+// required permission depends on the request. API keys are limited to their scopes; otherwise the
+// user's role must grant the permission (admin always has access).
+func HasPermission(db *sql.DB, r *http.Request, permission string) (bool, error) {
+	claims, ok := r.Context().Value(UserContextKey).(*Claims)
+	if !ok || claims == nil {
+		return false, nil
+	}
+	if claims.IsAPIKey() {
+		return models.APIKey{Scopes: claims.Scopes}.HasScope(permission), nil
+	}
+	return models.RoleHasPermission(db, claims.Role, permission)
+}
+
+// RequireUser creates middleware that rejects API keys on endpoints that act on the caller's own
+// account (profile, password, 2FA, logout), which only exist for users
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(UserContextKey).(*Claims)
+		if !ok || claims == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if claims.IsAPIKey() {
+			http.Error(w, "This endpoint requires a user login", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectImpersonation creates middleware that blocks sensitive actions (password, 2FA, sessions,
+// user and role administration) for admins who are acting as another user
+func RejectImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(UserContextKey).(*Claims)
+		if !ok || claims == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if claims.IsImpersonated() {
+			http.Error(w, "This action is not allowed while impersonating another user", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
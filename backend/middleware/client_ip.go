@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"student-management/config"
+)
+
+// trustProxyHeaders enables reading the client address from X-Forwarded-For / X-Real-IP.
+// Only enable it when the server runs behind a reverse proxy that sets these headers.
+var trustProxyHeaders = config.GetEnv("TRUST_PROXY_HEADERS", "false") == "true"
+
+// ClientIP returns the IP address of the client that sent the request
+func ClientIP(r *http.Request) string {
+	if trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
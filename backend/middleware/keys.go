@@ -0,0 +1,293 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"student-management/config"
+	"student-management/logger"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Keys holds the keys used to sign and verify JWTs. It is set at startup from LoadKeySet.
+var Keys *KeySet
+
+// SigningKey is a single JWT key identified by its key ID (kid)
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{} // nil for verify-only (retired) keys
+	VerifyKey interface{}
+}
+
+// KeySet holds every key that is accepted for verification and the one used for signing
+type KeySet struct {
+	active string
+	keys   map[string]*SigningKey
+}
+
+// keyFileEntry is one key in the JWT_KEYS_FILE document
+type keyFileEntry struct {
+	ID             string `json:"kid"`
+	Alg            string `json:"alg"`              // HS256, RS256 or EdDSA
+	Secret         string `json:"secret"`           // HS256 only
+	SecretFile     string `json:"secret_file"`      // HS256 only
+	PrivateKeyFile string `json:"private_key_file"` // RS256/EdDSA, PEM encoded
+	PublicKeyFile  string `json:"public_key_file"`  // RS256/EdDSA, PEM encoded; enough for verify-only keys
+}
+
+// keyFile is the JWT_KEYS_FILE document
+type keyFile struct {
+	Active string         `json:"active"`
+	Keys   []keyFileEntry `json:"keys"`
+}
+
+// LoadKeySet loads the JWT keys from the environment:
+//   - JWT_KEYS_FILE: JSON file with {"active": "<kid>", "keys": [...]}, for rotation and asymmetric keys
+//   - JWT_SECRET or JWT_SECRET_FILE: a single HS256 secret with key ID JWT_KEY_ID (default "default")
+//
+// If neither is set, a random secret is generated and tokens do not survive a restart.
+func LoadKeySet() (*KeySet, error) {
+	if path := config.GetEnv("JWT_KEYS_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT keys file: %w", err)
+		}
+		var doc keyFile
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse JWT keys file: %w", err)
+		}
+		return newKeySetFromFile(doc)
+	}
+
+	kid := config.GetEnv("JWT_KEY_ID", "default")
+	secret := config.GetEnv("JWT_SECRET", "")
+	if path := config.GetEnv("JWT_SECRET_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT secret file: %w", err)
+		}
+		secret = string(trimNewline(data))
+	}
+
+	if secret == "" {
+		logger.Warn("JWT_SECRET is not set, using a random signing key; tokens will not survive a restart")
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = string(buf)
+	}
+
+	ks := &KeySet{active: kid, keys: map[string]*SigningKey{}}
+	ks.keys[kid] = &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}
+	return ks, nil
+}
+
+func newKeySetFromFile(doc keyFile) (*KeySet, error) {
+	ks := &KeySet{active: doc.Active, keys: map[string]*SigningKey{}}
+	for _, entry := range doc.Keys {
+		if entry.ID == "" {
+			return nil, errors.New("JWT key without kid")
+		}
+		if _, exists := ks.keys[entry.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key %q", entry.ID)
+		}
+		key, err := loadKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", entry.ID, err)
+		}
+		ks.keys[entry.ID] = key
+	}
+
+	active, ok := ks.keys[doc.Active]
+	if !ok {
+		return nil, fmt.Errorf("active JWT key %q not found", doc.Active)
+	}
+	if active.SignKey == nil {
+		return nil, fmt.Errorf("active JWT key %q has no private key", doc.Active)
+	}
+	return ks, nil
+}
+
+func loadKey(entry keyFileEntry) (*SigningKey, error) {
+	key := &SigningKey{ID: entry.ID}
+
+	switch entry.Alg {
+	case "HS256":
+		secret := []byte(entry.Secret)
+		if entry.SecretFile != "" {
+			data, err := os.ReadFile(entry.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = trimNewline(data)
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("HS256 key requires secret or secret_file")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.SignKey = secret
+		key.VerifyKey = secret
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if entry.PrivateKeyFile != "" {
+			data, err := os.ReadFile(entry.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		} else if entry.PublicKeyFile != "" {
+			data, err := os.ReadFile(entry.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.VerifyKey = publicKey
+		} else {
+			return nil, errors.New("RS256 key requires private_key_file or public_key_file")
+		}
+
+	case "EdDSA":
+		key.Method = SigningMethodEd25519
+		if entry.PrivateKeyFile != "" {
+			parsed, err := parsePEM(entry.PrivateKeyFile, x509.ParsePKCS8PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("private key is not an Ed25519 key")
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = privateKey.Public()
+		} else if entry.PublicKeyFile != "" {
+			parsed, err := parsePEM(entry.PublicKeyFile, x509.ParsePKIXPublicKey)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, errors.New("public key is not an Ed25519 key")
+			}
+			key.VerifyKey = publicKey
+		} else {
+			return nil, errors.New("EdDSA key requires private_key_file or public_key_file")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", entry.Alg)
+	}
+
+	return key, nil
+}
+
+func parsePEM(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	return parse(block.Bytes)
+}
+
+func trimNewline(data []byte) []byte {
+	for len(data) > 0 && (data[len(data)-1] == '\n' || data[len(data)-1] == '\r') {
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+// Sign signs the claims with the active key and records its kid in the token header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := ks.keys[ks.active]
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey)
+}
+
+// Parse verifies the token with the key named by its kid header and fills in claims.
+// The token's alg must match the algorithm configured for that key.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+		}
+		return key.VerifyKey, nil
+	})
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS returns the public keys of all asymmetric keys so other services can verify tokens.
+// HS256 secrets are never exposed.
+func (ks *KeySet) PublicJWKS() []JWK {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	keys := []JWK{}
+	for _, id := range ids {
+		key := ks.keys[id]
+		switch publicKey := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+	return keys
+}
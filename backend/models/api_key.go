@@ -0,0 +1,237 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// apiKeyPrefix 标识 API 密钥，便于在日志和代码仓库扫描中识别泄露的密钥
+const apiKeyPrefix = "smk_"
+
+// apiKeyDisplayLength 是列表中显示的密钥前缀长度，用于区分不同的密钥
+const apiKeyDisplayLength = 12
+
+// apiKeyTouchInterval 限制 last_used_at 的更新频率，避免每个请求都写数据库
+const apiKeyTouchInterval = time.Minute
+
+// ErrAPIKeyInvalid 表示 API 密钥不存在、已吊销或已过期
+var ErrAPIKeyInvalid = errors.New("models: API key is invalid, revoked or expired")
+
+// APIKeyScopes 是可以授予 API 密钥的权限；管理用户、角色和密钥的权限只授予登录用户
+var APIKeyScopes = []string{
+	PermStudentsRead,
+	PermStudentsWrite,
+	PermStudentsDelete,
+	PermClassesRead,
+	PermClassesWrite,
+	PermClassesDelete,
+}
+
+// APIKey 表示一个系统集成使用的 API 密钥，密钥本身只在创建时返回一次
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 密钥的前几位，用于识别
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *int64     `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsValidAPIKeyScope 判断权限是否可以授予 API 密钥
+func IsValidAPIKeyScope(permission string) bool {
+	for _, scope := range APIKeyScopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 判断密钥是否拥有指定权限
+func (k APIKey) HasScope(permission string) bool {
+	for _, scope := range k.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey 生成并保存新的 API 密钥，返回密钥明文（只有这一次机会获取）
+func CreateAPIKey(db DBTX, key *APIKey) (string, error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	plain := apiKeyPrefix + token
+	key.Prefix = plain[:apiKeyDisplayLength]
+
+	err = inTx(db, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO api_keys (name, key_prefix, key_hash, created_by, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, NOW())
+		`
+		result, err := tx.Exec(query, key.Name, key.Prefix, HashToken(plain), key.CreatedBy, key.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if key.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		for _, scope := range key.Scopes {
+			if _, err := tx.Exec("INSERT IGNORE INTO api_key_scopes (api_key_id, permission) VALUES (?, ?)", key.ID, scope); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// GetAllAPIKeys 获取所有 API 密钥（包括已吊销的），不含密钥本身
+func GetAllAPIKeys(db *sql.DB) ([]APIKey, error) {
+	query := `
+		SELECT id, name, key_prefix, created_by, expires_at, last_used_at, revoked_at, created_at
+		FROM api_keys
+		ORDER BY revoked_at IS NOT NULL, created_at DESC
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		keys[i].Scopes, err = getAPIKeyScopes(db, keys[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// GetAPIKey 通过 ID 获取 API 密钥
+func GetAPIKey(db DBTX, id int64) (APIKey, error) {
+	query := `
+		SELECT id, name, key_prefix, created_by, expires_at, last_used_at, revoked_at, created_at
+		FROM api_keys
+		WHERE id = ?
+	`
+	key, err := scanAPIKey(db.QueryRow(query, id))
+	if err != nil {
+		return key, err
+	}
+	key.Scopes, err = getAPIKeyScopes(db, id)
+	return key, err
+}
+
+// AuthenticateAPIKey 通过密钥明文查找有效的 API 密钥，并记录最近使用时间
+func AuthenticateAPIKey(db *sql.DB, plain string) (APIKey, error) {
+	query := `
+		SELECT id, name, key_prefix, created_by, expires_at, last_used_at, revoked_at, created_at
+		FROM api_keys
+		WHERE key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	key, err := scanAPIKey(db.QueryRow(query, HashToken(plain)))
+	if err == sql.ErrNoRows {
+		return APIKey{}, ErrAPIKeyInvalid
+	} else if err != nil {
+		return APIKey{}, err
+	}
+
+	key.Scopes, err = getAPIKeyScopes(db, key.ID)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	touch := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < DATE_SUB(NOW(), INTERVAL ? SECOND))
+	`
+	if _, err := db.Exec(touch, key.ID, int64(apiKeyTouchInterval.Seconds())); err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey 吊销 API 密钥；记录保留以便查看历史使用情况
+func RevokeAPIKey(db DBTX, id int64) error {
+	result, err := db.Exec("UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowScanner 是 *sql.Row 和 *sql.Rows 的公共接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var createdBy sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &createdBy, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return key, err
+	}
+	if createdBy.Valid {
+		key.CreatedBy = &createdBy.Int64
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+func getAPIKeyScopes(db DBTX, id int64) ([]string, error) {
+	rows, err := db.Query("SELECT permission FROM api_key_scopes WHERE api_key_id = ? ORDER BY permission", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scopes := []string{}
+	for rows.Next() {
+		var scope string
+		if err := rows.Scan(&scope); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, rows.Err()
+}
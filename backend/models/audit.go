@@ -0,0 +1,224 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// 审计日志中的操作，格式为 "<对象>.<动作>"
+const (
+	AuditImpersonationStart   = "impersonation.start"   // 管理员开始模拟某个用户
+	AuditImpersonationRequest = "impersonation.request" // 模拟期间发出的请求
+
+	AuditStudentCreate  = "student.create"
+	AuditStudentUpdate  = "student.update"
+	AuditStudentDelete  = "student.delete"
+	AuditStudentRevert  = "student.revert"  // 恢复到历史版本
+	AuditStudentRestore = "student.restore" // 从回收站恢复
+	AuditStudentPurge   = "student.purge"   // 从回收站永久删除
+	AuditStudentImport  = "student.import"  // 从 CSV/XLSX 批量导入
+	AuditStudentExport  = "student.export"  // 导出学生名单或班级名册
+	AuditStudentTag     = "student.tag"     // 添加标签
+	AuditStudentUntag   = "student.untag"   // 移除标签
+	AuditGuardianCreate = "guardian.create"
+	AuditGuardianLink   = "guardian.link"
+	AuditGuardianUpdate = "guardian.update"
+	AuditGuardianUnlink = "guardian.unlink"
+	AuditClassCreate    = "class.create"
+	AuditClassUpdate    = "class.update"
+	AuditClassDelete    = "class.delete"
+	AuditClassRestore   = "class.restore"
+	AuditClassPurge     = "class.purge"
+
+	AuditLogin               = "auth.login"  // 登录成功，创建会话
+	AuditLogout              = "auth.logout" // 退出登录，吊销会话
+	AuditPasswordChange      = "password.change"
+	AuditPasswordReset       = "password.reset"
+	AuditTwoFactorEnable     = "2fa.enable"
+	AuditTwoFactorDisable    = "2fa.disable"
+	AuditTwoFactorReset      = "2fa.reset" // 管理员为用户重置两步验证
+	AuditRecoveryCodesRenew  = "2fa.recovery_codes"
+	AuditSessionRevoke       = "session.revoke"
+	AuditSessionRevokeOthers = "session.revoke_others"
+
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update" // 修改邮箱或角色
+	AuditUserDelete         = "user.delete"
+	AuditUserDisable        = "user.disable"
+	AuditUserEnable         = "user.enable"
+	AuditUserUnlock         = "user.unlock"  // 清除登录失败记录
+	AuditUserClasses        = "user.classes" // 设置教师的班级分配
+	AuditUserLinkStudent    = "user.link_student"
+	AuditUserUnlinkStudent  = "user.unlink_student"
+	AuditUserLinkGuardian   = "user.link_guardian"
+	AuditUserUnlinkGuardian = "user.unlink_guardian"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditAPIKeyCreate       = "api_key.create"
+	AuditAPIKeyRevoke       = "api_key.revoke"
+)
+
+// 审计日志中的对象类型
+const (
+	AuditResourceStudent  = "student"
+	AuditResourceGuardian = "guardian"
+	AuditResourceClass    = "class"
+	AuditResourceUser     = "user"
+	AuditResourceSession  = "session"
+	AuditResourceRole     = "role"
+	AuditResourceAPIKey   = "api_key"
+)
+
+// AuditLog 表示一条审计日志；审计日志只能追加，数据库触发器禁止修改和删除
+type AuditLog struct {
+	ID            int64           `json:"id"`
+	ActorID       *int64          `json:"actor_id"` // 实际执行操作的用户，模拟登录时为管理员；系统任务（如回收站定期清理）为空
+	ActorUsername string          `json:"actor_username,omitempty"`
+	UserID        *int64          `json:"user_id"` // 操作以其身份执行的用户
+	Username      string          `json:"username,omitempty"`
+	APIKeyID      *int64          `json:"api_key_id"` // 通过 API 密钥执行时的密钥
+	Action        string          `json:"action"`
+	Resource      string          `json:"resource"`
+	ResourceID    string          `json:"resource_id"`
+	Before        json.RawMessage `json:"before,omitempty"` // 修改或删除前的数据
+	After         json.RawMessage `json:"after,omitempty"`  // 创建或修改后的数据
+	Details       json.RawMessage `json:"details,omitempty"`
+	IP            string          `json:"ip"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditFilter 保存查询审计日志的可选条件
+type AuditFilter struct {
+	ActorID    int64
+	UserID     int64
+	Action     string
+	Resource   string
+	ResourceID string
+	From       time.Time // 包含
+	To         time.Time // 不包含
+}
+
+// AuditJSON 将审计日志中的数据序列化为 JSON，v 为 nil 时返回 nil
+func AuditJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// RecordAudit 写入一条审计日志
+func RecordAudit(db DBTX, entry AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (actor_id, user_id, api_key_id, action, resource, resource_id,
+		before_data, after_data, details, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`
+	_, err := db.Exec(query,
+		entry.ActorID, entry.UserID, entry.APIKeyID, entry.Action, entry.Resource, entry.ResourceID,
+		nullJSON(entry.Before), nullJSON(entry.After), nullJSON(entry.Details), entry.IP,
+	)
+	return err
+}
+
+// GetAuditLogs 按条件分页查询审计日志，最新的排在前面
+func GetAuditLogs(db *sql.DB, filter AuditFilter, page, pageSize int) ([]AuditLog, int, error) {
+	where := " WHERE 1=1"
+	params := []interface{}{}
+
+	if filter.ActorID > 0 {
+		where += " AND a.actor_id = ?"
+		params = append(params, filter.ActorID)
+	}
+	if filter.UserID > 0 {
+		where += " AND a.user_id = ?"
+		params = append(params, filter.UserID)
+	}
+	if filter.Action != "" {
+		where += " AND a.action = ?"
+		params = append(params, filter.Action)
+	}
+	if filter.Resource != "" {
+		where += " AND a.resource = ?"
+		params = append(params, filter.Resource)
+	}
+	if filter.ResourceID != "" {
+		where += " AND a.resource_id = ?"
+		params = append(params, filter.ResourceID)
+	}
+	if !filter.From.IsZero() {
+		where += " AND a.created_at >= ?"
+		params = append(params, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where += " AND a.created_at < ?"
+		params = append(params, filter.To)
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_logs a"+where, params...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT a.id, a.actor_id, COALESCE(actor.username, ''), a.user_id, COALESCE(u.username, ''),
+		a.api_key_id, a.action, a.resource, a.resource_id, a.before_data, a.after_data, a.details,
+		a.ip, a.created_at
+		FROM audit_logs a
+		LEFT JOIN users actor ON actor.id = a.actor_id
+		LEFT JOIN users u ON u.id = a.user_id
+	` + where + " ORDER BY a.id DESC LIMIT ? OFFSET ?"
+	params = append(params, pageSize, (page-1)*pageSize)
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []AuditLog{}
+	for rows.Next() {
+		var l AuditLog
+		var actorID, userID, apiKeyID sql.NullInt64
+		var before, after, details sql.NullString
+		err := rows.Scan(
+			&l.ID, &actorID, &l.ActorUsername, &userID, &l.Username,
+			&apiKeyID, &l.Action, &l.Resource, &l.ResourceID, &before, &after, &details,
+			&l.IP, &l.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		l.ActorID = nullInt64Ptr(actorID)
+		l.UserID = nullInt64Ptr(userID)
+		l.APIKeyID = nullInt64Ptr(apiKeyID)
+		l.Before = rawJSON(before)
+		l.After = rawJSON(after)
+		l.Details = rawJSON(details)
+		logs = append(logs, l)
+	}
+	return logs, total, rows.Err()
+}
+
+func nullJSON(data json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(data), Valid: len(data) > 0}
+}
+
+func rawJSON(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
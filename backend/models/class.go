@@ -2,25 +2,48 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"student-management/validation"
 	"time"
 )
 
+// Maximum lengths of the class fields in characters
+const (
+	MaxClassNameLength        = 100
+	MaxClassDescriptionLength = 500 // description is TEXT, the limit keeps it to a short summary
+)
+
 // Class represents a class in the school
 type Class struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	StudentCount int      `json:"student_count,omitempty"` // Not stored in DB, calculated when needed
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StudentCount int        `json:"student_count,omitempty"` // Not stored in DB, calculated when needed
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`    // Set while the class is in the trash
+	RowVersion   int64      `json:"-"`                       // Incremented on every change, sent as the ETag
+}
+
+// Validate checks the format and length of the class's fields
+func (c Class) Validate() validation.Errors {
+	var errs validation.Errors
+	if errs.Required("name", c.Name, "Name is required") {
+		errs.MaxLength("name", c.Name, MaxClassNameLength,
+			fmt.Sprintf("Name cannot be longer than %d characters", MaxClassNameLength))
+	}
+	errs.MaxLength("description", c.Description, MaxClassDescriptionLength,
+		fmt.Sprintf("Description cannot be longer than %d characters", MaxClassDescriptionLength))
+	return errs
 }
 
 // GetAllClasses retrieves all classes from the database
 func GetAllClasses(db *sql.DB) ([]Class, error) {
 	query := `
 		SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id) as student_count
+		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id AND s.deleted_at IS NULL) as student_count
 		FROM classes c
+		WHERE c.deleted_at IS NULL
 		ORDER BY c.name
 	`
 	rows, err := db.Query(query)
@@ -44,22 +67,24 @@ func GetAllClasses(db *sql.DB) ([]Class, error) {
 }
 
 // GetClassByID retrieves a class by ID
-func GetClassByID(db *sql.DB, id int64) (Class, error) {
+func GetClassByID(db DBTX, id int64) (Class, error) {
 	var class Class
 	query := `
 		SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id) as student_count
+		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id AND s.deleted_at IS NULL) as student_count,
+		c.row_version
 		FROM classes c
-		WHERE c.id = ?
+		WHERE c.id = ? AND c.deleted_at IS NULL
 	`
 	err := db.QueryRow(query, id).Scan(
 		&class.ID, &class.Name, &class.Description, &class.CreatedAt, &class.UpdatedAt, &class.StudentCount,
+		&class.RowVersion,
 	)
 	return class, err
 }
 
 // CreateClass inserts a new class into the database
-func CreateClass(db *sql.DB, class *Class) (int64, error) {
+func CreateClass(db DBTX, class *Class) (int64, error) {
 	query := `
 		INSERT INTO classes (name, description, created_at, updated_at)
 		VALUES (?, ?, NOW(), NOW())
@@ -71,31 +96,31 @@ func CreateClass(db *sql.DB, class *Class) (int64, error) {
 	return result.LastInsertId()
 }
 
-// UpdateClass updates an existing class
-func UpdateClass(db *sql.DB, class *Class) error {
+// UpdateClass updates an existing class. When class.RowVersion is set, the class is only
+// updated if it is still at that version, and ErrVersionConflict is returned otherwise.
+func UpdateClass(db DBTX, class *Class) error {
 	query := `
 		UPDATE classes
 		SET name = ?, description = ?, updated_at = NOW()
 		WHERE id = ?
 	`
-	_, err := db.Exec(query, class.Name, class.Description, class.ID)
-	return err
+	return execAtVersion(db, query, []interface{}{class.Name, class.Description, class.ID}, class.RowVersion)
 }
 
-// DeleteClass removes a class from the database
-func DeleteClass(db *sql.DB, id int64) error {
-	// First check if there are students in this class
+// DeleteClass moves a class to the trash; it can be restored until it is purged.
+// A non-zero version makes the delete conditional like in UpdateClass.
+func DeleteClass(db DBTX, id, version int64) error {
+	// First check if there are students in this class (students in the trash do not count)
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM students WHERE class_id = ?", id).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM students WHERE class_id = ? AND deleted_at IS NULL", id).Scan(&count)
 	if err != nil {
 		return err
 	}
-	
+
 	if count > 0 {
 		return sql.ErrNoRows // Using standard error to indicate class has students
 	}
-	
-	query := "DELETE FROM classes WHERE id = ?"
-	_, err = db.Exec(query, id)
-	return err
-} 
\ No newline at end of file
+
+	query := "UPDATE classes SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+	return execAtVersion(db, query, []interface{}{id}, version)
+}
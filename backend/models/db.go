@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// DBTX 是 *sql.DB 和 *sql.Tx 的公共接口。接受 DBTX 的函数既可以单独执行，
+// 也可以在调用方的事务中执行，使数据修改和对应的审计日志一起提交
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// inTx 在事务中执行 fn：db 已经是事务时直接在其中执行，由调用方提交；
+// 否则开始一个新事务，fn 成功后提交
+func inTx(db DBTX, fn func(tx *sql.Tx) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	beginner, ok := db.(interface{ Begin() (*sql.Tx, error) })
+	if !ok {
+		return errors.New("models: cannot start a transaction")
+	}
+	tx, err := beginner.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	// ErrAuthSourceConflict 表示同名的用户已存在但属于其他认证来源（例如本地账号）
+	ErrAuthSourceConflict = errors.New("models: username belongs to a different authentication source")
+	// ErrUsernameTaken 表示单点登录的新用户想用的用户名已被另一个单点登录账号使用
+	ErrUsernameTaken = errors.New("models: username is already taken")
+)
+
+// unusablePassword 是目录账号在 users 表中的密码占位符，它不是有效的哈希，永远无法通过本地密码验证
+const unusablePassword = "!"
+
+// ProvisionDirectoryUser 在外部目录认证成功后创建或同步本地用户：首次登录时创建，之后每次登录同步邮箱和角色。
+// 角色发生变化时吊销该用户的全部会话；同名的用户属于其他认证来源时返回 ErrAuthSourceConflict。
+func ProvisionDirectoryUser(db *sql.DB, source, username, email, role string) (User, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var currentSource, currentRole string
+	query := "SELECT id, auth_source, role FROM users WHERE username = ? FOR UPDATE"
+	err = tx.QueryRow(query, username).Scan(&id, &currentSource, &currentRole)
+	switch {
+	case err == sql.ErrNoRows:
+		insert := `
+			INSERT INTO users (username, password, email, role, auth_source, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+		`
+		result, err := tx.Exec(insert, username, unusablePassword, email, role, source)
+		if err != nil {
+			return User{}, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return User{}, err
+		}
+	case err != nil:
+		return User{}, err
+	case currentSource != source:
+		return User{}, ErrAuthSourceConflict
+	default:
+		if err := syncDirectoryUser(tx, id, email, role, currentRole); err != nil {
+			return User{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, err
+	}
+	return GetUserByID(db, id)
+}
+
+// ProvisionOIDCUser 与 ProvisionDirectoryUser 相同，但按签发方和 sub 查找单点登录账号：这两项不会变化且全局唯一，
+// 用户名只在创建账号时使用。用户名已被其他来源的账号使用时返回 ErrAuthSourceConflict，
+// 被另一个单点登录账号使用时返回 ErrUsernameTaken
+func ProvisionOIDCUser(db *sql.DB, issuer, subject, username, email, role string) (User, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var currentRole string
+	query := "SELECT id, role FROM users WHERE oidc_issuer = ? AND oidc_subject = ? FOR UPDATE"
+	err = tx.QueryRow(query, issuer, subject).Scan(&id, &currentRole)
+	switch {
+	case err == sql.ErrNoRows:
+		var currentSource string
+		err = tx.QueryRow("SELECT auth_source FROM users WHERE username = ? FOR UPDATE", username).Scan(&currentSource)
+		switch {
+		case err == nil && currentSource != AuthSourceOIDC:
+			return User{}, ErrAuthSourceConflict
+		case err == nil:
+			return User{}, ErrUsernameTaken
+		case err != sql.ErrNoRows:
+			return User{}, err
+		}
+
+		insert := `
+			INSERT INTO users (username, password, email, role, auth_source, oidc_issuer, oidc_subject, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		`
+		result, err := tx.Exec(insert, username, unusablePassword, email, role, AuthSourceOIDC, issuer, subject)
+		if err != nil {
+			return User{}, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return User{}, err
+		}
+	case err != nil:
+		return User{}, err
+	default:
+		if err := syncDirectoryUser(tx, id, email, role, currentRole); err != nil {
+			return User{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, err
+	}
+	return GetUserByID(db, id)
+}
+
+// syncDirectoryUser 同步已有目录账号的邮箱和角色，角色发生变化时吊销该用户的全部会话
+func syncDirectoryUser(tx *sql.Tx, id int64, email, role, currentRole string) error {
+	update := "UPDATE users SET email = ?, role = ?, updated_at = NOW() WHERE id = ?"
+	if _, err := tx.Exec(update, email, role, id); err != nil {
+		return err
+	}
+	if role != currentRole {
+		return revokeUserTokens(tx, id)
+	}
+	return nil
+}
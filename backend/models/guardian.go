@@ -0,0 +1,295 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"student-management/validation"
+	"time"
+)
+
+// ErrGuardianAlreadyLinked is returned when a guardian is already linked to another parent account
+var ErrGuardianAlreadyLinked = errors.New("models: guardian is already linked to another account")
+
+// Guardian represents a parent or other guardian of one or more students
+type Guardian struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Relationship     string    `json:"relationship"` // e.g. father, mother, grandparent
+	Phone            string    `json:"phone"`
+	Email            string    `json:"email"`
+	EmergencyContact bool      `json:"emergency_contact"`
+	UserID           *int64    `json:"user_id"` // parent login account, if any
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Maximum lengths of the guardian fields in characters, from the guardians table
+const (
+	MaxGuardianNameLength         = 100
+	MaxGuardianRelationshipLength = 50
+	MaxGuardianPhoneLength        = 20
+	MaxGuardianEmailLength        = 100
+)
+
+// Validate checks the format and length of the guardian's fields
+func (g Guardian) Validate() validation.Errors {
+	var errs validation.Errors
+	if errs.Required("name", g.Name, "Name is required") {
+		errs.MaxLength("name", g.Name, MaxGuardianNameLength,
+			fmt.Sprintf("Name cannot be longer than %d characters", MaxGuardianNameLength))
+	}
+	if errs.Required("relationship", g.Relationship, "Relationship is required") {
+		errs.MaxLength("relationship", g.Relationship, MaxGuardianRelationshipLength,
+			fmt.Sprintf("Relationship cannot be longer than %d characters", MaxGuardianRelationshipLength))
+	}
+	// An emergency contact has to be reachable
+	if g.EmergencyContact && g.Phone == "" {
+		errs.Add("phone", validation.Required, "An emergency contact needs a phone number")
+	}
+	errs.MaxLength("phone", g.Phone, MaxGuardianPhoneLength,
+		fmt.Sprintf("Phone cannot be longer than %d characters", MaxGuardianPhoneLength))
+	if errs.MaxLength("email", g.Email, MaxGuardianEmailLength,
+		fmt.Sprintf("Email cannot be longer than %d characters", MaxGuardianEmailLength)) {
+		errs.Email("email", g.Email, "Invalid email address")
+	}
+	return errs
+}
+
+const guardianColumns = `g.id, g.name, g.relationship, g.phone, g.email, g.emergency_contact, g.user_id, g.created_at, g.updated_at`
+
+// scanGuardian scans a row selected with guardianColumns
+func scanGuardian(row rowScanner) (Guardian, error) {
+	var g Guardian
+	var userID sql.NullInt64
+	err := row.Scan(&g.ID, &g.Name, &g.Relationship, &g.Phone, &g.Email, &g.EmergencyContact, &userID, &g.CreatedAt, &g.UpdatedAt)
+	if userID.Valid {
+		g.UserID = &userID.Int64
+	}
+	return g, err
+}
+
+// GetStudentGuardians retrieves the guardians of a student, emergency contacts first
+func GetStudentGuardians(db *sql.DB, studentID int64) ([]Guardian, error) {
+	query := `
+		SELECT ` + guardianColumns + `
+		FROM guardians g
+		JOIN student_guardians sg ON sg.guardian_id = g.id
+		WHERE sg.student_id = ?
+		ORDER BY g.emergency_contact DESC, g.name
+	`
+	rows, err := db.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guardians := []Guardian{}
+	for rows.Next() {
+		g, err := scanGuardian(rows)
+		if err != nil {
+			return nil, err
+		}
+		guardians = append(guardians, g)
+	}
+	return guardians, rows.Err()
+}
+
+// GetGuardianByID retrieves a guardian by ID
+func GetGuardianByID(db DBTX, id int64) (Guardian, error) {
+	query := "SELECT " + guardianColumns + " FROM guardians g WHERE g.id = ?"
+	return scanGuardian(db.QueryRow(query, id))
+}
+
+// GetStudentGuardian retrieves a guardian of a student; it returns sql.ErrNoRows
+// when the guardian does not exist or is not linked to the student
+func GetStudentGuardian(db DBTX, studentID, guardianID int64) (Guardian, error) {
+	query := `
+		SELECT ` + guardianColumns + `
+		FROM guardians g
+		JOIN student_guardians sg ON sg.guardian_id = g.id
+		WHERE sg.student_id = ? AND g.id = ?
+	`
+	return scanGuardian(db.QueryRow(query, studentID, guardianID))
+}
+
+// CreateGuardian inserts a new guardian and links it to a student
+func CreateGuardian(db DBTX, studentID int64, guardian *Guardian) (int64, error) {
+	var id int64
+	err := inTx(db, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO guardians (name, relationship, phone, email, emergency_contact, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+		`
+		result, err := tx.Exec(query,
+			guardian.Name, guardian.Relationship, guardian.Phone,
+			guardian.Email, guardian.EmergencyContact,
+		)
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO student_guardians (student_id, guardian_id, created_at) VALUES (?, ?, NOW())", studentID, id); err != nil {
+			return err
+		}
+		return nil
+	})
+	return id, err
+}
+
+// GetGuardianClassIDs returns the classes of every student the guardian is linked to, including
+// students in the trash; 0 stands for a student without a class
+func GetGuardianClassIDs(db *sql.DB, guardianID int64) ([]int64, error) {
+	query := `
+		SELECT DISTINCT COALESCE(s.class_id, 0)
+		FROM student_guardians sg
+		JOIN students s ON s.id = sg.student_id
+		WHERE sg.guardian_id = ?
+	`
+	rows, err := db.Query(query, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// LinkGuardian links an existing guardian to a student, e.g. a sibling's parent
+func LinkGuardian(db DBTX, studentID, guardianID int64) error {
+	query := "INSERT IGNORE INTO student_guardians (student_id, guardian_id, created_at) VALUES (?, ?, NOW())"
+	_, err := db.Exec(query, studentID, guardianID)
+	return err
+}
+
+// UpdateGuardian updates a guardian's details; the change is visible from every linked student
+func UpdateGuardian(db DBTX, guardian *Guardian) error {
+	query := `
+		UPDATE guardians
+		SET name = ?, relationship = ?, phone = ?, email = ?, emergency_contact = ?, updated_at = NOW()
+		WHERE id = ?
+	`
+	_, err := db.Exec(query,
+		guardian.Name, guardian.Relationship, guardian.Phone,
+		guardian.Email, guardian.EmergencyContact, guardian.ID,
+	)
+	return err
+}
+
+// UnlinkGuardian removes a guardian from a student and deletes the guardian once no student is left.
+// It returns sql.ErrNoRows when the guardian is not linked to the student.
+func UnlinkGuardian(db DBTX, studentID, guardianID int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM student_guardians WHERE student_id = ? AND guardian_id = ?", studentID, guardianID)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		query := `
+			DELETE FROM guardians
+			WHERE id = ? AND NOT EXISTS (SELECT 1 FROM student_guardians WHERE guardian_id = ?)
+		`
+		if _, err := tx.Exec(query, guardianID, guardianID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// GetGuardianIDByUser retrieves the guardian linked to a parent account; it returns sql.ErrNoRows when there is none
+func GetGuardianIDByUser(db DBTX, userID int64) (int64, error) {
+	var guardianID int64
+	err := db.QueryRow("SELECT id FROM guardians WHERE user_id = ?", userID).Scan(&guardianID)
+	return guardianID, err
+}
+
+// LinkGuardianUser links a parent account to a guardian, replacing the account's previous link.
+// A guardian can be linked to only one account, otherwise ErrGuardianAlreadyLinked is returned.
+func LinkGuardianUser(db DBTX, userID, guardianID int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		var linkedUserID sql.NullInt64
+		if err := tx.QueryRow("SELECT user_id FROM guardians WHERE id = ? FOR UPDATE", guardianID).Scan(&linkedUserID); err != nil {
+			return err
+		}
+		if linkedUserID.Valid && linkedUserID.Int64 != userID {
+			return ErrGuardianAlreadyLinked
+		}
+
+		if _, err := tx.Exec("UPDATE guardians SET user_id = NULL WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE guardians SET user_id = ? WHERE id = ?", userID, guardianID)
+		return err
+	})
+}
+
+// UnlinkGuardianUser removes the link between a parent account and its guardian
+func UnlinkGuardianUser(db DBTX, userID int64) error {
+	_, err := db.Exec("UPDATE guardians SET user_id = NULL WHERE user_id = ?", userID)
+	return err
+}
+
+// GetGuardianChildren retrieves the students linked to a parent account
+func GetGuardianChildren(db *sql.DB, userID int64) ([]Student, error) {
+	query := `
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), s.created_at, s.updated_at
+		FROM students s
+		LEFT JOIN classes c ON s.class_id = c.id
+		JOIN student_guardians sg ON sg.student_id = s.id
+		JOIN guardians g ON g.id = sg.guardian_id
+		WHERE g.user_id = ? AND s.deleted_at IS NULL
+		ORDER BY s.name
+	`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []Student{}
+	for rows.Next() {
+		var s Student
+		err := rows.Scan(
+			&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
+			&s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// IsGuardianOf reports whether a parent account is linked to the given student
+func IsGuardianOf(db *sql.DB, userID, studentID int64) (bool, error) {
+	var ok bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM student_guardians sg
+			JOIN guardians g ON g.id = sg.guardian_id
+			WHERE g.user_id = ? AND sg.student_id = ?
+		)
+	`
+	err := db.QueryRow(query, userID, studentID).Scan(&ok)
+	return ok, err
+}
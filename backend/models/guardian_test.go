@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"student-management/validation"
+	"testing"
+)
+
+func TestGuardianValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		guardian Guardian
+		want     []string // field:code
+	}{
+		{"valid", Guardian{Name: "王芳", Relationship: "母亲", Phone: "13800000000", Email: "wang@example.com", EmergencyContact: true}, nil},
+		{"required", Guardian{Name: " ", Relationship: ""}, []string{"name:" + validation.Required, "relationship:" + validation.Required}},
+		{"too long", Guardian{Name: strings.Repeat("王", 101), Relationship: "母亲", Phone: strings.Repeat("1", 21)}, []string{"name:" + validation.TooLong, "phone:" + validation.TooLong}},
+		{"invalid email", Guardian{Name: "王芳", Relationship: "母亲", Email: "Wang <wang@example.com>"}, []string{"email:" + validation.InvalidFormat}},
+		{"emergency contact without phone", Guardian{Name: "王芳", Relationship: "母亲", EmergencyContact: true}, []string{"phone:" + validation.Required}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, fe := range tt.guardian.Validate() {
+				got = append(got, fe.Field+":"+fe.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Validate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
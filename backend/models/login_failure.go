@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 登录失败记录的统计维度。申请重置密码复用同一张表，按邮箱和 IP 统计申请次数
+const (
+	LoginScopeUser     = "user"
+	LoginScopeIP       = "ip"
+	LoginScopeForgot   = "forgot"
+	LoginScopeForgotIP = "forgot_ip"
+)
+
+// LoginPolicy 配置登录失败后的退避和锁定策略
+type LoginPolicy struct {
+	MaxFailures     int           // 连续失败达到该次数后锁定
+	LockoutDuration time.Duration // 锁定时长
+	BackoffBase     time.Duration // 第 n 次失败后至少等待 BackoffBase * 2^(n-1) 才能再次尝试；为 0 时不退避，只在达到上限后锁定
+	FailureWindow   time.Duration // 超过该时间没有新的失败则重新计数
+}
+
+// LoginFailure 表示某个用户名或 IP 的登录失败情况
+type LoginFailure struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time // 零值表示未锁定
+}
+
+// Check 返回距离下一次允许尝试还需等待的时间；locked 表示处于锁定状态（而不仅是退避）
+func (f LoginFailure) Check(policy LoginPolicy, now time.Time) (wait time.Duration, locked bool) {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now), true
+	}
+	if policy.BackoffBase <= 0 || f.Failures == 0 || !f.LockedUntil.IsZero() || now.Sub(f.LastFailure) > policy.FailureWindow {
+		return 0, false
+	}
+
+	backoff := policy.BackoffBase
+	for i := 1; i < f.Failures && backoff < policy.LockoutDuration; i++ {
+		backoff *= 2
+	}
+	if backoff > policy.LockoutDuration {
+		backoff = policy.LockoutDuration
+	}
+	if next := f.LastFailure.Add(backoff); now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+// GetLoginFailure 获取用户名或 IP 的登录失败记录，没有记录时返回零值
+func GetLoginFailure(db *sql.DB, scope, identifier string) (LoginFailure, error) {
+	var f LoginFailure
+	var lastFailure, lockedUntil int64
+	query := `
+		SELECT failures, UNIX_TIMESTAMP(last_failure_at), COALESCE(UNIX_TIMESTAMP(locked_until), 0)
+		FROM login_failures
+		WHERE scope = ? AND identifier = ?
+	`
+	err := db.QueryRow(query, scope, identifier).Scan(&f.Failures, &lastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return LoginFailure{}, nil
+	} else if err != nil {
+		return f, err
+	}
+
+	f.LastFailure = time.Unix(lastFailure, 0)
+	if lockedUntil > 0 {
+		f.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+	return f, nil
+}
+
+// RecordLoginFailure 记录一次登录失败并按策略决定是否锁定，返回更新后的记录
+func RecordLoginFailure(db *sql.DB, scope, identifier string, policy LoginPolicy) (LoginFailure, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return LoginFailure{}, err
+	}
+	defer tx.Rollback()
+
+	var f LoginFailure
+	var lastFailure, lockedUntil int64
+	query := `
+		SELECT failures, UNIX_TIMESTAMP(last_failure_at), COALESCE(UNIX_TIMESTAMP(locked_until), 0)
+		FROM login_failures
+		WHERE scope = ? AND identifier = ?
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, scope, identifier).Scan(&f.Failures, &lastFailure, &lockedUntil)
+	if err != nil && err != sql.ErrNoRows {
+		return f, err
+	}
+
+	now := time.Now()
+	// 锁定已过期或长时间没有失败时重新计数
+	expiredLock := lockedUntil > 0 && lockedUntil <= now.Unix()
+	staleFailures := now.Sub(time.Unix(lastFailure, 0)) > policy.FailureWindow
+	if err == sql.ErrNoRows || expiredLock || staleFailures {
+		f.Failures = 0
+		lockedUntil = 0
+	}
+
+	f.Failures++
+	f.LastFailure = now
+	if f.Failures >= policy.MaxFailures && lockedUntil == 0 {
+		lockedUntil = now.Add(policy.LockoutDuration).Unix()
+	}
+	if lockedUntil > 0 {
+		f.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+
+	upsert := `
+		INSERT INTO login_failures (scope, identifier, failures, last_failure_at, locked_until)
+		VALUES (?, ?, ?, FROM_UNIXTIME(?), IF(? = 0, NULL, FROM_UNIXTIME(?)))
+		ON DUPLICATE KEY UPDATE failures = VALUES(failures),
+		last_failure_at = VALUES(last_failure_at), locked_until = VALUES(locked_until)
+	`
+	_, err = tx.Exec(upsert, scope, identifier, f.Failures, now.Unix(), lockedUntil, lockedUntil)
+	if err != nil {
+		return f, err
+	}
+	return f, tx.Commit()
+}
+
+// ClearLoginFailures 清除用户名或 IP 的登录失败记录（登录成功或管理员解锁时调用）
+func ClearLoginFailures(db DBTX, scope, identifier string) error {
+	_, err := db.Exec("DELETE FROM login_failures WHERE scope = ? AND identifier = ?", scope, identifier)
+	return err
+}
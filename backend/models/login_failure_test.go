@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailureCheck(t *testing.T) {
+	policy := LoginPolicy{
+		MaxFailures:     5,
+		LockoutDuration: 15 * time.Minute,
+		BackoffBase:     time.Second,
+		FailureWindow:   time.Hour,
+	}
+	now := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		failure    LoginFailure
+		policy     LoginPolicy
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"no failures", LoginFailure{}, policy, 0, false},
+		{"first failure", LoginFailure{Failures: 1, LastFailure: now}, policy, time.Second, false},
+		{"second failure", LoginFailure{Failures: 2, LastFailure: now}, policy, 2 * time.Second, false},
+		{"fourth failure", LoginFailure{Failures: 4, LastFailure: now}, policy, 8 * time.Second, false},
+		{"part of the backoff has passed", LoginFailure{Failures: 4, LastFailure: now.Add(-3 * time.Second)}, policy, 5 * time.Second, false},
+		{"backoff has passed", LoginFailure{Failures: 4, LastFailure: now.Add(-8 * time.Second)}, policy, 0, false},
+		// 2^10 秒已超过锁定时长，退避时间不超过锁定时长
+		{"backoff capped at the lockout", LoginFailure{Failures: 11, LastFailure: now}, policy, 15 * time.Minute, false},
+		{"backoff capped after many failures", LoginFailure{Failures: 200, LastFailure: now.Add(-time.Minute)}, policy, 14 * time.Minute, false},
+		{"failures outside the window", LoginFailure{Failures: 4, LastFailure: now.Add(-time.Hour - time.Second)}, policy, 0, false},
+		{"locked", LoginFailure{Failures: 5, LastFailure: now, LockedUntil: now.Add(15 * time.Minute)}, policy, 15 * time.Minute, true},
+		{"lock about to expire", LoginFailure{Failures: 5, LastFailure: now.Add(-15 * time.Minute), LockedUntil: now.Add(time.Second)}, policy, time.Second, true},
+		// 锁定过期后不再退避，下一次失败重新计数
+		{"lock expired", LoginFailure{Failures: 5, LastFailure: now.Add(-time.Minute), LockedUntil: now.Add(-time.Second)}, policy, 0, false},
+		{"no backoff", LoginFailure{Failures: 19, LastFailure: now}, LoginPolicy{MaxFailures: 20, LockoutDuration: 15 * time.Minute, FailureWindow: time.Hour}, 0, false},
+		{"locked without backoff", LoginFailure{Failures: 20, LastFailure: now, LockedUntil: now.Add(15 * time.Minute)}, LoginPolicy{MaxFailures: 20, LockoutDuration: 15 * time.Minute, FailureWindow: time.Hour}, 15 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, locked := tt.failure.Check(tt.policy, now)
+			if wait != tt.wantWait || locked != tt.wantLocked {
+				t.Errorf("Check = (%v, %v), want (%v, %v)", wait, locked, tt.wantWait, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrOIDCStateInvalid 表示单点登录的 state 不存在、已使用或已过期
+var ErrOIDCStateInvalid = errors.New("models: OIDC state is invalid or expired")
+
+// OIDCState 是发往身份提供方的一次授权请求，回调时据此校验 nonce 并完成 PKCE
+type OIDCState struct {
+	Nonce        string
+	CodeVerifier string
+}
+
+// CreateOIDCState 保存授权请求；state 本身只以哈希形式保存
+func CreateOIDCState(db *sql.DB, stateHash string, state OIDCState, ttl time.Duration) error {
+	query := `
+		INSERT INTO oidc_states (state_hash, nonce, code_verifier, expires_at, created_at)
+		VALUES (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), NOW())
+	`
+	_, err := db.Exec(query, stateHash, state.Nonce, state.CodeVerifier, int64(ttl.Seconds()))
+	return err
+}
+
+// ConsumeOIDCState 取出并删除授权请求，保证每个 state 只能使用一次
+func ConsumeOIDCState(db *sql.DB, stateHash string) (OIDCState, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return OIDCState{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var state OIDCState
+	query := `
+		SELECT id, nonce, code_verifier
+		FROM oidc_states
+		WHERE state_hash = ? AND expires_at > NOW()
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, stateHash).Scan(&id, &state.Nonce, &state.CodeVerifier)
+	if err == sql.ErrNoRows {
+		return OIDCState{}, ErrOIDCStateInvalid
+	} else if err != nil {
+		return OIDCState{}, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM oidc_states WHERE id = ?", id); err != nil {
+		return OIDCState{}, err
+	}
+	return state, tx.Commit()
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"student-management/dbtest"
+	"testing"
+	"time"
+)
+
+// oidcStates 是内存中的 oidc_states 表，只实现 CreateOIDCState 和 ConsumeOIDCState 用到的语句
+type oidcStates struct {
+	nextID int64
+	rows   map[int64]oidcStateRow
+}
+
+type oidcStateRow struct {
+	hash      string
+	state     OIDCState
+	expiresAt time.Time
+}
+
+func (s *oidcStates) handle(query string, args []driver.Value) (*dbtest.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO oidc_states"):
+		s.nextID++
+		s.rows[s.nextID] = oidcStateRow{
+			hash:      args[0].(string),
+			state:     OIDCState{Nonce: args[1].(string), CodeVerifier: args[2].(string)},
+			expiresAt: time.Now().Add(time.Duration(args[3].(int64)) * time.Second),
+		}
+		return &dbtest.Result{LastInsertID: s.nextID, RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "SELECT id, nonce, code_verifier FROM oidc_states"):
+		result := dbtest.Rows([]string{"id", "nonce", "code_verifier"})
+		for id, row := range s.rows {
+			if row.hash == args[0] && row.expiresAt.After(time.Now()) {
+				result.Rows = append(result.Rows, []driver.Value{id, row.state.Nonce, row.state.CodeVerifier})
+			}
+		}
+		return result, nil
+	case strings.HasPrefix(query, "DELETE FROM oidc_states WHERE id = ?"):
+		id := args[0].(int64)
+		if _, ok := s.rows[id]; !ok {
+			return &dbtest.Result{}, nil
+		}
+		delete(s.rows, id)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}
+	return nil, dbtest.Unexpected(query)
+}
+
+func openOIDCStates(t *testing.T) (*sql.DB, *oidcStates) {
+	states := &oidcStates{rows: map[int64]oidcStateRow{}}
+	return dbtest.Open(t, states.handle), states
+}
+
+func TestConsumeOIDCState(t *testing.T) {
+	db, states := openOIDCStates(t)
+	want := OIDCState{Nonce: "nonce", CodeVerifier: "verifier"}
+	if err := CreateOIDCState(db, HashToken("state"), want, time.Minute); err != nil {
+		t.Fatalf("CreateOIDCState: %v", err)
+	}
+
+	// 每个 state 只能使用一次，回放同一个回调会失败
+	got, err := ConsumeOIDCState(db, HashToken("state"))
+	if err != nil {
+		t.Fatalf("ConsumeOIDCState: %v", err)
+	}
+	if got != want {
+		t.Errorf("ConsumeOIDCState = %+v, want %+v", got, want)
+	}
+	if len(states.rows) != 0 {
+		t.Errorf("%d states left after consuming, want 0", len(states.rows))
+	}
+	if _, err := ConsumeOIDCState(db, HashToken("state")); err != ErrOIDCStateInvalid {
+		t.Fatalf("replayed ConsumeOIDCState error = %v, want ErrOIDCStateInvalid", err)
+	}
+}
+
+func TestConsumeOIDCStateInvalid(t *testing.T) {
+	db, _ := openOIDCStates(t)
+	if err := CreateOIDCState(db, HashToken("expired"), OIDCState{Nonce: "nonce"}, -time.Second); err != nil {
+		t.Fatalf("CreateOIDCState: %v", err)
+	}
+
+	for _, state := range []string{"expired", "unknown"} {
+		if _, err := ConsumeOIDCState(db, HashToken(state)); err != ErrOIDCStateInvalid {
+			t.Errorf("ConsumeOIDCState(%q) error = %v, want ErrOIDCStateInvalid", state, err)
+		}
+	}
+}
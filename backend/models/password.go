@@ -0,0 +1,295 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支持的密码哈希算法
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// MaxPasswordBytes 是密码的最大字节数（bcrypt 只使用前 72 个字节）
+const MaxPasswordBytes = 72
+
+// ErrPasswordReused 表示新密码与最近使用过的密码相同
+var ErrPasswordReused = errors.New("models: password was used recently")
+
+// PasswordHashConfig 配置新密码使用的哈希算法和参数；参数低于当前配置的旧哈希会在登录时重新计算
+type PasswordHashConfig struct {
+	Algorithm     string // HashBcrypt 或 HashArgon2id
+	BcryptCost    int
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// PasswordHashing 是当前使用的密码哈希配置，启动时可根据环境变量修改
+var PasswordHashing = PasswordHashConfig{
+	Algorithm:     HashBcrypt,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Memory:  64 * 1024,
+	Argon2Time:    3,
+	Argon2Threads: 2,
+}
+
+// PasswordPolicy 配置设置新密码时的要求
+type PasswordPolicy struct {
+	MinLength   int // 最少字符数
+	MinClasses  int // 至少包含的字符类别数（大写字母、小写字母、数字、其他符号）
+	HistorySize int // 不能与最近 N 个密码相同，0 表示不检查
+}
+
+// PasswordPolicyError 表示密码不符合策略，Problems 为可以直接展示给用户的说明
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "models: password does not meet the policy: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 检查密码是否满足长度和字符类别要求，且不能与用户名相同
+func (p PasswordPolicy) Validate(password, username string) error {
+	var problems []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("密码至少需要 %d 个字符", p.MinLength))
+	}
+	if len(password) > MaxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("密码不能超过 %d 个字节", MaxPasswordBytes))
+	}
+	if classes := passwordClasses(password); classes < p.MinClasses {
+		problems = append(problems, fmt.Sprintf("密码需要包含大写字母、小写字母、数字、符号中的至少 %d 类", p.MinClasses))
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		problems = append(problems, "密码不能与用户名相同")
+	}
+
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
+
+// passwordClasses 统计密码包含的字符类别数
+func passwordClasses(password string) int {
+	var upper, lower, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{upper, lower, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// HashPassword 使用当前配置的算法对密码进行哈希处理
+func HashPassword(password string) (string, error) {
+	if PasswordHashing.Algorithm == HashArgon2id {
+		return hashArgon2id(password, PasswordHashing)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashing.BcryptCost)
+	return string(bytes), err
+}
+
+// CheckPasswordHash 比较密码和哈希值是否匹配，支持 bcrypt（$2a$、$2b$、$2y$）和 argon2id 哈希
+func CheckPasswordHash(password, hash string) bool {
+	switch {
+	case isBcryptHash(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return checkArgon2id(password, hash)
+	default:
+		return false
+	}
+}
+
+// NeedsRehash 判断哈希值是否使用了与当前配置不同的算法或更弱的参数
+func NeedsRehash(hash string) bool {
+	config := PasswordHashing
+	if isBcryptHash(hash) {
+		if config.Algorithm != HashBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < config.BcryptCost
+	}
+
+	params, _, _, err := parseArgon2id(hash)
+	if err != nil || config.Algorithm != HashArgon2id {
+		return true
+	}
+	return params.memory < config.Argon2Memory || params.time < config.Argon2Time || params.threads < config.Argon2Threads
+}
+
+// UpdatePasswordHash 用重新计算的哈希替换旧哈希；若期间密码已被修改则不做任何操作
+func UpdatePasswordHash(db *sql.DB, userID int64, oldHash, newHash string) error {
+	_, err := db.Exec("UPDATE users SET password = ? WHERE id = ? AND password = ?", newHash, userID, oldHash)
+	return err
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// argon2Params 是 argon2id 哈希中编码的参数
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// hashArgon2id 生成 PHC 格式的 argon2id 哈希：$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func hashArgon2id(password string, config PasswordHashConfig) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, config.Argon2Time, config.Argon2Memory, config.Argon2Threads, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, config.Argon2Memory, config.Argon2Time, config.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkArgon2id(password, hash string) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	actual := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("models: invalid argon2id hash")
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return params, nil, nil, errors.New("models: unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, errors.New("models: invalid argon2id parameters")
+	}
+	// Sscanf 不检查多余的内容，这里只接受 hashArgon2id 生成的规范写法
+	canonical := fmt.Sprintf("m=%d,t=%d,p=%d", params.memory, params.time, params.threads)
+	if parts[3] != canonical || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, errors.New("models: invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("models: invalid argon2id key")
+	}
+	return params, salt, key, nil
+}
+
+// setPassword 在事务中按策略校验并设置用户的新密码，被替换的密码记入密码历史
+func setPassword(tx *sql.Tx, userID int64, newPassword string, policy PasswordPolicy) error {
+	var username, currentHash string
+	err := tx.QueryRow("SELECT username, password FROM users WHERE id = ? FOR UPDATE", userID).Scan(&username, &currentHash)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.Validate(newPassword, username); err != nil {
+		return err
+	}
+	if err := checkPasswordHistory(tx, userID, currentHash, newPassword, policy.HistorySize); err != nil {
+		return err
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE users SET password = ?, updated_at = NOW() WHERE id = ?", hashedPassword, userID); err != nil {
+		return err
+	}
+	return recordPasswordHistory(tx, userID, currentHash, policy.HistorySize)
+}
+
+// checkPasswordHistory 检查新密码是否与最近 n 个密码（当前密码和 n-1 个历史密码）相同
+func checkPasswordHistory(tx *sql.Tx, userID int64, currentHash, newPassword string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if CheckPasswordHash(newPassword, currentHash) {
+		return ErrPasswordReused
+	}
+
+	rows, err := tx.Query("SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, n-1)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return err
+		}
+		if CheckPasswordHash(newPassword, hash) {
+			return ErrPasswordReused
+		}
+	}
+	return rows.Err()
+}
+
+// recordPasswordHistory 将被替换的密码哈希记入历史，只保留检查所需的最近 n-1 条
+func recordPasswordHistory(tx *sql.Tx, userID int64, oldHash string, n int) error {
+	keep := n - 1
+	if keep < 0 {
+		keep = 0
+	}
+	if keep > 0 {
+		query := "INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, NOW())"
+		if _, err := tx.Exec(query, userID, oldHash); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM (
+				SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+			) AS recent
+		)
+	`
+	_, err := tx.Exec(query, userID, userID, keep)
+	return err
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrResetTokenInvalid 表示密码重置令牌不存在、已使用或已过期
+var ErrResetTokenInvalid = errors.New("models: password reset token is invalid or expired")
+
+// CreatePasswordResetToken 为用户保存新的密码重置令牌，并使该用户此前未使用的令牌失效
+func CreatePasswordResetToken(db *sql.DB, userID int64, tokenHash string, ttl time.Duration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), NOW())
+	`
+	if _, err := tx.Exec(query, userID, tokenHash, int64(ttl.Seconds())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetPasswordWithToken 按密码策略使用重置令牌设置新密码，令牌随即失效，并吊销该用户的全部会话。
+// 返回令牌所属的用户 ID；新密码不符合策略时令牌仍然有效。
+func ResetPasswordWithToken(db DBTX, tokenHash, newPassword string, policy PasswordPolicy) (int64, error) {
+	var userID int64
+	err := inTx(db, func(tx *sql.Tx) error {
+		var id int64
+		query := `
+			SELECT id, user_id
+			FROM password_reset_tokens
+			WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()
+			FOR UPDATE
+		`
+		err := tx.QueryRow(query, tokenHash).Scan(&id, &userID)
+		if err == sql.ErrNoRows {
+			return ErrResetTokenInvalid
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE id = ?", id); err != nil {
+			return err
+		}
+		if err := setPassword(tx, userID, newPassword, policy); err != nil {
+			return err
+		}
+		if err := revokeUserTokens(tx, userID); err != nil {
+			return err
+		}
+		return nil
+	})
+	return userID, err
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试使用较小的参数，避免每次哈希都占用 64 MiB 内存
+var testArgon2id = PasswordHashConfig{
+	Algorithm:     HashArgon2id,
+	BcryptCost:    bcrypt.MinCost,
+	Argon2Memory:  1024,
+	Argon2Time:    2,
+	Argon2Threads: 2,
+}
+
+func usePasswordHashing(t *testing.T, config PasswordHashConfig) {
+	previous := PasswordHashing
+	PasswordHashing = config
+	t.Cleanup(func() { PasswordHashing = previous })
+}
+
+// argon2idHash 用固定的盐生成 PHC 格式的哈希
+func argon2idHash(password, salt string, memory, time uint32, threads uint8) string {
+	key := argon2.IDKey([]byte(password), []byte(salt), time, memory, threads, 32)
+	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", memory, time, threads,
+		base64.RawStdEncoding.EncodeToString([]byte(salt)), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestHashPasswordArgon2idEncoding(t *testing.T) {
+	usePasswordHashing(t, testArgon2id)
+
+	hash, err := HashPassword("Secret123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 16 字节的盐和 32 字节的密钥，使用不带填充的标准 base64
+	phc := regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=2,p=2\$([A-Za-z0-9+/]{22})\$([A-Za-z0-9+/]{43})$`)
+	match := phc.FindStringSubmatch(hash)
+	if match == nil {
+		t.Fatalf("HashPassword = %q, not a PHC argon2id string", hash)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("Secret123!"), salt, 2, 1024, 2, 32))
+	if match[2] != want {
+		t.Errorf("key = %s, want %s", match[2], want)
+	}
+
+	if !CheckPasswordHash("Secret123!", hash) {
+		t.Error("CheckPasswordHash rejected the correct password")
+	}
+	if CheckPasswordHash("Secret123?", hash) {
+		t.Error("CheckPasswordHash accepted a wrong password")
+	}
+	if again, _ := HashPassword("Secret123!"); again == hash {
+		t.Error("HashPassword reused the salt")
+	}
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	valid := argon2idHash("Secret123!", "somesaltsomesalt", 1024, 2, 2)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("Secret123!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+	with := func(i int, value string) string {
+		changed := append([]string(nil), parts...)
+		changed[i] = value
+		return strings.Join(changed, "$")
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"argon2id", valid, true},
+		{"bcrypt $2a$", string(bcryptHash), true},
+		{"bcrypt $2y$", "$2y$" + strings.TrimPrefix(string(bcryptHash), "$2a$"), true},
+		{"argon2i", with(1, "argon2i"), false},
+		{"other version", with(2, "v=16"), false},
+		{"version with leading zero", with(2, "v=019"), false},
+		{"missing version", strings.Replace(valid, "$v=19", "", 1), false},
+		{"parameters in another order", with(3, "t=2,m=1024,p=2"), false},
+		{"extra parameter", with(3, "m=1024,t=2,p=2,keyid=x"), false},
+		{"leading zero", with(3, "m=01024,t=2,p=2"), false},
+		{"zero time", with(3, "m=1024,t=0,p=2"), false},
+		{"zero threads", with(3, "m=1024,t=2,p=0"), false},
+		{"threads overflow", with(3, "m=1024,t=2,p=258"), false},
+		{"invalid salt", with(4, "not base64!"), false},
+		{"padded key", with(5, parts[5]+"="), false},
+		{"empty key", with(5, ""), false},
+		{"truncated key", with(5, parts[5][:20]), false},
+		{"trailing field", valid + "$", false},
+		{"plain text", "Secret123!", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPasswordHash("Secret123!", tt.hash); got != tt.want {
+				t.Errorf("CheckPasswordHash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash := func(cost int) string {
+		hash, err := bcrypt.GenerateFromPassword([]byte("Secret123!"), cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(hash)
+	}
+	bcryptConfig := testArgon2id
+	bcryptConfig.Algorithm = HashBcrypt
+	bcryptConfig.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name   string
+		config PasswordHashConfig
+		hash   string
+		want   bool
+	}{
+		{"bcrypt at the configured cost", bcryptConfig, bcryptHash(bcrypt.MinCost + 1), false},
+		{"bcrypt above the configured cost", bcryptConfig, bcryptHash(bcrypt.MinCost + 2), false},
+		{"bcrypt below the configured cost", bcryptConfig, bcryptHash(bcrypt.MinCost), true},
+		{"bcrypt when argon2id is configured", testArgon2id, bcryptHash(bcrypt.MinCost + 1), true},
+		{"argon2id with the configured parameters", testArgon2id, argon2idHash("Secret123!", "somesaltsomesalt", 1024, 2, 2), false},
+		{"argon2id with stronger parameters", testArgon2id, argon2idHash("Secret123!", "somesaltsomesalt", 2048, 3, 4), false},
+		{"argon2id with less memory", testArgon2id, argon2idHash("Secret123!", "somesaltsomesalt", 512, 2, 2), true},
+		{"argon2id with fewer passes", testArgon2id, argon2idHash("Secret123!", "somesaltsomesalt", 1024, 1, 2), true},
+		{"argon2id with fewer threads", testArgon2id, argon2idHash("Secret123!", "somesaltsomesalt", 1024, 2, 1), true},
+		{"argon2id when bcrypt is configured", bcryptConfig, argon2idHash("Secret123!", "somesaltsomesalt", 1024, 2, 2), true},
+		{"malformed argon2id", testArgon2id, "$argon2id$v=19$m=1024,t=2,p=2$c2FsdA$", true},
+		{"unknown format", testArgon2id, "plain", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePasswordHashing(t, tt.config)
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinClasses: 3}
+	tests := []struct {
+		password string
+		problems int
+	}{
+		{"Secret123", 0},
+		{"密码Secret1", 0},
+		{"Sec1!", 1},
+		{"secretsecret", 1},
+		{"alicesmith.", 1},
+		{"Alice.Smith1", 1}, // 与用户名相同，不区分大小写
+		{strings.Repeat("Aa1", 25), 1},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, "alice.smith1")
+		problems := 0
+		if policyErr, ok := err.(*PasswordPolicyError); ok {
+			problems = len(policyErr.Problems)
+		} else if err != nil {
+			t.Fatalf("Validate(%q) = %v", tt.password, err)
+		}
+		if problems != tt.problems {
+			t.Errorf("Validate(%q) = %v, want %d problems", tt.password, err, tt.problems)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// 系统定义的权限
+const (
+	PermStudentsRead   = "students:read"
+	PermStudentsWrite  = "students:write"
+	PermStudentsDelete = "students:delete"
+	PermClassesRead    = "classes:read"
+	PermClassesWrite   = "classes:write"
+	PermClassesDelete  = "classes:delete"
+	PermUsersManage    = "users:manage"
+	PermRolesManage    = "roles:manage"
+	PermAPIKeysManage  = "api_keys:manage"
+	PermAuditRead      = "audit:read"
+	PermTrashPurge     = "trash:purge"
+)
+
+// Permission 描述一个可分配给角色的权限
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions 是系统支持的全部权限
+var Permissions = []Permission{
+	{PermStudentsRead, "查看学生"},
+	{PermStudentsWrite, "创建和编辑学生"},
+	{PermStudentsDelete, "删除学生"},
+	{PermClassesRead, "查看班级"},
+	{PermClassesWrite, "创建和编辑班级"},
+	{PermClassesDelete, "删除班级"},
+	{PermUsersManage, "管理用户"},
+	{PermRolesManage, "管理角色和权限"},
+	{PermAPIKeysManage, "管理 API 密钥"},
+	{PermAuditRead, "查看审计日志"},
+	{PermTrashPurge, "永久删除回收站中的学生和班级"},
+}
+
+// 角色相关错误
+var (
+	ErrRoleBuiltin   = errors.New("models: builtin role cannot be deleted")
+	ErrRoleImmutable = errors.New("models: admin, student and parent roles cannot be modified")
+	ErrRoleInUse     = errors.New("models: role is assigned to users")
+)
+
+// Role 表示一个角色及其拥有的权限
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Builtin     bool      `json:"builtin"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,19}$`)
+
+// IsValidRoleName 判断角色名格式是否合法（小写字母开头，最多 20 个字符）
+func IsValidRoleName(name string) bool {
+	return roleNamePattern.MatchString(name)
+}
+
+// IsValidPermission 判断权限名是否为系统定义的权限
+func IsValidPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAllRoles 获取所有角色及其权限
+func GetAllRoles(db *sql.DB) ([]Role, error) {
+	query := `
+		SELECT name, description, builtin, created_at, updated_at
+		FROM roles
+		ORDER BY builtin DESC, name
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role.Name, &role.Description, &role.Builtin, &role.CreatedAt, &role.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		roles[i].Permissions, err = GetRolePermissions(db, roles[i].Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// GetRole 通过名称获取角色及其权限
+func GetRole(db DBTX, name string) (Role, error) {
+	var role Role
+	query := `
+		SELECT name, description, builtin, created_at, updated_at
+		FROM roles
+		WHERE name = ?
+	`
+	err := db.QueryRow(query, name).Scan(&role.Name, &role.Description, &role.Builtin, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return role, err
+	}
+	role.Permissions, err = GetRolePermissions(db, name)
+	return role, err
+}
+
+// RoleExists 判断角色是否存在
+func RoleExists(db DBTX, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM roles WHERE name = ?)", name).Scan(&exists)
+	return exists, err
+}
+
+// IsPortalRole 判断角色是否只能通过 /api/me 访问与本人关联的记录（student 和 parent），这类角色没有任何权限
+func IsPortalRole(role string) bool {
+	return role == RoleStudent || role == RoleParent
+}
+
+// GetRolePermissions 获取角色拥有的权限；admin 角色始终拥有全部权限，student 和 parent 角色没有任何权限
+func GetRolePermissions(db DBTX, role string) ([]string, error) {
+	permissions := []string{}
+	if IsPortalRole(role) {
+		return permissions, nil
+	}
+	if role == RoleAdmin {
+		for _, p := range Permissions {
+			permissions = append(permissions, p.Name)
+		}
+		return permissions, nil
+	}
+
+	rows, err := db.Query("SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission", role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+// RoleHasPermission 判断角色是否拥有指定权限；admin 角色始终拥有全部权限，student 和 parent 角色没有任何权限
+func RoleHasPermission(db *sql.DB, role, permission string) (bool, error) {
+	if role == RoleAdmin {
+		return true, nil
+	}
+	if IsPortalRole(role) {
+		return false, nil
+	}
+
+	var ok bool
+	query := "SELECT EXISTS(SELECT 1 FROM role_permissions WHERE role = ? AND permission = ?)"
+	err := db.QueryRow(query, role, permission).Scan(&ok)
+	return ok, err
+}
+
+// CreateRole 创建新角色及其权限
+func CreateRole(db DBTX, role *Role) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO roles (name, description, builtin, created_at, updated_at)
+			VALUES (?, ?, FALSE, NOW(), NOW())
+		`
+		if _, err := tx.Exec(query, role.Name, role.Description); err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.Name, role.Permissions)
+	})
+}
+
+// UpdateRole 更新角色的描述和权限；admin、student 和 parent 角色的权限是固定的，不可修改
+func UpdateRole(db DBTX, role *Role) error {
+	if role.Name == RoleAdmin || IsPortalRole(role.Name) {
+		return ErrRoleImmutable
+	}
+
+	return inTx(db, func(tx *sql.Tx) error {
+		query := `
+			UPDATE roles
+			SET description = ?, updated_at = NOW()
+			WHERE name = ?
+		`
+		if _, err := tx.Exec(query, role.Description, role.Name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", role.Name); err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.Name, role.Permissions)
+	})
+}
+
+// DeleteRole 删除自定义角色；内置角色或仍有用户使用的角色不能删除
+func DeleteRole(db DBTX, name string) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		var builtin bool
+		if err := tx.QueryRow("SELECT builtin FROM roles WHERE name = ? FOR UPDATE", name).Scan(&builtin); err != nil {
+			return err
+		}
+		if builtin {
+			return ErrRoleBuiltin
+		}
+
+		var inUse bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE role = ?)", name).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return ErrRoleInUse
+		}
+
+		_, err := tx.Exec("DELETE FROM roles WHERE name = ?", name)
+		return err
+	})
+}
+
+func setRolePermissions(tx *sql.Tx, role string, permissions []string) error {
+	for _, p := range permissions {
+		if _, err := tx.Exec("INSERT IGNORE INTO role_permissions (role, permission) VALUES (?, ?)", role, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
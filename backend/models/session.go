@@ -0,0 +1,167 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// maxUserAgentLength 对应 sessions.user_agent 的列宽
+const maxUserAgentLength = 255
+
+// Session 表示一次登录产生的会话，会话在刷新令牌轮换时保持不变
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"` // 根据 User-Agent 推断的浏览器和操作系统
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`   // 登录时间
+	LastSeenAt time.Time `json:"last_seen_at"` // 最近一次刷新令牌的时间
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // 是否为发起请求的会话
+}
+
+// StartSession 为一次成功的登录创建会话及其第一个刷新令牌，返回会话 ID
+func StartSession(db DBTX, userID int64, userAgent, ip, refreshHash string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	sessionID := hex.EncodeToString(buf)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	err := inTx(db, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO sessions (id, user_id, user_agent, ip, last_seen_at, expires_at, created_at)
+			VALUES (?, ?, ?, ?, NOW(), DATE_ADD(NOW(), INTERVAL ? SECOND), NOW())
+		`
+		if _, err := tx.Exec(query, sessionID, userID, userAgent, ip, int64(ttl.Seconds())); err != nil {
+			return err
+		}
+		_, err := insertRefreshToken(tx, userID, sessionID, refreshHash, ttl)
+		return err
+	})
+	return sessionID, err
+}
+
+// GetUserSessions 获取用户当前有效的会话，最近活动的排在前面
+func GetUserSessions(db *sql.DB, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC
+	`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		s.Device = DescribeUserAgent(s.UserAgent)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession 吊销用户的一个会话：会话的刷新令牌立即失效，访问令牌在下一次请求时被拒绝。
+// 会话不存在、不属于该用户或已被吊销时返回 sql.ErrNoRows。
+func RevokeSession(db DBTX, userID int64, sessionID string) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = ? AND revoked_at IS NULL", sessionID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// RevokeOtherSessions 吊销用户除 keepSessionID 以外的全部会话，返回吊销的会话数
+func RevokeOtherSessions(db DBTX, userID int64, keepSessionID string) (int64, error) {
+	var revoked int64
+	err := inTx(db, func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID)
+		if err != nil {
+			return err
+		}
+		revoked, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		query := `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE user_id = ? AND session_id <> ? AND revoked_at IS NULL
+		`
+		if _, err := tx.Exec(query, userID, keepSessionID); err != nil {
+			return err
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+// DescribeUserAgent 从 User-Agent 中粗略识别浏览器和操作系统，例如 "Chrome on Windows"
+func DescribeUserAgent(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	systems := []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser, system := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "未知设备"
+	}
+}
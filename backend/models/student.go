@@ -2,64 +2,98 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 )
 
 // Student represents a student in the system
 type Student struct {
-	ID        int64     `json:"id"`
-	StudentID string    `json:"student_id"` // University/School ID
-	Name      string    `json:"name"`
-	ClassID   int64     `json:"class_id"`
-	ClassName string    `json:"class_name,omitempty"` // Not stored in DB, populated when joining with class
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int64      `json:"id"`
+	StudentID  string     `json:"student_id"` // University/School ID
+	Name       string     `json:"name"`
+	ClassID    int64      `json:"class_id"`
+	ClassName  string     `json:"class_name,omitempty"` // Not stored in DB, populated when joining with class
+	Email      string     `json:"email"`
+	Phone      string     `json:"phone"`
+	Address    string     `json:"address"`
+	Tags       []string   `json:"tags,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set while the student is in the trash
+	RowVersion int64      `json:"-"`                    // Incremented on every change, sent as the ETag
 }
 
-// GetAllStudents retrieves all students with optional filters and pagination
-func GetAllStudents(db *sql.DB, classID int64, studentID, name string, page, pageSize int) ([]Student, int, error) {
-	query := `
-		SELECT s.id, s.student_id, s.name, s.class_id, c.name as class_name, 
-		s.email, s.phone, s.address, s.created_at, s.updated_at
-		FROM students s
-		LEFT JOIN classes c ON s.class_id = c.id
-		WHERE 1=1
-	`
-	countQuery := `SELECT COUNT(*) FROM students WHERE 1=1`
+// ErrVersionConflict is returned by updates and deletes made at a given row version
+// when the record has changed since that version was read
+var ErrVersionConflict = errors.New("record was changed by another request")
+
+// StudentFilter holds the optional filters for listing students
+type StudentFilter struct {
+	ClassID   int64
+	StudentID string  // partial match
+	Name      string  // partial match
+	Tag       string  // exact match
+	ClassIDs  []int64 // when non-nil, only students in these classes are returned (e.g. a teacher's classes)
+}
+
+// where builds the SQL conditions for the filter on the students table aliased as s.
+// Students in the trash are always excluded.
+func (filter StudentFilter) where() (string, []interface{}) {
+	where := " WHERE s.deleted_at IS NULL"
 	params := []interface{}{}
 
-	// Apply filters
-	if classID > 0 {
-		query += " AND s.class_id = ?"
-		countQuery += " AND class_id = ?"
-		params = append(params, classID)
+	if filter.ClassID > 0 {
+		where += " AND s.class_id = ?"
+		params = append(params, filter.ClassID)
+	}
+	if filter.StudentID != "" {
+		where += " AND s.student_id LIKE ?"
+		params = append(params, "%"+filter.StudentID+"%")
+	}
+	if filter.Name != "" {
+		where += " AND s.name LIKE ?"
+		params = append(params, "%"+filter.Name+"%")
 	}
-	if studentID != "" {
-		query += " AND s.student_id LIKE ?"
-		countQuery += " AND student_id LIKE ?"
-		params = append(params, "%"+studentID+"%")
+	if filter.Tag != "" {
+		where += " AND EXISTS (SELECT 1 FROM student_tags t WHERE t.student_id = s.id AND t.tag = ?)"
+		params = append(params, filter.Tag)
 	}
-	if name != "" {
-		query += " AND s.name LIKE ?"
-		countQuery += " AND name LIKE ?"
-		params = append(params, "%"+name+"%")
+	if filter.ClassIDs != nil {
+		if len(filter.ClassIDs) == 0 {
+			where += " AND 1=0"
+		} else {
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(filter.ClassIDs)), ",")
+			where += " AND s.class_id IN (" + placeholders + ")"
+			for _, id := range filter.ClassIDs {
+				params = append(params, id)
+			}
+		}
 	}
+	return where, params
+}
 
-	// Apply pagination
-	query += " ORDER BY s.id DESC LIMIT ? OFFSET ?"
-	offset := (page - 1) * pageSize
-	params = append(params, pageSize, offset)
+// GetAllStudents retrieves all students with optional filters and pagination
+func GetAllStudents(db *sql.DB, filter StudentFilter, page, pageSize int) ([]Student, int, error) {
+	where, params := filter.where()
 
 	// Execute the count query
 	var total int
-	err := db.QueryRow(countQuery, params[:len(params)-2]...).Scan(&total)
+	err := db.QueryRow("SELECT COUNT(*) FROM students s"+where, params...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	// Apply pagination
+	query := `
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), ` + tagsColumn + `, s.created_at, s.updated_at
+		FROM students s
+		LEFT JOIN classes c ON s.class_id = c.id
+	` + where + " ORDER BY s.id DESC LIMIT ? OFFSET ?"
+	offset := (page - 1) * pageSize
+	params = append(params, pageSize, offset)
+
 	// Execute the main query
 	rows, err := db.Query(query, params...)
 	if err != nil {
@@ -70,38 +104,77 @@ func GetAllStudents(db *sql.DB, classID int64, studentID, name string, page, pag
 	var students []Student
 	for rows.Next() {
 		var s Student
+		var tags sql.NullString
 		err := rows.Scan(
 			&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
-			&s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt,
+			&s.Email, &s.Phone, &s.Address, &tags, &s.CreatedAt, &s.UpdatedAt,
 		)
 		if err != nil {
 			return nil, 0, err
 		}
+		s.Tags = splitTags(tags)
 		students = append(students, s)
 	}
 
 	return students, total, nil
 }
 
+// EachStudent calls fn for every student matching the filter, ordered by class and student number,
+// without loading them all into memory (e.g. for exports). It stops at the first error fn returns.
+func EachStudent(db *sql.DB, filter StudentFilter, fn func(Student) error) error {
+	where, params := filter.where()
+	query := `
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), s.created_at, s.updated_at
+		FROM students s
+		LEFT JOIN classes c ON s.class_id = c.id
+	` + where + " ORDER BY c.name, s.student_id"
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Student
+		err := rows.Scan(
+			&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
+			&s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetStudentByID retrieves a student by ID
-func GetStudentByID(db *sql.DB, id int64) (Student, error) {
+func GetStudentByID(db DBTX, id int64) (Student, error) {
 	var student Student
 	query := `
-		SELECT s.id, s.student_id, s.name, s.class_id, c.name as class_name, 
-		s.email, s.phone, s.address, s.created_at, s.updated_at
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), ` + tagsColumn + `, s.created_at, s.updated_at, s.row_version
 		FROM students s
 		LEFT JOIN classes c ON s.class_id = c.id
-		WHERE s.id = ?
+		WHERE s.id = ? AND s.deleted_at IS NULL
 	`
+	var tags sql.NullString
 	err := db.QueryRow(query, id).Scan(
 		&student.ID, &student.StudentID, &student.Name, &student.ClassID, &student.ClassName,
-		&student.Email, &student.Phone, &student.Address, &student.CreatedAt, &student.UpdatedAt,
+		&student.Email, &student.Phone, &student.Address, &tags, &student.CreatedAt, &student.UpdatedAt,
+		&student.RowVersion,
 	)
+	student.Tags = splitTags(tags)
 	return student, err
 }
 
-// CreateStudent inserts a new student into the database
-func CreateStudent(db *sql.DB, student *Student) (int64, error) {
+// CreateStudent inserts a new student into the database. It returns ErrStudentNumberTaken when the
+// student number is already in use.
+func CreateStudent(db DBTX, student *Student) (int64, error) {
 	query := `
 		INSERT INTO students (student_id, name, class_id, email, phone, address, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())
@@ -110,42 +183,142 @@ func CreateStudent(db *sql.DB, student *Student) (int64, error) {
 		student.StudentID, student.Name, student.ClassID,
 		student.Email, student.Phone, student.Address,
 	)
+	if isDuplicateKey(err) {
+		return 0, ErrStudentNumberTaken
+	}
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-// UpdateStudent updates an existing student
-func UpdateStudent(db *sql.DB, student *Student) error {
+// CreateStudents inserts several students in one transaction, so either all of them are created or none.
+// It returns the new IDs in the order of the given students.
+func CreateStudents(db DBTX, students []Student) ([]int64, error) {
+	ids := make([]int64, 0, len(students))
+	err := inTx(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT INTO students (student_id, name, class_id, email, phone, address, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, student := range students {
+			result, err := stmt.Exec(
+				student.StudentID, student.Name, student.ClassID,
+				student.Email, student.Phone, student.Address,
+			)
+			if err != nil {
+				return err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// GetTakenStudentNumbers returns which of the given student numbers (students.student_id) are already in use,
+// including by students in the trash
+func GetTakenStudentNumbers(db *sql.DB, numbers []string) (map[string]bool, error) {
+	taken := map[string]bool{}
+	const batch = 500
+	for start := 0; start < len(numbers); start += batch {
+		end := start + batch
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		chunk := numbers[start:end]
+
+		params := make([]interface{}, len(chunk))
+		for i, n := range chunk {
+			params[i] = n
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		rows, err := db.Query("SELECT student_id FROM students WHERE student_id IN ("+placeholders+")", params...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var n string
+			if err := rows.Scan(&n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			taken[n] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return taken, nil
+}
+
+// UpdateStudent updates an existing student. When student.RowVersion is set, the student is only
+// updated if it is still at that version, and ErrVersionConflict is returned otherwise.
+// ErrStudentNumberTaken is returned when the new student number is already in use.
+func UpdateStudent(db DBTX, student *Student) error {
 	query := `
 		UPDATE students
 		SET student_id = ?, name = ?, class_id = ?, 
 		    email = ?, phone = ?, address = ?, updated_at = NOW()
 		WHERE id = ?
 	`
-	_, err := db.Exec(query,
+	params := []interface{}{
 		student.StudentID, student.Name, student.ClassID,
 		student.Email, student.Phone, student.Address, student.ID,
-	)
+	}
+	err := execAtVersion(db, query, params, student.RowVersion)
+	if isDuplicateKey(err) {
+		return ErrStudentNumberTaken
+	}
 	return err
 }
 
-// DeleteStudent removes a student from the database
-func DeleteStudent(db *sql.DB, id int64) error {
-	query := "DELETE FROM students WHERE id = ?"
-	_, err := db.Exec(query, id)
-	return err
+// DeleteStudent moves a student to the trash; it can be restored until it is purged.
+// A non-zero version makes the delete conditional like in UpdateStudent.
+func DeleteStudent(db DBTX, id, version int64) error {
+	query := "UPDATE students SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+	return execAtVersion(db, query, []interface{}{id}, version)
+}
+
+// execAtVersion runs an UPDATE, adding a row_version condition when version is non-zero
+// and returning ErrVersionConflict when that condition matches no row
+func execAtVersion(db DBTX, query string, params []interface{}, version int64) error {
+	if version == 0 {
+		_, err := db.Exec(query, params...)
+		return err
+	}
+	result, err := db.Exec(query+" AND row_version = ?", append(params, version)...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 // GetStudentsByClassID retrieves all students in a specific class
 func GetStudentsByClassID(db *sql.DB, classID int64) ([]Student, error) {
 	query := `
-		SELECT s.id, s.student_id, s.name, s.class_id, c.name as class_name, 
-		s.email, s.phone, s.address, s.created_at, s.updated_at
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), s.created_at, s.updated_at
 		FROM students s
 		LEFT JOIN classes c ON s.class_id = c.id
-		WHERE s.class_id = ?
+		WHERE s.class_id = ? AND s.deleted_at IS NULL
 		ORDER BY s.name
 	`
 	rows, err := db.Query(query, classID)
@@ -167,4 +340,4 @@ func GetStudentsByClassID(db *sql.DB, classID int64) ([]Student, error) {
 		students = append(students, s)
 	}
 	return students, nil
-} 
\ No newline at end of file
+}
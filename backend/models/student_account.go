@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrStudentAlreadyLinked 表示该学生记录已关联到另一个学生账号
+var ErrStudentAlreadyLinked = errors.New("models: student is already linked to another account")
+
+// GetLinkedStudentID 获取学生账号关联的学生记录 ID；没有关联时返回 sql.ErrNoRows
+func GetLinkedStudentID(db DBTX, userID int64) (int64, error) {
+	var studentID int64
+	err := db.QueryRow("SELECT student_id FROM student_accounts WHERE user_id = ?", userID).Scan(&studentID)
+	return studentID, err
+}
+
+// LinkStudentAccount 将学生账号关联到学生记录，替换该账号原有的关联；
+// 一个学生记录只能关联一个账号，否则返回 ErrStudentAlreadyLinked
+func LinkStudentAccount(db DBTX, userID, studentID int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		var linkedUserID int64
+		err := tx.QueryRow("SELECT user_id FROM student_accounts WHERE student_id = ? FOR UPDATE", studentID).Scan(&linkedUserID)
+		if err == nil && linkedUserID != userID {
+			return ErrStudentAlreadyLinked
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		query := `
+			INSERT INTO student_accounts (user_id, student_id, created_at)
+			VALUES (?, ?, NOW())
+			ON DUPLICATE KEY UPDATE student_id = VALUES(student_id), created_at = NOW()
+		`
+		_, err = tx.Exec(query, userID, studentID)
+		return err
+	})
+}
+
+// UnlinkStudentAccount 解除学生账号与学生记录的关联
+func UnlinkStudentAccount(db DBTX, userID int64) error {
+	_, err := db.Exec("DELETE FROM student_accounts WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// tagsColumn selects a student's tags as one comma-separated string; tags cannot contain commas
+const tagsColumn = "(SELECT GROUP_CONCAT(t.tag ORDER BY t.tag SEPARATOR ',') FROM student_tags t WHERE t.student_id = s.id)"
+
+// splitTags splits the tags selected by tagsColumn
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return nil
+	}
+	return strings.Split(tags.String, ",")
+}
+
+// BulkStudentFields are the fields set by a bulk update; nil fields are left unchanged.
+// The student number and name identify a student, so they cannot be set in bulk.
+type BulkStudentFields struct {
+	ClassID *int64
+	Email   *string
+	Phone   *string
+	Address *string
+}
+
+// Apply returns the student with the fields set
+func (f BulkStudentFields) Apply(s Student) Student {
+	if f.ClassID != nil {
+		s.ClassID = *f.ClassID
+	}
+	if f.Email != nil {
+		s.Email = *f.Email
+	}
+	if f.Phone != nil {
+		s.Phone = *f.Phone
+	}
+	if f.Address != nil {
+		s.Address = *f.Address
+	}
+	return s
+}
+
+// StudentGoneError is returned by the bulk operations when a student is no longer there,
+// e.g. because another request deleted it in the meantime. The whole operation is rolled back.
+type StudentGoneError struct {
+	ID int64
+}
+
+func (e *StudentGoneError) Error() string {
+	return fmt.Sprintf("student %d no longer exists", e.ID)
+}
+
+// GetStudentIDs returns the IDs of at most limit students matching the filter, in ID order
+func GetStudentIDs(db *sql.DB, filter StudentFilter, limit int) ([]int64, error) {
+	where, params := filter.where()
+	rows, err := db.Query("SELECT s.id FROM students s"+where+" ORDER BY s.id LIMIT ?", append(params, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetStudentsByIDs retrieves the students with the given IDs, keyed by ID.
+// Students that do not exist or are in the trash are left out.
+func GetStudentsByIDs(db DBTX, ids []int64) (map[int64]Student, error) {
+	return getStudentsByIDs(db, ids, "")
+}
+
+// LockStudentsByIDs is GetStudentsByIDs inside a transaction, locking the rows until the transaction
+// ends, so what a bulk operation checked cannot change before it is written
+func LockStudentsByIDs(tx *sql.Tx, ids []int64) (map[int64]Student, error) {
+	return getStudentsByIDs(tx, ids, " FOR UPDATE")
+}
+
+func getStudentsByIDs(db DBTX, ids []int64, lock string) (map[int64]Student, error) {
+	students := map[int64]Student{}
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		end := start + batch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		params := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			params[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		query := `
+			SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+			COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''), ` + tagsColumn + `, s.created_at, s.updated_at
+			FROM students s
+			LEFT JOIN classes c ON s.class_id = c.id
+			WHERE s.id IN (` + placeholders + `) AND s.deleted_at IS NULL
+		` + lock
+		rows, err := db.Query(query, params...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var s Student
+			var tags sql.NullString
+			err := rows.Scan(
+				&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
+				&s.Email, &s.Phone, &s.Address, &tags, &s.CreatedAt, &s.UpdatedAt,
+			)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			s.Tags = splitTags(tags)
+			students[s.ID] = s
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return students, nil
+}
+
+// BulkUpdateStudents sets the given fields on every student in one transaction
+func BulkUpdateStudents(db DBTX, ids []int64, fields BulkStudentFields) error {
+	var set []string
+	var values []interface{}
+	if fields.ClassID != nil {
+		set, values = append(set, "class_id = ?"), append(values, *fields.ClassID)
+	}
+	if fields.Email != nil {
+		set, values = append(set, "email = ?"), append(values, *fields.Email)
+	}
+	if fields.Phone != nil {
+		set, values = append(set, "phone = ?"), append(values, *fields.Phone)
+	}
+	if fields.Address != nil {
+		set, values = append(set, "address = ?"), append(values, *fields.Address)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	query := "UPDATE students SET " + strings.Join(set, ", ") + ", updated_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+	return execEachInTx(db, ids, query, func(id int64) []interface{} {
+		return append(append([]interface{}{}, values...), id)
+	})
+}
+
+// BulkDeleteStudents moves every student to the trash in one transaction
+func BulkDeleteStudents(db DBTX, ids []int64) error {
+	query := "UPDATE students SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+	return execEachInTx(db, ids, query, func(id int64) []interface{} {
+		return []interface{}{id}
+	})
+}
+
+// AddStudentTags adds the tags to every student in one transaction; tags a student already has are kept
+func AddStudentTags(db DBTX, ids []int64, tags []string) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT IGNORE INTO student_tags (student_id, tag)
+			SELECT id, ? FROM students WHERE id = ? AND deleted_at IS NULL
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, id := range ids {
+			for _, tag := range tags {
+				if _, err := stmt.Exec(tag, id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveStudentTags removes the tags from every student in one transaction
+func RemoveStudentTags(db DBTX, ids []int64, tags []string) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tags)), ",")
+	query := "DELETE FROM student_tags WHERE student_id = ? AND tag IN (" + placeholders + ")"
+	return inTx(db, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			params := []interface{}{id}
+			for _, tag := range tags {
+				params = append(params, tag)
+			}
+			if _, err := tx.Exec(query, params...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// execEachInTx runs the query once per ID in one transaction, rolling back with a StudentGoneError
+// when it affects no row
+func execEachInTx(db DBTX, ids []int64, query string, args func(id int64) []interface{}) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, id := range ids {
+			result, err := stmt.Exec(args(id)...)
+			if err != nil {
+				return err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return &StudentGoneError{ID: id}
+			}
+		}
+		return nil
+	})
+}
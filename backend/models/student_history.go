@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Operations recorded in the student history
+const (
+	StudentVersionCreate  = "create"
+	StudentVersionUpdate  = "update"
+	StudentVersionDelete  = "delete"  // Moved to the trash
+	StudentVersionRestore = "restore" // Restored from the trash
+	StudentVersionPurge   = "purge"   // Permanently deleted
+)
+
+// StudentVersion is a snapshot of a student record saved by the database triggers on every change
+type StudentVersion struct {
+	Version   int           `json:"version"`
+	Operation string        `json:"operation"`
+	ChangedAt time.Time     `json:"changed_at"`
+	Student   Student       `json:"student"`
+	Changes   []FieldChange `json:"changes"` // Fields that differ from the previous version
+}
+
+// FieldChange describes a single field changed between two versions
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// GetStudentHistory retrieves all versions of a student, newest first, with the per-field changes of each version.
+// Versions outlive the student, so the history of a deleted student is still available.
+func GetStudentHistory(db *sql.DB, studentID int64) ([]StudentVersion, error) {
+	query := `
+		SELECT v.version, v.operation, v.changed_at, v.student_id, v.student_number, v.name,
+		COALESCE(v.class_id, 0), COALESCE(c.name, ''), COALESCE(v.email, ''), COALESCE(v.phone, ''),
+		COALESCE(v.address, '')
+		FROM student_versions v
+		LEFT JOIN classes c ON v.class_id = c.id
+		WHERE v.student_id = ?
+		ORDER BY v.version
+	`
+	rows, err := db.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []StudentVersion
+	for rows.Next() {
+		var v StudentVersion
+		s := &v.Student
+		err := rows.Scan(
+			&v.Version, &v.Operation, &v.ChangedAt, &s.ID, &s.StudentID, &s.Name,
+			&s.ClassID, &s.ClassName, &s.Email, &s.Phone, &s.Address,
+		)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fill in the timestamps and diffs in version order, then return the newest version first
+	for i := range versions {
+		v := &versions[i]
+		v.Student.CreatedAt = versions[0].ChangedAt
+		v.Student.UpdatedAt = v.ChangedAt
+		if i == 0 {
+			v.Changes = diffStudents(Student{}, v.Student)
+		} else {
+			v.Changes = diffStudents(versions[i-1].Student, v.Student)
+		}
+	}
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	return versions, nil
+}
+
+// StudentVersionAt returns the version that was current at time t from a history ordered newest first,
+// or false when the student did not exist yet
+func StudentVersionAt(history []StudentVersion, t time.Time) (StudentVersion, bool) {
+	for _, v := range history {
+		if v.ChangedAt.Before(t) {
+			return v, true
+		}
+	}
+	return StudentVersion{}, false
+}
+
+// Deleted reports whether the student was deleted (in the trash or purged) in this version
+func (v StudentVersion) Deleted() bool {
+	return v.Operation == StudentVersionDelete || v.Operation == StudentVersionPurge
+}
+
+// diffStudents lists the stored fields that differ between two snapshots of a student
+func diffStudents(old, new Student) []FieldChange {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"student_id", old.StudentID, new.StudentID},
+		{"name", old.Name, new.Name},
+		{"class_id", old.ClassID, new.ClassID},
+		{"email", old.Email, new.Email},
+		{"phone", old.Phone, new.Phone},
+		{"address", old.Address, new.Address},
+	}
+
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"student-management/validation"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Maximum lengths of the student fields in characters, from the students table
+const (
+	MaxStudentNumberLength  = 20
+	MaxStudentNameLength    = 100
+	MaxStudentEmailLength   = 100
+	MaxStudentPhoneLength   = 20
+	MaxStudentAddressLength = 500 // address is TEXT, the limit keeps it to a postal address
+)
+
+var (
+	studentNumberPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	phonePattern         = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+)
+
+// ErrStudentNumberTaken is returned when a student is created or updated with a student number
+// another student already has, including one in the trash
+var ErrStudentNumberTaken = errors.New("student number is already in use")
+
+// Validate checks the format and length of the student's fields, without looking at the database
+func (s Student) Validate() validation.Errors {
+	var errs validation.Errors
+	if errs.Required("student_id", s.StudentID, "Student number is required") {
+		if errs.MaxLength("student_id", s.StudentID, MaxStudentNumberLength,
+			fmt.Sprintf("Student number cannot be longer than %d characters", MaxStudentNumberLength)) {
+			errs.Match("student_id", s.StudentID, studentNumberPattern,
+				"Student number can only contain letters, digits, hyphens and underscores")
+		}
+	}
+	if errs.Required("name", s.Name, "Name is required") {
+		errs.MaxLength("name", s.Name, MaxStudentNameLength,
+			fmt.Sprintf("Name cannot be longer than %d characters", MaxStudentNameLength))
+	}
+	if s.ClassID <= 0 {
+		errs.Add("class_id", validation.Required, "Class is required")
+	}
+	if errs.MaxLength("email", s.Email, MaxStudentEmailLength,
+		fmt.Sprintf("Email cannot be longer than %d characters", MaxStudentEmailLength)) {
+		errs.Email("email", s.Email, "Invalid email address")
+	}
+	if errs.MaxLength("phone", s.Phone, MaxStudentPhoneLength,
+		fmt.Sprintf("Phone cannot be longer than %d characters", MaxStudentPhoneLength)) {
+		errs.Match("phone", s.Phone, phonePattern, "Phone can only contain digits, spaces, hyphens, parentheses and a leading +")
+	}
+	errs.MaxLength("address", s.Address, MaxStudentAddressLength,
+		fmt.Sprintf("Address cannot be longer than %d characters", MaxStudentAddressLength))
+	return errs
+}
+
+// ValidateStudentReferences checks the fields of a student that refer to other records: the class
+// must exist and not be in the trash, and no other student may have the same student number.
+// Fields that already failed Validate are skipped, and when fields are given only those are checked;
+// errs is returned with the new problems added.
+func ValidateStudentReferences(db *sql.DB, s Student, errs validation.Errors, fields ...string) (validation.Errors, error) {
+	check := func(field string) bool {
+		if errs.Has(field) {
+			return false
+		}
+		if len(fields) == 0 {
+			return true
+		}
+		for _, f := range fields {
+			if f == field {
+				return true
+			}
+		}
+		return false
+	}
+	if check("class_id") {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM classes WHERE id = ? AND deleted_at IS NULL", s.ClassID).Scan(&count)
+		if err != nil {
+			return errs, err
+		}
+		if count == 0 {
+			errs.Add("class_id", validation.NotFound, "Class not found")
+		}
+	}
+	if check("student_id") {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM students WHERE student_id = ? AND id <> ?", s.StudentID, s.ID).Scan(&count)
+		if err != nil {
+			return errs, err
+		}
+		if count > 0 {
+			errs.Add("student_id", validation.Taken, "Student number is already in use")
+		}
+	}
+	return errs, nil
+}
+
+// isDuplicateKey reports whether err is a MySQL duplicate key error
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// GetTeacherClassIDs 获取分配给教师的班级 ID 列表
+func GetTeacherClassIDs(db DBTX, userID int64) ([]int64, error) {
+	rows, err := db.Query("SELECT class_id FROM teacher_classes WHERE user_id = ? ORDER BY class_id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		classIDs = append(classIDs, id)
+	}
+	return classIDs, rows.Err()
+}
+
+// SetTeacherClasses 用给定的班级列表替换教师当前的班级分配
+func SetTeacherClasses(db DBTX, userID int64, classIDs []int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM teacher_classes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		for _, classID := range classIDs {
+			query := "INSERT IGNORE INTO teacher_classes (user_id, class_id, created_at) VALUES (?, ?, NOW())"
+			if _, err := tx.Exec(query, userID, classID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// 刷新令牌相关错误
+var (
+	ErrRefreshTokenInvalid = errors.New("models: refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("models: revoked refresh token was reused")
+)
+
+// NewOpaqueToken 生成一个随机的不透明令牌，返回明文（交给客户端）和哈希值（存入数据库）
+func NewOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken 计算令牌的 SHA-256 哈希（十六进制）
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RotateRefreshToken 用新的刷新令牌替换旧令牌，返回令牌所属的用户 ID 和会话 ID，并更新会话的最近活动时间和 IP。
+// 如果旧令牌已被吊销（说明可能被盗用），会吊销该用户的全部会话并返回 ErrRefreshTokenReused；
+// 会话已被吊销（退出登录或在其他设备上被移除）时只返回 ErrRefreshTokenInvalid。
+func RotateRefreshToken(db *sql.DB, oldHash, newHash string, ttl time.Duration, ip string) (int64, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var id, userID int64
+	var sessionID string
+	var revoked, expired, sessionRevoked bool
+	query := `
+		SELECT rt.id, rt.user_id, rt.session_id, rt.revoked_at IS NOT NULL, rt.expires_at <= NOW(), s.revoked_at IS NOT NULL
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = ?
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, oldHash).Scan(&id, &userID, &sessionID, &revoked, &expired, &sessionRevoked)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrRefreshTokenInvalid
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	if sessionRevoked {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if revoked {
+		if err := revokeUserTokens(tx, userID); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return 0, "", ErrRefreshTokenReused
+	}
+	if expired {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	newID, err := insertRefreshToken(tx, userID, sessionID, newHash, ttl)
+	if err != nil {
+		return 0, "", err
+	}
+	_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = ? WHERE id = ?", newID, id)
+	if err != nil {
+		return 0, "", err
+	}
+	query = `
+		UPDATE sessions
+		SET ip = ?, last_seen_at = NOW(), expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		WHERE id = ?
+	`
+	if _, err := tx.Exec(query, ip, int64(ttl.Seconds()), sessionID); err != nil {
+		return 0, "", err
+	}
+	return userID, sessionID, tx.Commit()
+}
+
+// RevokeRefreshToken 吊销指定的刷新令牌（仅限属于该用户的令牌）
+func RevokeRefreshToken(db DBTX, userID int64, tokenHash string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE token_hash = ? AND user_id = ? AND revoked_at IS NULL
+	`
+	_, err := db.Exec(query, tokenHash, userID)
+	return err
+}
+
+// RevokeAccessToken 将访问令牌的 JWT ID 加入吊销列表，expiresAt 为令牌本身的过期时间（Unix 秒）
+func RevokeAccessToken(db DBTX, jti string, userID int64, expiresAt int64) error {
+	query := `
+		INSERT IGNORE INTO revoked_tokens (jti, user_id, expires_at, created_at)
+		VALUES (?, ?, FROM_UNIXTIME(?), NOW())
+	`
+	_, err := db.Exec(query, jti, userID, expiresAt)
+	return err
+}
+
+// RevokeUserTokens 吊销用户的全部会话：此前签发的访问令牌和所有刷新令牌都会失效
+func RevokeUserTokens(db DBTX, userID int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		return revokeUserTokens(tx, userID)
+	})
+}
+
+// IsTokenRevoked 判断访问令牌是否已失效：令牌被单独吊销、所属会话已被吊销或不存在、
+// 签发时间早于用户的吊销时间点、用户被禁用或已不存在都视为失效
+func IsTokenRevoked(db *sql.DB, jti, sessionID string, userID int64, issuedAt int64) (bool, error) {
+	var disabled, blacklisted, sessionRevoked bool
+	var revokedBefore int64
+	query := `
+		SELECT u.disabled,
+		COALESCE(UNIX_TIMESTAMP(u.tokens_revoked_at), 0),
+		EXISTS(SELECT 1 FROM revoked_tokens rt WHERE rt.jti = ?),
+		? <> '' AND NOT EXISTS(SELECT 1 FROM sessions s WHERE s.id = ? AND s.user_id = u.id AND s.revoked_at IS NULL)
+		FROM users u
+		WHERE u.id = ?
+	`
+	err := db.QueryRow(query, jti, sessionID, sessionID, userID).Scan(&disabled, &revokedBefore, &blacklisted, &sessionRevoked)
+	if err == sql.ErrNoRows {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return disabled || blacklisted || sessionRevoked || issuedAt < revokedBefore, nil
+}
+
+// DeleteExpiredTokens 清理已过期的刷新令牌、会话、吊销记录、密码重置令牌、登录挑战和单点登录状态
+func DeleteExpiredTokens(db *sql.DB) error {
+	tables := []string{"refresh_tokens", "sessions", "revoked_tokens", "password_reset_tokens", "mfa_challenges", "oidc_states"}
+	for _, table := range tables {
+		if _, err := db.Exec("DELETE FROM " + table + " WHERE expires_at <= NOW()"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execer 是 *sql.DB 和 *sql.Tx 的公共接口
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertRefreshToken(db execer, userID int64, sessionID, tokenHash string, ttl time.Duration) (int64, error) {
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), NOW())
+	`
+	result, err := db.Exec(query, userID, sessionID, tokenHash, int64(ttl.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// revokeUserTokens 使用应用服务器的时钟记录吊销时间点，与 JWT 的签发时间保持同一时间基准
+func revokeUserTokens(tx *sql.Tx, userID int64) error {
+	_, err := tx.Exec("UPDATE users SET tokens_revoked_at = FROM_UNIXTIME(?) WHERE id = ?", time.Now().Unix(), userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL", userID)
+	return err
+}
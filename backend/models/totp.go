@@ -0,0 +1,215 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 两步验证相关错误
+var (
+	ErrTOTPAlreadyEnabled = errors.New("models: two-factor authentication is already enabled")
+	ErrTOTPNotPending     = errors.New("models: no pending two-factor secret")
+	ErrMFAChallenge       = errors.New("models: MFA challenge is invalid or expired")
+)
+
+// MaxMFAChallengeAttempts 是单个登录挑战允许的最大验证次数
+const MaxMFAChallengeAttempts = 5
+
+// TOTPState 表示用户的两步验证状态
+type TOTPState struct {
+	Secret   string // 未启用时为待验证的密钥
+	Enabled  bool
+	LastStep int64
+}
+
+// GetTOTPState 获取用户的两步验证状态
+func GetTOTPState(db *sql.DB, userID int64) (TOTPState, error) {
+	var state TOTPState
+	var secret sql.NullString
+	query := "SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?"
+	err := db.QueryRow(query, userID).Scan(&secret, &state.Enabled, &state.LastStep)
+	state.Secret = secret.String
+	return state, err
+}
+
+// SetPendingTOTPSecret 为尚未启用两步验证的用户保存待验证的密钥
+func SetPendingTOTPSecret(db *sql.DB, userID int64, secret string) error {
+	result, err := db.Exec("UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled = FALSE", secret, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP 在首次验证成功后启用两步验证，并保存恢复码的哈希
+func EnableTOTP(db DBTX, userID int64, step int64, recoveryCodeHashes []string) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET totp_enabled = TRUE, totp_last_step = ?
+			WHERE id = ? AND totp_enabled = FALSE AND totp_secret IS NOT NULL
+		`
+		result, err := tx.Exec(query, step, userID)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return ErrTOTPNotPending
+		}
+
+		if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// DisableTOTP 关闭两步验证，清除密钥和恢复码
+func DisableTOTP(db DBTX, userID int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		query := "UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?"
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep 记录已使用的验证码时间步；若该时间步不晚于上次使用的时间步（验证码重放）则返回 false
+func UseTOTPStep(db *sql.DB, userID int64, step int64) (bool, error) {
+	result, err := db.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ReplaceRecoveryCodes 用新的恢复码替换用户现有的全部恢复码
+func ReplaceRecoveryCodes(db DBTX, userID int64, codeHashes []string) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode 使用一个恢复码，成功返回 true；每个恢复码只能使用一次
+func UseRecoveryCode(db *sql.DB, userID int64, code string) (bool, error) {
+	query := `
+		UPDATE totp_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+		LIMIT 1
+	`
+	result, err := db.Exec(query, userID, HashToken(NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// NewRecoveryCodes 生成 n 个恢复码，返回明文（展示给用户一次）和哈希值（存入数据库）
+func NewRecoveryCodes(n int) ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// NormalizeRecoveryCode 去掉恢复码中的分隔符和空白并转为小写
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		query := "INSERT INTO totp_recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, NOW())"
+		if _, err := tx.Exec(query, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateMFAChallenge 在密码验证通过后创建登录挑战，ttl 为有效期
+func CreateMFAChallenge(db *sql.DB, userID int64, tokenHash string, ttl time.Duration) error {
+	query := `
+		INSERT INTO mfa_challenges (user_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), NOW())
+	`
+	_, err := db.Exec(query, userID, tokenHash, int64(ttl.Seconds()))
+	return err
+}
+
+// GetMFAChallengeUser 返回有效登录挑战所属的用户 ID，并计入一次验证尝试
+func GetMFAChallengeUser(db *sql.DB, tokenHash string) (int64, error) {
+	query := `
+		UPDATE mfa_challenges
+		SET attempts = attempts + 1
+		WHERE token_hash = ? AND expires_at > NOW() AND attempts < ?
+	`
+	result, err := db.Exec(query, tokenHash, MaxMFAChallengeAttempts)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if affected == 0 {
+		return 0, ErrMFAChallenge
+	}
+
+	var userID int64
+	err = db.QueryRow("SELECT user_id FROM mfa_challenges WHERE token_hash = ?", tokenHash).Scan(&userID)
+	return userID, err
+}
+
+// PeekMFAChallengeUser 返回有效登录挑战所属的用户 ID，不计入验证尝试
+func PeekMFAChallengeUser(db *sql.DB, tokenHash string) (int64, error) {
+	var userID int64
+	query := `
+		SELECT user_id FROM mfa_challenges
+		WHERE token_hash = ? AND expires_at > NOW() AND attempts < ?
+	`
+	err := db.QueryRow(query, tokenHash, MaxMFAChallengeAttempts).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrMFAChallenge
+	}
+	return userID, err
+}
+
+// DeleteMFAChallenge 删除已完成的登录挑战
+func DeleteMFAChallenge(db *sql.DB, tokenHash string) error {
+	_, err := db.Exec("DELETE FROM mfa_challenges WHERE token_hash = ?", tokenHash)
+	return err
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultTrashRetention is how long deleted students and classes are kept before they are purged automatically
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// GetDeletedStudents retrieves the students in the trash, most recently deleted first.
+// When classIDs is non-nil, only students in these classes are returned (e.g. a teacher's classes).
+func GetDeletedStudents(db *sql.DB, classIDs []int64) ([]Student, error) {
+	query := `
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''),
+		s.created_at, s.updated_at, s.deleted_at
+		FROM students s
+		LEFT JOIN classes c ON s.class_id = c.id
+		WHERE s.deleted_at IS NOT NULL
+	`
+	params := []interface{}{}
+	if classIDs != nil {
+		if len(classIDs) == 0 {
+			return []Student{}, nil
+		}
+		query += " AND s.class_id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(classIDs)), ",") + ")"
+		for _, id := range classIDs {
+			params = append(params, id)
+		}
+	}
+	query += " ORDER BY s.deleted_at DESC, s.id DESC"
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []Student{}
+	for rows.Next() {
+		var s Student
+		err := rows.Scan(
+			&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
+			&s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// GetDeletedStudentByID retrieves a student in the trash by ID
+func GetDeletedStudentByID(db DBTX, id int64) (Student, error) {
+	var s Student
+	query := `
+		SELECT s.id, s.student_id, s.name, COALESCE(s.class_id, 0), COALESCE(c.name, ''),
+		COALESCE(s.email, ''), COALESCE(s.phone, ''), COALESCE(s.address, ''),
+		s.created_at, s.updated_at, s.deleted_at
+		FROM students s
+		LEFT JOIN classes c ON s.class_id = c.id
+		WHERE s.id = ? AND s.deleted_at IS NOT NULL
+	`
+	err := db.QueryRow(query, id).Scan(
+		&s.ID, &s.StudentID, &s.Name, &s.ClassID, &s.ClassName,
+		&s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt,
+	)
+	return s, err
+}
+
+// RestoreStudent takes a student out of the trash
+func RestoreStudent(db DBTX, id int64) error {
+	return execOne(db, "UPDATE students SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// PurgeStudent permanently removes a student that is in the trash
+func PurgeStudent(db DBTX, id int64) error {
+	return execOne(db, "DELETE FROM students WHERE id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// GetDeletedClasses retrieves the classes in the trash, most recently deleted first.
+// When classIDs is non-nil, only these classes are returned.
+func GetDeletedClasses(db *sql.DB, classIDs []int64) ([]Class, error) {
+	query := `
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id) as student_count, c.deleted_at
+		FROM classes c
+		WHERE c.deleted_at IS NOT NULL
+	`
+	params := []interface{}{}
+	if classIDs != nil {
+		if len(classIDs) == 0 {
+			return []Class{}, nil
+		}
+		query += " AND c.id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(classIDs)), ",") + ")"
+		for _, id := range classIDs {
+			params = append(params, id)
+		}
+	}
+	query += " ORDER BY c.deleted_at DESC, c.id DESC"
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []Class{}
+	for rows.Next() {
+		var c Class
+		err := rows.Scan(
+			&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.StudentCount, &c.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, c)
+	}
+	return classes, rows.Err()
+}
+
+// GetDeletedClassByID retrieves a class in the trash by ID
+func GetDeletedClassByID(db DBTX, id int64) (Class, error) {
+	var c Class
+	query := `
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM students s WHERE s.class_id = c.id) as student_count, c.deleted_at
+		FROM classes c
+		WHERE c.id = ? AND c.deleted_at IS NOT NULL
+	`
+	err := db.QueryRow(query, id).Scan(
+		&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.StudentCount, &c.DeletedAt,
+	)
+	return c, err
+}
+
+// RestoreClass takes a class out of the trash
+func RestoreClass(db DBTX, id int64) error {
+	return execOne(db, "UPDATE classes SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// ErrClassHasStudents is returned by PurgeClass while students, which can only be in the trash, still belong to the class
+var ErrClassHasStudents = errors.New("models: class still has students in the trash")
+
+// unreferencedClass restricts a DELETE FROM classes to classes no student points at. Purging such a class
+// would set the students' class_id to NULL through the foreign key, leaving them without a class.
+const unreferencedClass = " AND NOT EXISTS (SELECT 1 FROM students s WHERE s.class_id = classes.id)"
+
+// PurgeClass permanently removes a class that is in the trash. It returns ErrClassHasStudents
+// while students in the trash still belong to it; they have to be restored or purged first.
+func PurgeClass(db DBTX, id int64) error {
+	err := execOne(db, "DELETE FROM classes WHERE id = ? AND deleted_at IS NOT NULL"+unreferencedClass, id)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	var referenced bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM students WHERE class_id = ?)", id).Scan(&referenced); err != nil {
+		return err
+	}
+	if referenced {
+		return ErrClassHasStudents
+	}
+	return sql.ErrNoRows
+}
+
+// PurgeExpiredTrash permanently removes the students and classes deleted before the given time and returns them.
+// Students are purged first; a class is kept while a student still belongs to it.
+func PurgeExpiredTrash(db DBTX, before time.Time) (students []Student, classes []Class, err error) {
+	err = inTx(db, func(tx *sql.Tx) error {
+		ids, err := queryIDs(tx, "SELECT id FROM students WHERE deleted_at IS NOT NULL AND deleted_at < ? FOR UPDATE", before)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			s, err := GetDeletedStudentByID(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := PurgeStudent(tx, id); err != nil {
+				return err
+			}
+			students = append(students, s)
+		}
+
+		query := "SELECT id FROM classes WHERE deleted_at IS NOT NULL AND deleted_at < ?" + unreferencedClass + " FOR UPDATE"
+		if ids, err = queryIDs(tx, query, before); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			c, err := GetDeletedClassByID(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := PurgeClass(tx, id); err != nil {
+				return err
+			}
+			classes = append(classes, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return students, classes, nil
+}
+
+// queryIDs runs a query that selects a single ID column
+func queryIDs(db DBTX, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// execOne executes a statement that should affect exactly one row, returning sql.ErrNoRows when it affected none
+func execOne(db DBTX, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
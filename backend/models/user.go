@@ -2,92 +2,107 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"time"
-	"golang.org/x/crypto/bcrypt"
-	"log"
-	"strings"
 )
 
+// 系统内置角色，其余角色由管理员在 roles 表中定义
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleTeacher = "teacher" // 只能访问 teacher_classes 中分配的班级
+	RoleStudent = "student" // 只能通过 /api/me/student 访问 student_accounts 中关联的学生记录
+	RoleParent  = "parent"  // 只能通过 /api/me/children 查看 guardians 中关联的子女
+)
+
+// 用户的认证来源：本地账号使用 users 表中的密码，目录账号由外部目录验证密码
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+	AuthSourceOIDC  = "oidc"
+)
+
+// ErrLastAdmin 表示操作会导致系统中不再有可用的管理员
+var ErrLastAdmin = errors.New("models: operation would remove the last admin")
+
 // User 表示系统中的用户
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // 密码永远不会在 JSON 中暴露
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Password    string    `json:"-"` // 密码永远不会在 JSON 中暴露
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	AuthSource  string    `json:"auth_source"` // AuthSourceLocal 或外部目录（如 AuthSourceLDAP）
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // UserProfile 是用户信息的简化版本，不包含密码
 type UserProfile struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
-}
-
-// HashPassword 使用 bcrypt 对密码进行哈希处理
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
-}
-
-// CheckPasswordHash 比较密码和哈希值是否匹配
-func CheckPasswordHash(password, hash string) bool {
-	log.Printf("正在验证密码哈希")
-	log.Printf("输入的密码: %s", password)
-	log.Printf("存储的哈希值: %s", hash)
-	log.Printf("哈希值长度: %d", len(hash))
-	log.Printf("密码长度: %d", len(password))
-	
-	// 检查哈希值是否以正确的前缀开头
-	if !strings.HasPrefix(hash, "$2a$") {
-		log.Printf("无效的哈希格式：不以 $2a$ 开头")
-		return false
-	}
-	
-	// 如果存在重复的 $2a$ 前缀，则移除
-	if strings.HasPrefix(hash, "$2a$2a$") {
-		hash = strings.TrimPrefix(hash, "$2a$")
-		log.Printf("修复重复前缀，新的哈希值: %s", hash)
-	}
-	
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if err != nil {
-		log.Printf("密码验证失败: %v", err)
-		return false
-	}
-	log.Printf("密码验证成功")
-	return true
+	ID          int64    `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"` // 角色拥有的权限，供前端控制界面显示
+	TOTPEnabled bool     `json:"totp_enabled"`
 }
 
 // GetUserByUsername 通过用户名获取用户信息
 func GetUserByUsername(db *sql.DB, username string) (User, error) {
 	var user User
 	query := `
-		SELECT id, username, password, email, role, created_at, updated_at
+		SELECT id, username, password, email, role, disabled, totp_enabled, auth_source, created_at, updated_at
 		FROM users
 		WHERE username = ?
 	`
 	err := db.QueryRow(query, username).Scan(
 		&user.ID, &user.Username, &user.Password, &user.Email, 
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.Disabled, &user.TOTPEnabled, &user.AuthSource, &user.CreatedAt, &user.UpdatedAt,
 	)
 	return user, err
 }
 
+// GetUsersByEmail 获取使用该邮箱的所有用户（邮箱不要求唯一）
+func GetUsersByEmail(db *sql.DB, email string) ([]User, error) {
+	query := `
+		SELECT id, username, password, email, role, disabled, totp_enabled, auth_source, created_at, updated_at
+		FROM users
+		WHERE email = ?
+	`
+	rows, err := db.Query(query, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		err := rows.Scan(
+			&u.ID, &u.Username, &u.Password, &u.Email,
+			&u.Role, &u.Disabled, &u.TOTPEnabled, &u.AuthSource, &u.CreatedAt, &u.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
 // GetUserByID 通过 ID 获取用户信息
-func GetUserByID(db *sql.DB, id int64) (User, error) {
+func GetUserByID(db DBTX, id int64) (User, error) {
 	var user User
 	query := `
-		SELECT id, username, password, email, role, created_at, updated_at
+		SELECT id, username, password, email, role, disabled, totp_enabled, auth_source, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
 	err := db.QueryRow(query, id).Scan(
 		&user.ID, &user.Username, &user.Password, &user.Email, 
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.Disabled, &user.TOTPEnabled, &user.AuthSource, &user.CreatedAt, &user.UpdatedAt,
 	)
 	return user, err
 }
@@ -96,28 +111,181 @@ func GetUserByID(db *sql.DB, id int64) (User, error) {
 func GetUserProfile(db *sql.DB, id int64) (UserProfile, error) {
 	var profile UserProfile
 	query := `
-		SELECT id, username, email, role
+		SELECT id, username, email, role, totp_enabled
 		FROM users
 		WHERE id = ?
 	`
 	err := db.QueryRow(query, id).Scan(
-		&profile.ID, &profile.Username, &profile.Email, &profile.Role,
+		&profile.ID, &profile.Username, &profile.Email, &profile.Role, &profile.TOTPEnabled,
 	)
+	if err != nil {
+		return profile, err
+	}
+	profile.Permissions, err = GetRolePermissions(db, profile.Role)
 	return profile, err
 }
 
 // ChangePassword 更新用户密码
-func ChangePassword(db *sql.DB, userID int64, newPassword string) error {
-	hashedPassword, err := HashPassword(newPassword)
+func ChangePassword(db DBTX, userID int64, newPassword string, policy PasswordPolicy) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		return setPassword(tx, userID, newPassword, policy)
+	})
+}
+
+// GetAllUsers 获取所有用户（密码字段不会被序列化）
+func GetAllUsers(db *sql.DB) ([]User, error) {
+	query := `
+		SELECT id, username, email, role, disabled, totp_enabled, auth_source, created_at, updated_at
+		FROM users
+		ORDER BY id
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		err := rows.Scan(
+			&u.ID, &u.Username, &u.Email, &u.Role, &u.Disabled, &u.TOTPEnabled, &u.AuthSource, &u.CreatedAt, &u.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// CreateUser 创建新用户，user.Password 为明文密码，写入前会进行哈希处理
+func CreateUser(db DBTX, user *User) (int64, error) {
+	hashedPassword, err := HashPassword(user.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO users (username, password, email, role, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+	`
+	result, err := db.Exec(query, user.Username, hashedPassword, user.Email, user.Role, user.Disabled)
 	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateUser 更新用户的邮箱和角色；若会降级最后一个管理员则返回 ErrLastAdmin。
+// 角色发生变化时会吊销该用户的全部会话。
+func UpdateUser(db DBTX, user *User) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		return updateUser(tx, user)
+	})
+}
+
+func updateUser(tx *sql.Tx, user *User) error {
+	if user.Role != RoleAdmin {
+		if err := ensureNotLastAdmin(tx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	// 角色变更后，携带旧角色的令牌需要失效
+	var currentRole string
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = ?", user.ID).Scan(&currentRole); err != nil {
 		return err
 	}
-	
+	if currentRole != user.Role {
+		if err := revokeUserTokens(tx, user.ID); err != nil {
+			return err
+		}
+	}
+	// 不再是学生账号时解除与学生记录的关联
+	if currentRole == RoleStudent && user.Role != RoleStudent {
+		if _, err := tx.Exec("DELETE FROM student_accounts WHERE user_id = ?", user.ID); err != nil {
+			return err
+		}
+	}
+	// 不再是家长账号时解除与监护人的关联
+	if currentRole == RoleParent && user.Role != RoleParent {
+		if _, err := tx.Exec("UPDATE guardians SET user_id = NULL WHERE user_id = ?", user.ID); err != nil {
+			return err
+		}
+	}
+
 	query := `
 		UPDATE users
-		SET password = ?, updated_at = NOW()
+		SET email = ?, role = ?, updated_at = NOW()
 		WHERE id = ?
 	`
-	_, err = db.Exec(query, hashedPassword, userID)
+	_, err := tx.Exec(query, user.Email, user.Role, user.ID)
 	return err
-} 
\ No newline at end of file
+}
+
+// SetUserDisabled 启用或禁用用户；若会禁用最后一个管理员则返回 ErrLastAdmin。
+// 禁用时会吊销该用户的全部会话。
+func SetUserDisabled(db DBTX, id int64, disabled bool) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		if disabled {
+			if err := ensureNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+			// 禁用账号时立即吊销其全部会话
+			if err := revokeUserTokens(tx, id); err != nil {
+				return err
+			}
+		}
+
+		query := `
+			UPDATE users
+			SET disabled = ?, updated_at = NOW()
+			WHERE id = ?
+		`
+		_, err := tx.Exec(query, disabled, id)
+		return err
+	})
+}
+
+// DeleteUser 删除用户；若会删除最后一个管理员则返回 ErrLastAdmin
+func DeleteUser(db DBTX, id int64) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		if err := ensureNotLastAdmin(tx, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+		return err
+	})
+}
+
+// ensureNotLastAdmin 检查在移除指定用户的管理员身份后是否仍有其他可用的管理员。
+// 对管理员行加锁，避免并发请求同时移除最后两个管理员。
+func ensureNotLastAdmin(tx *sql.Tx, id int64) error {
+	rows, err := tx.Query("SELECT id FROM users WHERE role = ? AND disabled = FALSE FOR UPDATE", RoleAdmin)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	isAdmin := false
+	count := 0
+	for rows.Next() {
+		var adminID int64
+		if err := rows.Scan(&adminID); err != nil {
+			return err
+		}
+		if adminID == id {
+			isAdmin = true
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if isAdmin && count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
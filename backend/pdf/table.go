@@ -0,0 +1,272 @@
+// Package pdf writes simple printable tables, such as class rosters, as PDF documents.
+// Text is set in STSong-Light, one of the standard Chinese fonts PDF viewers provide,
+// so Chinese and Latin text can be printed without embedding a font.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Page layout in points: A4 landscape
+const (
+	pageWidth  = 842.0
+	pageHeight = 595.0
+	margin     = 36.0
+	titleSize  = 14.0
+	fontSize   = 9.0
+	rowHeight  = 16.0
+	cellPad    = 3.0
+)
+
+// Objects written before the pages; the page tree is written last, once the pages are known
+const (
+	catalogObj   = 1
+	pagesObj     = 2
+	fontObj      = 3
+	cidFontObj   = 4
+	fontDescObj  = 5
+	firstPageObj = 6
+)
+
+// Column is a table column; widths are relative and scaled to fill the page width
+type Column struct {
+	Header string
+	Width  float64
+}
+
+// TableWriter streams a table to a PDF document page by page, repeating the title and header row on every page.
+// Cell text that does not fit its column is cut off.
+type TableWriter struct {
+	w       *countingWriter
+	title   string
+	columns []Column
+	widths  []float64 // Column widths in points
+	offsets map[int]int64
+	pages   []int // Page object numbers
+	nextObj int
+	page    *bytes.Buffer // Content of the current page, nil before the first row
+	y       float64       // Top of the next row on the current page
+}
+
+// NewTableWriter starts a PDF document with the given title and columns
+func NewTableWriter(w io.Writer, title string, columns []Column) (*TableWriter, error) {
+	t := &TableWriter{
+		w:       &countingWriter{w: w},
+		title:   title,
+		columns: columns,
+		offsets: map[int]int64{},
+		nextObj: firstPageObj,
+	}
+
+	var total float64
+	for _, c := range columns {
+		total += c.Width
+	}
+	for _, c := range columns {
+		t.widths = append(t.widths, (pageWidth-2*margin)*c.Width/total)
+	}
+
+	// Header; the binary comment marks the file as binary for transfer programs
+	if _, err := io.WriteString(t.w, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return nil, err
+	}
+	objects := []struct {
+		num  int
+		body string
+	}{
+		{catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)},
+		{fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFontObj)},
+		// CIDs 1-95 of Adobe-GB1 are the half-width Latin characters
+		{cidFontObj, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R "+
+			"/DW 1000 /W [1 95 500] >>", fontDescObj)},
+		{fontDescObj, "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>"},
+	}
+	for _, o := range objects {
+		if err := t.writeObject(o.num, o.body); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// WriteRow adds a row to the table, starting a new page when the current one is full
+func (t *TableWriter) WriteRow(values []string) error {
+	if t.page == nil || t.y-rowHeight < margin+rowHeight {
+		if err := t.newPage(); err != nil {
+			return err
+		}
+	}
+	t.drawRow(values)
+	fmt.Fprintf(t.page, "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, t.y, pageWidth-margin, t.y)
+	return nil
+}
+
+// Close finishes the last page and writes the page tree and cross-reference table
+func (t *TableWriter) Close() error {
+	// An empty table still gets a page with the title and header
+	if t.page == nil {
+		if err := t.startPage(); err != nil {
+			return err
+		}
+	}
+	if err := t.finishPage(); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(t.pages))
+	for i, p := range t.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", p)
+	}
+	body := fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(t.pages))
+	if err := t.writeObject(pagesObj, body); err != nil {
+		return err
+	}
+
+	xref := t.w.n
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "xref\n0 %d\n0000000000 65535 f \n", t.nextObj)
+	for num := 1; num < t.nextObj; num++ {
+		fmt.Fprintf(&sb, "%010d 00000 n \n", t.offsets[num])
+	}
+	fmt.Fprintf(&sb, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", t.nextObj, catalogObj, xref)
+	_, err := io.WriteString(t.w, sb.String())
+	return err
+}
+
+// newPage finishes the current page, if any, and starts the next one
+func (t *TableWriter) newPage() error {
+	if t.page != nil {
+		if err := t.finishPage(); err != nil {
+			return err
+		}
+	}
+	return t.startPage()
+}
+
+// startPage begins a page with the title, page number and header row
+func (t *TableWriter) startPage() error {
+	t.page = &bytes.Buffer{}
+	pageNum := len(t.pages) + 1
+
+	top := pageHeight - margin
+	fmt.Fprintf(t.page, "BT /F1 %.0f Tf %.2f %.2f Td %s Tj ET\n", titleSize, margin, top-titleSize, encodeText(t.title))
+	footer := fmt.Sprintf("第 %d 页", pageNum)
+	fmt.Fprintf(t.page, "BT /F1 %.0f Tf %.2f %.2f Td %s Tj ET\n",
+		fontSize, (pageWidth-textWidth(footer, fontSize))/2, margin-fontSize, encodeText(footer))
+
+	// Header row on a grey background
+	t.y = top - titleSize - 10
+	fmt.Fprintf(t.page, "0.9 g %.2f %.2f %.2f %.2f re f 0 g\n", margin, t.y-rowHeight, pageWidth-2*margin, rowHeight)
+	headers := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		headers[i] = c.Header
+	}
+	t.drawRow(headers)
+	return nil
+}
+
+// drawRow writes the cells of a row below t.y and moves t.y to the bottom of the row
+func (t *TableWriter) drawRow(values []string) {
+	x := margin
+	baseline := t.y - rowHeight + (rowHeight-fontSize)/2 + 1
+	for i, width := range t.widths {
+		if i < len(values) {
+			text := fitText(values[i], width-2*cellPad, fontSize)
+			if text != "" {
+				fmt.Fprintf(t.page, "BT /F1 %.0f Tf %.2f %.2f Td %s Tj ET\n", fontSize, x+cellPad, baseline, encodeText(text))
+			}
+		}
+		x += width
+	}
+	t.y -= rowHeight
+}
+
+// finishPage writes the content stream and page object of the current page
+func (t *TableWriter) finishPage() error {
+	contentObj := t.nextObj
+	pageObj := t.nextObj + 1
+	t.nextObj += 2
+
+	content := t.page.Bytes()
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+	if err := t.writeObject(contentObj, stream); err != nil {
+		return err
+	}
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] "+
+		"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesObj, pageWidth, pageHeight, fontObj, contentObj)
+	if err := t.writeObject(pageObj, page); err != nil {
+		return err
+	}
+	t.pages = append(t.pages, pageObj)
+	t.page = nil
+	return nil
+}
+
+func (t *TableWriter) writeObject(num int, body string) error {
+	t.offsets[num] = t.w.n
+	_, err := fmt.Fprintf(t.w, "%d 0 obj\n%s\nendobj\n", num, body)
+	return err
+}
+
+// encodeText encodes text as a hex string in UCS-2, the encoding of the font.
+// Characters outside the Basic Multilingual Plane are replaced with "?" and control characters with spaces.
+func encodeText(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('<')
+	for _, r := range s {
+		switch {
+		case r > 0xFFFF:
+			r = '?'
+		case r < 0x20 || r == 0x7F:
+			r = ' '
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	sb.WriteByte('>')
+	return sb.String()
+}
+
+// textWidth estimates the width of text in points: Latin characters are half-width, everything else full-width
+func textWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		if r < 0x80 {
+			units += 0.5
+		} else {
+			units++
+		}
+	}
+	return units * size
+}
+
+// fitText cuts text that is wider than width, marking the cut with ".."
+func fitText(s string, width, size float64) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if textWidth(s, size) <= width {
+		return s
+	}
+	for s != "" && textWidth(s+"..", size) > width {
+		_, n := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-n]
+	}
+	return s + ".."
+}
+
+// countingWriter tracks the number of bytes written, for the cross-reference table
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"student-management/controllers"
 	"student-management/middleware"
+	"student-management/models"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -22,49 +23,153 @@ func SetupRouter(db *sql.DB) http.Handler {
 	studentController := controllers.NewStudentController(db)
 	classController := controllers.NewClassController(db)
 	authController := controllers.NewAuthController(db)
+	userController := controllers.NewUserController(db)
+	roleController := controllers.NewRoleController(db)
+	apiKeyController := controllers.NewAPIKeyController(db)
+	guardianController := controllers.NewGuardianController(db)
+	auditController := controllers.NewAuditController(db)
+	trashController := controllers.NewTrashController(db)
 
 	// Auth routes (public)
 	authRoutes := api.PathPrefix("/auth").Subrouter()
 	authRoutes.HandleFunc("/login", authController.Login).Methods("POST")
-	authRoutes.HandleFunc("/logout", authController.Logout).Methods("POST")
+	authRoutes.HandleFunc("/login/2fa", authController.LoginTwoFactor).Methods("POST")
+	authRoutes.HandleFunc("/login/2fa/setup", authController.LoginTwoFactorSetup).Methods("POST")
+	authRoutes.HandleFunc("/refresh", authController.Refresh).Methods("POST")
+	authRoutes.HandleFunc("/jwks", authController.JWKS).Methods("GET")
+	authRoutes.HandleFunc("/forgot-password", authController.ForgotPassword).Methods("POST")
+	authRoutes.HandleFunc("/reset-password", authController.ResetPassword).Methods("POST")
+	authRoutes.HandleFunc("/oidc/login", authController.OIDCLogin).Methods("GET")
+	authRoutes.HandleFunc("/oidc/callback", authController.OIDCCallback).Methods("GET")
 	
 	// Protected auth routes
 	protectedAuthRoutes := authRoutes.NewRoute().Subrouter()
-	protectedAuthRoutes.Use(middleware.AuthMiddleware)
+	protectedAuthRoutes.Use(middleware.AuthMiddleware(db), middleware.RequireUser)
+	protectedAuthRoutes.HandleFunc("/logout", authController.Logout).Methods("POST")
 	protectedAuthRoutes.HandleFunc("/profile", authController.Profile).Methods("GET")
-	protectedAuthRoutes.HandleFunc("/change-password", authController.ChangePassword).Methods("POST")
+
+	// Account security routes are blocked while an admin is impersonating another user
+	accountRoutes := protectedAuthRoutes.NewRoute().Subrouter()
+	accountRoutes.Use(middleware.RejectImpersonation)
+	accountRoutes.HandleFunc("/change-password", authController.ChangePassword).Methods("POST")
+	accountRoutes.HandleFunc("/2fa/setup", authController.SetupTwoFactor).Methods("POST")
+	accountRoutes.HandleFunc("/2fa/enable", authController.EnableTwoFactor).Methods("POST")
+	accountRoutes.HandleFunc("/2fa/disable", authController.DisableTwoFactor).Methods("POST")
+	accountRoutes.HandleFunc("/2fa/recovery-codes", authController.RegenerateRecoveryCodes).Methods("POST")
+	accountRoutes.HandleFunc("/sessions", authController.GetSessions).Methods("GET")
+	accountRoutes.HandleFunc("/sessions/revoke-others", authController.RevokeOtherSessions).Methods("POST")
+	accountRoutes.HandleFunc("/sessions/{id:[0-9a-f]{32}}", authController.RevokeSession).Methods("DELETE")
 
 	// Protected API routes
 	protectedAPI := api.NewRoute().Subrouter()
-	protectedAPI.Use(middleware.AuthMiddleware)
+	protectedAPI.Use(middleware.AuthMiddleware(db))
+
+	// requires wraps a handler with a per-route permission check
+	requires := func(permission string, handler http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(db, permission)(handler)
+	}
 
 	// Student routes
 	students := protectedAPI.PathPrefix("/students").Subrouter()
-	students.HandleFunc("", studentController.GetStudents).Methods("GET")
-	students.HandleFunc("/{id:[0-9]+}", studentController.GetStudentByID).Methods("GET")
-	students.HandleFunc("", studentController.CreateStudent).Methods("POST")
-	students.HandleFunc("/{id:[0-9]+}", studentController.UpdateStudent).Methods("PUT")
-	students.HandleFunc("/{id:[0-9]+}", studentController.DeleteStudent).Methods("DELETE")
+	students.Handle("", requires(models.PermStudentsRead, studentController.GetStudents)).Methods("GET")
+	students.Handle("/{id:[0-9]+}", requires(models.PermStudentsRead, studentController.GetStudentByID)).Methods("GET")
+	students.Handle("", requires(models.PermStudentsWrite, studentController.CreateStudent)).Methods("POST")
+	students.Handle("/export", requires(models.PermStudentsRead, studentController.ExportStudents)).Methods("GET")
+	students.Handle("/import", requires(models.PermStudentsWrite, studentController.ImportStudents)).Methods("POST")
+	students.Handle("/bulk", requires(models.PermStudentsWrite, studentController.BulkStudents)).Methods("POST")
+	students.Handle("/{id:[0-9]+}", requires(models.PermStudentsWrite, studentController.UpdateStudent)).Methods("PUT")
+	students.Handle("/{id:[0-9]+}", requires(models.PermStudentsWrite, studentController.PatchStudent)).Methods("PATCH")
+	students.Handle("/{id:[0-9]+}", requires(models.PermStudentsDelete, studentController.DeleteStudent)).Methods("DELETE")
+	students.Handle("/{id:[0-9]+}/history", requires(models.PermStudentsRead, studentController.GetStudentHistory)).Methods("GET")
+	students.Handle("/{id:[0-9]+}/history/{version:[0-9]+}/revert", requires(models.PermStudentsWrite, studentController.RevertStudent)).Methods("POST")
+	students.Handle("/{id:[0-9]+}/guardians", requires(models.PermStudentsRead, guardianController.GetGuardians)).Methods("GET")
+	students.Handle("/{id:[0-9]+}/guardians/{guardianId:[0-9]+}", requires(models.PermStudentsRead, guardianController.GetGuardian)).Methods("GET")
+	students.Handle("/{id:[0-9]+}/guardians", requires(models.PermStudentsWrite, guardianController.CreateGuardian)).Methods("POST")
+	students.Handle("/{id:[0-9]+}/guardians/{guardianId:[0-9]+}", requires(models.PermStudentsWrite, guardianController.UpdateGuardian)).Methods("PUT")
+	students.Handle("/{id:[0-9]+}/guardians/{guardianId:[0-9]+}", requires(models.PermStudentsWrite, guardianController.DeleteGuardian)).Methods("DELETE")
 
 	// Class routes
 	classes := protectedAPI.PathPrefix("/classes").Subrouter()
-	classes.HandleFunc("", classController.GetClasses).Methods("GET")
-	classes.HandleFunc("/{id:[0-9]+}", classController.GetClassByID).Methods("GET")
-	classes.HandleFunc("", classController.CreateClass).Methods("POST")
-	classes.HandleFunc("/{id:[0-9]+}", classController.UpdateClass).Methods("PUT")
-	classes.HandleFunc("/{id:[0-9]+}", classController.DeleteClass).Methods("DELETE")
-	classes.HandleFunc("/{id:[0-9]+}/students", classController.GetClassStudents).Methods("GET")
+	classes.Handle("", requires(models.PermClassesRead, classController.GetClasses)).Methods("GET")
+	classes.Handle("/{id:[0-9]+}", requires(models.PermClassesRead, classController.GetClassByID)).Methods("GET")
+	classes.Handle("", requires(models.PermClassesWrite, classController.CreateClass)).Methods("POST")
+	classes.Handle("/{id:[0-9]+}", requires(models.PermClassesWrite, classController.UpdateClass)).Methods("PUT")
+	classes.Handle("/{id:[0-9]+}", requires(models.PermClassesWrite, classController.PatchClass)).Methods("PATCH")
+	classes.Handle("/{id:[0-9]+}", requires(models.PermClassesDelete, classController.DeleteClass)).Methods("DELETE")
+	classes.Handle("/{id:[0-9]+}/students", requires(models.PermStudentsRead, classController.GetClassStudents)).Methods("GET")
+	classes.Handle("/{id:[0-9]+}/roster", requires(models.PermStudentsRead, classController.ExportRoster)).Methods("GET")
+
+	// Self-service routes for the student and parent roles
+	me := protectedAPI.PathPrefix("/me").Subrouter()
+	me.Use(middleware.RequireUser)
+	me.HandleFunc("/student", studentController.GetOwnStudent).Methods("GET")
+	me.HandleFunc("/student", studentController.UpdateOwnStudent).Methods("PATCH")
+	me.HandleFunc("/children", studentController.GetOwnChildren).Methods("GET")
+	me.HandleFunc("/children/{id:[0-9]+}", studentController.GetOwnChild).Methods("GET")
+
+	// User management routes
+	users := protectedAPI.PathPrefix("/users").Subrouter()
+	users.Use(middleware.RequirePermission(db, models.PermUsersManage), middleware.RejectImpersonation)
+	users.HandleFunc("", userController.GetUsers).Methods("GET")
+	users.HandleFunc("/{id:[0-9]+}", userController.GetUserByID).Methods("GET")
+	users.HandleFunc("", userController.CreateUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}", userController.UpdateUser).Methods("PUT")
+	users.HandleFunc("/{id:[0-9]+}/disable", userController.DisableUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/enable", userController.EnableUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/unlock", userController.UnlockUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/2fa/reset", userController.ResetTwoFactor).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/classes", userController.GetUserClasses).Methods("GET")
+	users.HandleFunc("/{id:[0-9]+}/classes", userController.SetUserClasses).Methods("PUT")
+	users.HandleFunc("/{id:[0-9]+}/student", userController.GetUserStudent).Methods("GET")
+	users.HandleFunc("/{id:[0-9]+}/student", userController.SetUserStudent).Methods("PUT")
+	users.HandleFunc("/{id:[0-9]+}/student", userController.UnlinkUserStudent).Methods("DELETE")
+	users.HandleFunc("/{id:[0-9]+}/guardian", userController.GetUserGuardian).Methods("GET")
+	users.HandleFunc("/{id:[0-9]+}/guardian", userController.SetUserGuardian).Methods("PUT")
+	users.HandleFunc("/{id:[0-9]+}/guardian", userController.UnlinkUserGuardian).Methods("DELETE")
+	users.HandleFunc("/{id:[0-9]+}", userController.DeleteUser).Methods("DELETE")
+	users.HandleFunc("/{id:[0-9]+}/impersonate", authController.Impersonate).Methods("POST")
+
+	// Role and permission definitions
+	protectedAPI.Handle("/permissions", requires(models.PermRolesManage, roleController.GetPermissions)).Methods("GET")
+	roles := protectedAPI.PathPrefix("/roles").Subrouter()
+	roles.Use(middleware.RequirePermission(db, models.PermRolesManage), middleware.RejectImpersonation)
+	roles.HandleFunc("", roleController.GetRoles).Methods("GET")
+	roles.HandleFunc("/{name}", roleController.GetRole).Methods("GET")
+	roles.HandleFunc("", roleController.CreateRole).Methods("POST")
+	roles.HandleFunc("/{name}", roleController.UpdateRole).Methods("PUT")
+	roles.HandleFunc("/{name}", roleController.DeleteRole).Methods("DELETE")
+
+	// API keys for service-to-service integrations
+	apiKeys := protectedAPI.PathPrefix("/api-keys").Subrouter()
+	apiKeys.Use(middleware.RequirePermission(db, models.PermAPIKeysManage), middleware.RejectImpersonation)
+	apiKeys.HandleFunc("", apiKeyController.GetAPIKeys).Methods("GET")
+	apiKeys.HandleFunc("/{id:[0-9]+}", apiKeyController.GetAPIKey).Methods("GET")
+	apiKeys.HandleFunc("", apiKeyController.CreateAPIKey).Methods("POST")
+	apiKeys.HandleFunc("/{id:[0-9]+}", apiKeyController.RevokeAPIKey).Methods("DELETE")
+	
+	// Audit log of write operations
+	protectedAPI.Handle("/audit", requires(models.PermAuditRead, auditController.GetAuditLogs)).Methods("GET")
+
+	// Trash: deleted students and classes can be restored with the delete permission until they are purged
+	trash := protectedAPI.PathPrefix("/trash").Subrouter()
+	trash.Handle("/students", requires(models.PermStudentsDelete, trashController.GetDeletedStudents)).Methods("GET")
+	trash.Handle("/students/{id:[0-9]+}/restore", requires(models.PermStudentsDelete, trashController.RestoreStudent)).Methods("POST")
+	trash.Handle("/students/{id:[0-9]+}", requires(models.PermTrashPurge, trashController.PurgeStudent)).Methods("DELETE")
+	trash.Handle("/classes", requires(models.PermClassesDelete, trashController.GetDeletedClasses)).Methods("GET")
+	trash.Handle("/classes/{id:[0-9]+}/restore", requires(models.PermClassesDelete, trashController.RestoreClass)).Methods("POST")
+	trash.Handle("/classes/{id:[0-9]+}", requires(models.PermTrashPurge, trashController.PurgeClass)).Methods("DELETE")
 	
 	// Set up CORS middleware
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://www.zsjurl.top"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{"ETag"},
 		AllowCredentials: true,
 	})
 
-	// Wrap router with CORS middleware
-	handler := c.Handler(router)
+	// Wrap router with CORS middleware, and log every request (including preflight and unmatched ones)
+	handler := middleware.AccessLog(c.Handler(router))
 	
 	return handler
 } 
\ No newline at end of file
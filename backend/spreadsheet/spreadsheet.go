@@ -0,0 +1,308 @@
+// Package spreadsheet reads and writes the CSV and XLSX files used to import and export students.
+// Only what spreadsheets exported by Excel, LibreOffice and similar tools need is supported:
+// the first worksheet, shared and inline strings, and plain cell values.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedFormat is returned for files that are neither CSV nor XLSX
+var ErrUnsupportedFormat = errors.New("unsupported file format, expected CSV or XLSX")
+
+// Errors returned when a file is larger than the Limits it is read with
+var (
+	ErrTooManyRows    = errors.New("the file has too many rows")
+	ErrTooManyColumns = errors.New("the file has too many columns")
+)
+
+// Limits bounds the table a file is read into. XLSX cells are placed by their reference, so a
+// small file with a cell in row 1000000 or column XFD would otherwise expand into a huge table.
+// Zero values stand for the size of an Excel worksheet.
+type Limits struct {
+	Rows    int // Last row that may be read, counting the header
+	Columns int // Last column that may hold a value; empty cells beyond it are ignored
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.Rows <= 0 || l.Rows > maxRows {
+		l.Rows = maxRows
+	}
+	if l.Columns <= 0 || l.Columns > maxColumns {
+		l.Columns = maxColumns
+	}
+	return l
+}
+
+// Read parses a CSV or XLSX file into rows of cell values. The format is taken from the file name's
+// extension, falling back to the content (XLSX files are ZIP archives).
+func Read(name string, data []byte, limits Limits) ([][]string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv", ".txt":
+		return ReadCSV(data, limits)
+	case ".xlsx":
+		return ReadXLSX(data, limits)
+	case ".xls":
+		return nil, ErrUnsupportedFormat
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return ReadXLSX(data, limits)
+	}
+	return ReadCSV(data, limits)
+}
+
+// ReadCSV parses UTF-8 CSV data, with or without a byte order mark.
+// Rows may have different numbers of fields. The quote written in front of formula-like values
+// by the CSV Writer is removed, so exported files read back unchanged.
+func ReadCSV(data []byte, limits Limits) ([][]string, error) {
+	limits = limits.withDefaults()
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, errors.New("CSV file is not UTF-8 encoded")
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %w", err)
+	}
+	if len(rows) > limits.Rows {
+		return nil, ErrTooManyRows
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if value != "" && i >= limits.Columns {
+				return nil, ErrTooManyColumns
+			}
+			if value != "" && value[0] == '\'' && needsFormulaQuote(value[1:]) {
+				row[i] = value[1:]
+			}
+		}
+	}
+	return rows, nil
+}
+
+// ReadXLSX parses the first worksheet of an XLSX workbook
+func ReadXLSX(data []byte, limits Limits) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var sharedStrings []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if sharedStrings, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("invalid XLSX file: missing %s", sheetPath)
+	}
+	return readSheet(f, sharedStrings, limits.withDefaults())
+}
+
+// firstSheetPath finds the first worksheet of the workbook through its relationships
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if err := decodeXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("invalid XLSX file: workbook has no worksheets")
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		// Targets are relative to xl/ unless they are absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("invalid XLSX file: first worksheet not found")
+}
+
+// readSharedStrings reads the shared string table, joining the runs of rich text strings
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var table struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := decodeFile(f, &table); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		if len(item.Runs) == 0 {
+			strs[i] = item.Text
+			continue
+		}
+		var sb strings.Builder
+		for _, run := range item.Runs {
+			sb.WriteString(run.Text)
+		}
+		strs[i] = sb.String()
+	}
+	return strs, nil
+}
+
+// readSheet reads the cell values of a worksheet, placing cells by their reference so empty cells are kept.
+// Rows past limits.Rows and values past limits.Columns are rejected before any space is made for them.
+func readSheet(f *zip.File, sharedStrings []string, limits Limits) ([][]string, error) {
+	var sheet struct {
+		Rows []struct {
+			Index int `xml:"r,attr"` // 1-based, rows without cells may be left out
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline struct {
+					Text string `xml:"t"`
+					Runs []struct {
+						Text string `xml:"t"`
+					} `xml:"r"`
+				} `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeFile(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		if row.Index > maxRows {
+			return nil, fmt.Errorf("invalid XLSX file: row %d is out of range", row.Index)
+		}
+		if row.Index > limits.Rows || len(rows) >= limits.Rows {
+			return nil, ErrTooManyRows
+		}
+		for len(rows) < row.Index-1 {
+			rows = append(rows, []string{})
+		}
+
+		values := []string{}
+		for i, cell := range row.Cells {
+			col := i
+			if c := columnIndex(cell.Ref); c >= 0 {
+				col = c
+			}
+			if col >= maxColumns {
+				return nil, fmt.Errorf("invalid XLSX file: cell %s is out of range", cell.Ref)
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				var idx int
+				if _, err := fmt.Sscan(cell.Value, &idx); err != nil || idx < 0 || idx >= len(sharedStrings) {
+					return nil, fmt.Errorf("invalid XLSX file: bad shared string index in cell %s", cell.Ref)
+				}
+				value = sharedStrings[idx]
+			case "inlineStr":
+				value = cell.Inline.Text
+				for _, run := range cell.Inline.Runs {
+					value += run.Text
+				}
+			default:
+				value = cell.Value
+			}
+			if value == "" {
+				continue
+			}
+			if col >= limits.Columns {
+				return nil, ErrTooManyColumns
+			}
+
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			values[col] = value
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// columnIndex converts the column letters of a cell reference such as "AB12" to a zero-based index,
+// returning -1 when the reference has no column letters
+func columnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		if col > maxColumns {
+			return maxColumns // out of range either way; stop before the index overflows
+		}
+	}
+	return col - 1
+}
+
+func decodeXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("invalid XLSX file: missing %s", name)
+	}
+	return decodeFile(f, v)
+}
+
+func decodeFile(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX file: %w", err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXMLSize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid XLSX file: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// Limits guarding against ZIP bombs and sparse sheets with far-away cells
+const (
+	maxXMLSize = 64 << 20 // Bytes read from each decompressed XML part
+	maxRows    = 1 << 20  // Rows in an Excel worksheet
+	maxColumns = 1 << 14  // Columns in an Excel worksheet
+)
@@ -0,0 +1,226 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildXLSX returns a minimal workbook whose first worksheet has the given sheetData content
+func buildXLSX(t *testing.T, sheetData string, sharedStrings ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"[Content_Types].xml":        xlsxContentTypes,
+		"_rels/.rels":                xlsxRootRels,
+		"xl/workbook.xml":            strings.Replace(xlsxWorkbook, "{{sheet}}", "Sheet1", 1),
+		"xl/_rels/workbook.xml.rels": xlsxWorkbookRels,
+		"xl/worksheets/sheet1.xml":   xml.Header + `<worksheet xmlns="` + xlsxMainNS + `"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	if len(sharedStrings) > 0 {
+		parts["xl/sharedStrings.xml"] = xml.Header + `<sst xmlns="` + xlsxMainNS + `">` + strings.Join(sharedStrings, "") + `</sst>`
+	}
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t,
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>`+
+			`<row r="3"><c r="B3" t="inlineStr"><is><t>inline</t></is></c><c r="D3"><v>42</v></c></row>`+
+			`<row r="4"><c r="A4" t="inlineStr"><is><r><t>rich </t></r><r><t>inline</t></r></is></c><c r="B4" t="s"><v>1</v></c></row>`+
+			`<row><c><v>no</v></c><c><v>refs</v></c></row>`,
+		`<si><t>name</t></si>`,
+		`<si><r><t>rich </t></r><r><t>text</t></r></si>`,
+	)
+	got, err := ReadXLSX(data, Limits{})
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	want := [][]string{
+		{"name", "", "rich text"},
+		{},
+		{"", "inline", "", "42"},
+		{"rich inline", "rich text"},
+		{"no", "refs"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadXLSX =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	limits := Limits{Rows: 3, Columns: 3}
+	tests := []struct {
+		name      string
+		sheetData string
+		wantErr   error // nil with wantOK false means any error
+		wantOK    bool
+	}{
+		{
+			name:      "within limits",
+			sheetData: `<row r="1"><c r="C1"><v>1</v></c></row><row r="3"><c r="A3"><v>1</v></c></row>`,
+			wantOK:    true,
+		},
+		{
+			name:      "empty cells beyond the last column are ignored",
+			sheetData: `<row r="1"><c r="A1"><v>1</v></c><c r="XFD1" s="1"/><c r="Z1"><v></v></c></row>`,
+			wantOK:    true,
+		},
+		{
+			name:      "value beyond the last column",
+			sheetData: `<row r="1"><c r="D1"><v>1</v></c></row>`,
+			wantErr:   ErrTooManyColumns,
+		},
+		{
+			name:      "far-away column",
+			sheetData: `<row r="1"><c r="XFD1"><v>1</v></c></row>`,
+			wantErr:   ErrTooManyColumns,
+		},
+		{
+			name:      "too many rows",
+			sheetData: `<row r="1"/><row r="2"/><row r="3"/><row r="4"/>`,
+			wantErr:   ErrTooManyRows,
+		},
+		{
+			name:      "too many rows without indexes",
+			sheetData: `<row/><row/><row/><row/>`,
+			wantErr:   ErrTooManyRows,
+		},
+		{
+			name:      "far-away row",
+			sheetData: `<row r="1000000"><c r="A1000000"><v>1</v></c></row>`,
+			wantErr:   ErrTooManyRows,
+		},
+		{
+			name:      "row outside a worksheet",
+			sheetData: `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`,
+		},
+		{
+			name:      "column outside a worksheet",
+			sheetData: `<row r="1"><c r="XFE1"><v>1</v></c></row>`,
+		},
+		{
+			name:      "column letters that would overflow",
+			sheetData: `<row r="1"><c r="ZZZZZZZZZZZZZZZZZZZZZZZZ1"><v>1</v></c></row>`,
+		},
+		{
+			name:      "bad shared string index",
+			sheetData: `<row r="1"><c r="A1" t="s"><v>5</v></c></row>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadXLSX(buildXLSX(t, tt.sheetData, `<si><t>only</t></si>`), limits)
+			switch {
+			case tt.wantOK:
+				if err != nil {
+					t.Fatalf("ReadXLSX: %v", err)
+				}
+			case tt.wantErr != nil:
+				if err != tt.wantErr {
+					t.Fatalf("ReadXLSX error = %v, want %v", err, tt.wantErr)
+				}
+			case err == nil:
+				t.Fatal("ReadXLSX accepted an invalid sheet")
+			}
+		})
+	}
+}
+
+func TestReadXLSXInvalid(t *testing.T) {
+	if _, err := ReadXLSX([]byte("PK\x03\x04 not really a zip"), Limits{}); err == nil {
+		t.Error("ReadXLSX accepted a broken archive")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("xl/workbook.xml")
+	zw.Close()
+	if _, err := ReadXLSX(buf.Bytes(), Limits{}); err == nil {
+		t.Error("ReadXLSX accepted an archive without a worksheet")
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	data := "\ufeffstudent_no,name,note\n2024001,\"Zhang, San\",'=1+1\n2024002,李四\n2024003,O'Brien,'text,\n"
+	got, err := ReadCSV([]byte(data), Limits{})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	want := [][]string{
+		{"student_no", "name", "note"},
+		{"2024001", "Zhang, San", "=1+1"},
+		{"2024002", "李四"},
+		{"2024003", "O'Brien", "'text", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadCSV =\n%q\nwant\n%q", got, want)
+	}
+
+	if _, err := ReadCSV([]byte("name\n\xff\xfe\n"), Limits{}); err == nil {
+		t.Error("ReadCSV accepted data that is not UTF-8")
+	}
+}
+
+func TestReadCSVLimits(t *testing.T) {
+	limits := Limits{Rows: 2, Columns: 2}
+	tests := []struct {
+		data    string
+		wantErr error
+	}{
+		{"a,b\nc,d\n", nil},
+		{"a,b,,\nc,d,\n", nil}, // empty fields beyond the last column are ignored
+		{"a,b,c\n", ErrTooManyColumns},
+		{"a\nb\nc\n", ErrTooManyRows},
+	}
+	for _, tt := range tests {
+		if _, err := ReadCSV([]byte(tt.data), limits); err != tt.wantErr {
+			t.Errorf("ReadCSV(%q) error = %v, want %v", tt.data, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	xlsx := buildXLSX(t, `<row r="1"><c r="A1"><v>xlsx</v></c></row>`)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"students.csv", []byte("csv"), "csv", nil},
+		{"STUDENTS.CSV", []byte("csv"), "csv", nil},
+		{"students.txt", []byte("csv"), "csv", nil},
+		{"students.xlsx", xlsx, "xlsx", nil},
+		{"students", xlsx, "xlsx", nil},
+		{"students", []byte("csv"), "csv", nil},
+		{"students.xls", xlsx, "", ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		rows, err := Read(tt.name, tt.data, Limits{})
+		if err != tt.wantErr {
+			t.Errorf("Read(%q) error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (len(rows) != 1 || len(rows[0]) != 1 || rows[0][0] != tt.want) {
+			t.Errorf("Read(%q) = %q, want [[%s]]", tt.name, rows, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer writes a table row by row. Rows are streamed to the underlying writer as they are written;
+// Close must be called to finish the file.
+type Writer interface {
+	WriteRow(values []string) error
+	Close() error
+}
+
+// csvWriter writes UTF-8 CSV with a byte order mark, so Excel detects the encoding
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVWriter returns a Writer producing CSV
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteRow(values []string) error {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = escapeFormula(value)
+	}
+	if !c.header {
+		c.header = true
+		// The BOM goes through the csv.Writer's buffer so it stays in front of the first row
+		if len(escaped) > 0 {
+			escaped[0] = "\ufeff" + escaped[0]
+		}
+	}
+	return c.w.Write(escaped)
+}
+
+// escapeFormula prefixes values that a spreadsheet program would evaluate as a formula with a quote,
+// so user-supplied text such as "=HYPERLINK(...)" is shown as text. XLSX cells are written as inline
+// strings, which are never evaluated, so only CSV needs this.
+func escapeFormula(value string) string {
+	if needsFormulaQuote(value) {
+		return "'" + value
+	}
+	return value
+}
+
+// needsFormulaQuote reports whether escapeFormula quotes the value. Text that already looks like an
+// escaped formula, such as "'=1", is quoted once more so ReadCSV can tell it from an escaped "=1".
+func needsFormulaQuote(value string) bool {
+	if value == "" {
+		return false
+	}
+	if value[0] == '\'' {
+		return needsFormulaQuote(value[1:])
+	}
+	return strings.ContainsRune("=+-@\t\r", rune(value[0]))
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// xlsxWriter writes a workbook with a single worksheet, streaming the rows into the worksheet part
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+// NewXLSXWriter returns a Writer producing an XLSX workbook with one worksheet of the given name.
+// All cells are text, and the first row is written in bold as the header.
+func NewXLSXWriter(w io.Writer, sheetName string) (Writer, error) {
+	// Excel limits sheet names to 31 characters and does not allow some punctuation
+	sheetName = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, sheetName)
+	if runes := []rune(sheetName); len(runes) > 31 {
+		sheetName = string(runes[:31])
+	}
+	if sheetName == "" {
+		sheetName = "Sheet1"
+	}
+
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", strings.Replace(xlsxWorkbook, "{{sheet}}", escapeXML(sheetName), 1)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xml.Header+`<worksheet xmlns="`+xlsxMainNS+`"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zw: zw, sheet: sheet}, nil
+}
+
+func (x *xlsxWriter) WriteRow(values []string) error {
+	x.rows++
+	style := ""
+	if x.rows == 1 {
+		style = ` s="1"`
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<row r="` + strconv.Itoa(x.rows) + `">`)
+	for i, v := range values {
+		sb.WriteString(`<c r="` + columnName(i) + strconv.Itoa(x.rows) + `" t="inlineStr"` + style + `>`)
+		sb.WriteString(`<is><t xml:space="preserve">` + escapeXML(v) + `</t></is></c>`)
+	}
+	sb.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// columnName converts a zero-based column index to its letters, e.g. 27 to "AB"
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+const xlsxMainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="` + xlsxMainNS + `" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="{{sheet}}" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// Style 0 is the default and style 1 is bold, used for the header row
+const xlsxStyles = xml.Header + `<styleSheet xmlns="` + xlsxMainNS + `">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,154 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripRows exercises escaping: formula-like text, quotes, XML special characters,
+// whitespace that must be preserved, non-ASCII text and empty cells
+var roundTripRows = [][]string{
+	{"student_no", "name", "note", "", "email"},
+	{"2024001", "张三", `=HYPERLINK("http://evil.example.com","click")`, "", "zhang@example.com"},
+	{"2024002", "O'Brien", "+1 555 0100", "-5", "@mention"},
+	{"2024003", "<b>&amp;</b>", "'=already escaped", "''=twice", "'quoted"},
+	{"2024004", "  padded  ", "line 1\nline 2", "\ttab", "a,b;\"c\""},
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	for _, row := range roundTripRows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\ufeffstudent_no,")) {
+		t.Errorf("CSV does not start with a byte order mark: %q", buf.String()[:20])
+	}
+
+	got, err := ReadCSV(buf.Bytes(), Limits{})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if !reflect.DeepEqual(got, roundTripRows) {
+		t.Errorf("ReadCSV(written CSV) =\n%q\nwant\n%q", got, roundTripRows)
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	rows := append([][]string(nil), roundTripRows...)
+	// A row wider than 26 columns checks two-letter column names
+	wide := make([]string, 30)
+	for i := range wide {
+		wide[i] = columnName(i)
+	}
+	rows = append(rows, wide)
+
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "Students: 2024/2025 [all classes] and more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read("export", buf.Bytes(), Limits{})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	// XLSX cells are not formula-escaped; only trailing empty cells are lost
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("Read(written XLSX) =\n%q\nwant\n%q", got, rows)
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"1+1", "1+1"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"'", "'"},
+		{"'text", "'text"},
+		{"'=1", "''=1"},
+		{"''=1", "'''=1"},
+		{"张三", "张三"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.value); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewXLSXWriterSheetName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Students", "Students"},
+		{"", "Sheet1"},
+		{"a/b\\c[d]e:f*g?h", "a_b_c_d_e_f_g_h"},
+		{strings.Repeat("学", 40), strings.Repeat("学", 31)},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := NewXLSXWriter(&buf, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var workbook struct {
+			Sheets []struct {
+				Name string `xml:"name,attr"`
+			} `xml:"sheets>sheet"`
+		}
+		f, err := zr.Open("xl/workbook.xml")
+		if err == nil {
+			err = xml.NewDecoder(f).Decode(&workbook)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != tt.want {
+			t.Errorf("NewXLSXWriter(%q) sheets = %+v, want one named %q", tt.name, workbook.Sheets, tt.want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA", maxColumns - 1: "XFD"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+		if got := columnIndex(want + "1"); got != i {
+			t.Errorf("columnIndex(%s1) = %d, want %d", want, got, i)
+		}
+	}
+}
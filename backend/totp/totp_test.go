@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of RFC 6238 appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA1 test vectors of RFC 6238 appendix B. The RFC lists 8-digit codes;
+// a 6-digit code is the same value modulo 10^6, i.e. its last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestCodeAtRFC6238(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		want := tt.code[len(tt.code)-Digits:]
+		got, err := CodeAt(rfc6238Secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt(%d): %v", tt.unix, err)
+		}
+		if got != want {
+			t.Errorf("CodeAt(%d) = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeAtSecretFormat(t *testing.T) {
+	// Authenticator apps show secrets in lower case and people paste them with surrounding spaces
+	for _, secret := range []string{rfc6238Secret, "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", " " + rfc6238Secret + "\n"} {
+		got, err := CodeAt(secret, 1)
+		if err != nil || got != "287082" {
+			t.Errorf("CodeAt(%q, 1) = %s, %v, want 287082", secret, got, err)
+		}
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Error("CodeAt accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0) // step 37037037, code 050471
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		skew     int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", "050471", now, 0, 37037037, true},
+		{"with spaces", " 050 471 ", now, 0, 37037037, true},
+		{"previous step within skew", "050471", now.Add(Period * time.Second), 1, 37037037, true},
+		{"next step within skew", "050471", now.Add(-Period * time.Second), 1, 37037037, true},
+		{"previous step without skew", "050471", now.Add(Period * time.Second), 0, 0, false},
+		{"beyond skew", "050471", now.Add(2 * Period * time.Second), 1, 0, false},
+		{"wrong code", "050472", now, 1, 0, false},
+		{"8 digits", "14050471", now, 1, 0, false},
+		{"too short", "50471", now, 1, 0, false},
+		{"empty", "", now, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfc6238Secret, tt.code, tt.at, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("len(GenerateSecret()) = %d, want 32 (160 bits)", len(secret))
+	}
+	code, err := CodeAt(secret, Step(time.Now()))
+	if err != nil {
+		t.Fatalf("CodeAt(GenerateSecret()): %v", err)
+	}
+	if _, ok := Validate(secret, code, time.Now(), 1); !ok {
+		t.Error("Validate rejected the current code of a generated secret")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Student Management", "alice@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Student Management:alice@example.com" {
+		t.Errorf("ProvisioningURI = %s", uri)
+	}
+	want := url.Values{
+		"secret":    {rfc6238Secret},
+		"issuer":    {"Student Management"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}
+	if got := uri.Query(); got.Encode() != want.Encode() {
+		t.Errorf("ProvisioningURI query = %s, want %s", got.Encode(), want.Encode())
+	}
+}
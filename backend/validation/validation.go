@@ -0,0 +1,110 @@
+// Package validation collects field-level input errors, so a request can be rejected with every
+// failing field at once instead of the first one found.
+package validation
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Machine-readable error codes, stable for clients to match on
+const (
+	Required       = "required"        // the field is missing or blank
+	TooLong        = "too_long"        // the value has more than params.max characters
+	InvalidFormat  = "invalid_format"  // the value is not in the expected format
+	NotFound       = "not_found"       // the value refers to a record that does not exist
+	Taken          = "taken"           // the value must be unique and is already in use
+	WeakPassword   = "weak_password"   // the password does not meet the password policy
+	PasswordReused = "password_reused" // the password was used recently
+)
+
+// FieldError is a problem with one field of a request
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Errors lists the problems found in a request, in the order they were found
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a problem with a field
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Has reports whether a problem was recorded for the field, e.g. to skip database checks
+// of a value that is already known to be invalid
+func (e Errors) Has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Only returns the problems with the given fields, e.g. to check just the fields a partial update changes
+func (e Errors) Only(fields ...string) Errors {
+	var only Errors
+	for _, fe := range e {
+		for _, field := range fields {
+			if fe.Field == field {
+				only = append(only, fe)
+				break
+			}
+		}
+	}
+	return only
+}
+
+// Required records a required error when value is blank, and reports whether it is not
+func (e *Errors) Required(field, value, message string) bool {
+	if strings.TrimSpace(value) == "" {
+		e.Add(field, Required, message)
+		return false
+	}
+	return true
+}
+
+// MaxLength records a too_long error when value has more than max characters (not bytes)
+func (e *Errors) MaxLength(field, value string, max int, message string) bool {
+	if utf8.RuneCountInString(value) > max {
+		*e = append(*e, FieldError{Field: field, Code: TooLong, Message: message, Params: map[string]interface{}{"max": max}})
+		return false
+	}
+	return true
+}
+
+// Match records an invalid_format error when a non-empty value does not match the pattern
+func (e *Errors) Match(field, value string, pattern *regexp.Regexp, message string) bool {
+	if value != "" && !pattern.MatchString(value) {
+		e.Add(field, InvalidFormat, message)
+		return false
+	}
+	return true
+}
+
+// Email records an invalid_format error when a non-empty value is not a bare email address;
+// display names such as "Name <name@example.com>" are not accepted
+func (e *Errors) Email(field, value, message string) bool {
+	if value == "" {
+		return true
+	}
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		e.Add(field, InvalidFormat, message)
+		return false
+	}
+	return true
+}
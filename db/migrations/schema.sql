@@ -4,6 +4,23 @@
 CREATE DATABASE IF NOT EXISTS student_management CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 USE student_management;
 
+-- 角色表（角色名对应 users.role）
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE, -- 内置角色不能删除
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- 角色权限表
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role, permission),
+    FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
 -- 用户表（用于认证）
 CREATE TABLE IF NOT EXISTS users (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
@@ -11,8 +28,18 @@ CREATE TABLE IF NOT EXISTS users (
     password VARCHAR(255) NOT NULL,
     email VARCHAR(100) NOT NULL,
     role VARCHAR(20) NOT NULL DEFAULT 'user',
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    tokens_revoked_at TIMESTAMP NULL DEFAULT NULL, -- 早于该时间签发的访问令牌全部失效
+    totp_secret VARCHAR(64) NULL DEFAULT NULL, -- 两步验证密钥（base32），启用前为待验证状态
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0, -- 最近一次使用的验证码时间步，防止验证码重放
+    auth_source VARCHAR(20) NOT NULL DEFAULT 'local', -- local：本地密码；ldap：由目录服务验证，首次登录时自动创建
+    oidc_issuer VARCHAR(255) NULL DEFAULT NULL, -- 单点登录账号绑定的签发方（iss）和用户标识（sub），用户名只是首次登录时的初始值
+    oidc_subject VARCHAR(255) NULL DEFAULT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE (oidc_issuer, oidc_subject),
+    FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE
 );
 
 -- 班级表
@@ -21,7 +48,9 @@ CREATE TABLE IF NOT EXISTS classes (
     name VARCHAR(100) NOT NULL,
     description TEXT,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL, -- 非空表示已移入回收站
+    row_version BIGINT NOT NULL DEFAULT 1 -- 每次修改加 1（见触发器），用作 ETag
 );
 
 -- 学生表
@@ -35,14 +64,291 @@ CREATE TABLE IF NOT EXISTS students (
     address TEXT,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL, -- 非空表示已移入回收站，学号仍被占用
+    row_version BIGINT NOT NULL DEFAULT 1, -- 每次修改加 1（见触发器），用作 ETag
     FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE SET NULL
 );
 
+-- 登录会话（一次登录对应一个会话，刷新令牌轮换时会话不变；访问令牌的 sid 声明指向会话）
+CREATE TABLE IF NOT EXISTS sessions (
+    id CHAR(32) PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 刷新令牌表（只保存令牌的 SHA-256 哈希）
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    session_id CHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL DEFAULT NULL,
+    replaced_by BIGINT NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+-- 密码重置令牌（只保存哈希，一次性使用）
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 密码历史表（用于禁止重复使用最近的密码）
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 两步验证恢复码（只保存哈希，每个只能使用一次）
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 登录两步验证挑战（密码验证通过后、第二因素验证前使用的一次性令牌）
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- OpenID Connect 单点登录进行中的授权请求（state 一次性使用）
+CREATE TABLE IF NOT EXISTS oidc_states (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    state_hash CHAR(64) NOT NULL UNIQUE,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 已吊销的访问令牌（按 JWT ID 记录，过期后可清理）
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 教师与班级的分配关系（教师只能访问分配给自己的班级）
+CREATE TABLE IF NOT EXISTS teacher_classes (
+    user_id BIGINT NOT NULL,
+    class_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, class_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE
+);
+
+-- 学生账号与学生记录的一一对应关系（student 角色只能访问关联的学生记录）
+CREATE TABLE IF NOT EXISTS student_accounts (
+    user_id BIGINT PRIMARY KEY,
+    student_id BIGINT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
+
+-- 学生的监护人（家长），可选关联一个 parent 角色的登录账号
+CREATE TABLE IF NOT EXISTS guardians (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    relationship VARCHAR(50) NOT NULL, -- 与学生的关系，例如 '父亲'、'母亲'
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    emergency_contact BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id BIGINT UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- 学生与监护人的多对多关系（兄弟姐妹可以共用同一个监护人）
+CREATE TABLE IF NOT EXISTS student_guardians (
+    student_id BIGINT NOT NULL,
+    guardian_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, guardian_id),
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+    FOREIGN KEY (guardian_id) REFERENCES guardians(id) ON DELETE CASCADE
+);
+
+-- 学生标签（例如 '毕业班'、'住校'），用于分组筛选和批量操作
+CREATE TABLE IF NOT EXISTS student_tags (
+    student_id BIGINT NOT NULL,
+    tag VARCHAR(50) NOT NULL, -- 不能包含逗号
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, tag),
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
+
+-- 登录失败记录（按用户名和 IP 分别统计，用于退避和锁定）
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope VARCHAR(10) NOT NULL, -- 'user'、'ip'，或申请重置密码的 'forgot'（邮箱）、'forgot_ip'
+    identifier VARCHAR(100) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (scope, identifier)
+);
+
+-- 系统集成使用的 API 密钥（只保存哈希，吊销后保留记录）
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_by BIGINT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    last_used_at TIMESTAMP NULL DEFAULT NULL,
+    revoked_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- API 密钥的权限范围
+CREATE TABLE IF NOT EXISTS api_key_scopes (
+    api_key_id BIGINT NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (api_key_id, permission),
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+-- 审计日志；不设外键，用户删除后记录仍然保留
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    actor_id BIGINT, -- 实际执行操作的用户，模拟登录时为管理员
+    user_id BIGINT, -- 操作以其身份执行的用户
+    api_key_id BIGINT, -- 通过 API 密钥执行时的密钥
+    action VARCHAR(50) NOT NULL,
+    resource VARCHAR(50) NOT NULL DEFAULT '',
+    resource_id VARCHAR(64) NOT NULL DEFAULT '',
+    before_data MEDIUMTEXT, -- 修改或删除前的数据（JSON）
+    after_data MEDIUMTEXT, -- 创建或修改后的数据（JSON）
+    details TEXT, -- JSON
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 审计日志只能追加，不能修改或删除
+CREATE TRIGGER audit_logs_no_update BEFORE UPDATE ON audit_logs
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+CREATE TRIGGER audit_logs_no_delete BEFORE DELETE ON audit_logs
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+-- 学生记录的历史版本（每次新增、修改和删除都由触发器保存一份快照）；不设外键，学生删除后历史仍然保留
+CREATE TABLE IF NOT EXISTS student_versions (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    student_id BIGINT NOT NULL, -- students.id
+    version INT NOT NULL, -- 每个学生从 1 开始递增
+    operation VARCHAR(10) NOT NULL, -- create、update、delete（移入回收站）、restore（从回收站恢复）或 purge（永久删除）
+    student_number VARCHAR(20) NOT NULL, -- 快照：students.student_id
+    name VARCHAR(100) NOT NULL,
+    class_id BIGINT,
+    email VARCHAR(100),
+    phone VARCHAR(20),
+    address TEXT,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_student_version (student_id, version)
+);
+
+-- 保存学生历史版本的触发器；修改时只有字段或删除状态真正变化才生成新版本
+CREATE TRIGGER students_version_insert AFTER INSERT ON students
+FOR EACH ROW INSERT INTO student_versions (student_id, version, operation, student_number, name, class_id, email, phone, address)
+SELECT NEW.id, COALESCE(MAX(version), 0) + 1, 'create', NEW.student_id, NEW.name, NEW.class_id, NEW.email, NEW.phone, NEW.address
+FROM student_versions WHERE student_id = NEW.id;
+CREATE TRIGGER students_version_update AFTER UPDATE ON students
+FOR EACH ROW INSERT INTO student_versions (student_id, version, operation, student_number, name, class_id, email, phone, address)
+SELECT NEW.id, COALESCE(MAX(version), 0) + 1,
+    CASE
+        WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN 'delete'
+        WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN 'restore'
+        ELSE 'update'
+    END,
+    NEW.student_id, NEW.name, NEW.class_id, NEW.email, NEW.phone, NEW.address
+FROM student_versions WHERE student_id = NEW.id
+HAVING NOT (NEW.student_id <=> OLD.student_id AND NEW.name <=> OLD.name AND NEW.class_id <=> OLD.class_id
+    AND NEW.email <=> OLD.email AND NEW.phone <=> OLD.phone AND NEW.address <=> OLD.address
+    AND NEW.deleted_at <=> OLD.deleted_at);
+CREATE TRIGGER students_version_delete AFTER DELETE ON students
+FOR EACH ROW INSERT INTO student_versions (student_id, version, operation, student_number, name, class_id, email, phone, address)
+SELECT OLD.id, COALESCE(MAX(version), 0) + 1, 'purge', OLD.student_id, OLD.name, OLD.class_id, OLD.email, OLD.phone, OLD.address
+FROM student_versions WHERE student_id = OLD.id;
+
+-- 学生和班级的每次修改都递增 row_version，包括移入回收站和恢复；带 If-Match 的修改以此检测并发冲突
+CREATE TRIGGER students_row_version BEFORE UPDATE ON students
+FOR EACH ROW SET NEW.row_version = OLD.row_version + 1;
+CREATE TRIGGER classes_row_version BEFORE UPDATE ON classes
+FOR EACH ROW SET NEW.row_version = OLD.row_version + 1;
+
+-- 为已有学生补建第一个版本
+INSERT INTO student_versions (student_id, version, operation, student_number, name, class_id, email, phone, address, changed_at)
+SELECT s.id, 1, 'create', s.student_id, s.name, s.class_id, s.email, s.phone, s.address, s.updated_at
+FROM students s
+WHERE NOT EXISTS (SELECT 1 FROM student_versions v WHERE v.student_id = s.id);
+
 -- 索引
 CREATE INDEX idx_student_name ON students(name);
 CREATE INDEX idx_student_class ON students(class_id);
 CREATE INDEX idx_class_name ON classes(name);
+CREATE INDEX idx_student_deleted ON students(deleted_at);
+CREATE INDEX idx_class_deleted ON classes(deleted_at);
 CREATE INDEX idx_user_username ON users(username);
+CREATE INDEX idx_refresh_token_user ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_token_session ON refresh_tokens(session_id);
+CREATE INDEX idx_session_user ON sessions(user_id);
+CREATE INDEX idx_revoked_token_expires ON revoked_tokens(expires_at);
+CREATE INDEX idx_teacher_class_class ON teacher_classes(class_id);
+CREATE INDEX idx_student_guardian_guardian ON student_guardians(guardian_id);
+CREATE INDEX idx_student_tag_tag ON student_tags(tag);
+CREATE INDEX idx_recovery_code_user ON totp_recovery_codes(user_id);
+CREATE INDEX idx_password_history_user ON password_history(user_id);
+CREATE INDEX idx_audit_log_created ON audit_logs(created_at);
+CREATE INDEX idx_audit_log_actor ON audit_logs(actor_id);
+CREATE INDEX idx_audit_log_resource ON audit_logs(resource, resource_id);
+
+-- 内置角色及其默认权限（admin 始终拥有全部权限）
+INSERT INTO roles (name, description, builtin) VALUES
+('admin', '系统管理员', TRUE),
+('user', '普通用户', TRUE),
+('teacher', '教师（仅能访问分配的班级）', TRUE),
+('student', '学生（只能查看和修改本人的联系方式）', TRUE),
+('parent', '家长（只能查看关联子女的信息）', TRUE);
+
+INSERT INTO role_permissions (role, permission) VALUES
+('user', 'students:read'),
+('user', 'students:write'),
+('user', 'classes:read'),
+('user', 'classes:write'),
+('teacher', 'students:read'),
+('teacher', 'students:write'),
+('teacher', 'classes:read');
 
 -- 创建管理员用户（密码：admin123）
 -- 在实际应用中，密码会在插入前进行 bcrypt 哈希处理
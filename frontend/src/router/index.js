@@ -3,15 +3,24 @@ import store from '../store'
 
 // Lazy load components
 const Login = () => import('../views/auth/Login.vue')
+const ForgotPassword = () => import('../views/auth/ForgotPassword.vue')
+const ResetPassword = () => import('../views/auth/ResetPassword.vue')
+const OIDCCallback = () => import('../views/auth/OIDCCallback.vue')
 const Dashboard = () => import('../views/Dashboard.vue')
 const StudentList = () => import('../views/students/StudentList.vue')
 const StudentForm = () => import('../views/students/StudentForm.vue')
 const StudentDetail = () => import('../views/students/StudentDetail.vue')
+const MyStudent = () => import('../views/students/MyStudent.vue')
+const MyChildren = () => import('../views/students/MyChildren.vue')
 const ClassList = () => import('../views/classes/ClassList.vue')
 const ClassForm = () => import('../views/classes/ClassForm.vue')
 const ClassDetail = () => import('../views/classes/ClassDetail.vue')
 const Profile = () => import('../views/auth/Profile.vue')
 const ChangePassword = () => import('../views/auth/ChangePassword.vue')
+const RoleList = () => import('../views/admin/RoleList.vue')
+const APIKeyList = () => import('../views/admin/APIKeyList.vue')
+const AuditLog = () => import('../views/admin/AuditLog.vue')
+const Trash = () => import('../views/admin/Trash.vue')
 const NotFound = () => import('../views/NotFound.vue')
 
 const routes = [
@@ -25,6 +34,25 @@ const routes = [
     component: Login,
     meta: { requiresAuth: false }
   },
+  {
+    path: '/forgot-password',
+    name: 'ForgotPassword',
+    component: ForgotPassword,
+    meta: { requiresAuth: false }
+  },
+  {
+    path: '/reset-password',
+    name: 'ResetPassword',
+    component: ResetPassword,
+    props: route => ({ token: route.query.token || '' }),
+    meta: { requiresAuth: false }
+  },
+  {
+    path: '/oidc/callback',
+    name: 'OIDCCallback',
+    component: OIDCCallback,
+    meta: { requiresAuth: false }
+  },
   {
     path: '/dashboard',
     name: 'Dashboard',
@@ -53,6 +81,16 @@ const routes = [
     component: StudentForm,
     props: route => ({ id: parseInt(route.params.id), isEdit: true })
   },
+  {
+    path: '/me/student',
+    name: 'MyStudent',
+    component: MyStudent
+  },
+  {
+    path: '/me/children',
+    name: 'MyChildren',
+    component: MyChildren
+  },
   // Class routes
   {
     path: '/classes',
@@ -87,6 +125,27 @@ const routes = [
     name: 'ChangePassword',
     component: ChangePassword
   },
+  // Admin routes
+  {
+    path: '/roles',
+    name: 'RoleList',
+    component: RoleList
+  },
+  {
+    path: '/api-keys',
+    name: 'APIKeyList',
+    component: APIKeyList
+  },
+  {
+    path: '/audit',
+    name: 'AuditLog',
+    component: AuditLog
+  },
+  {
+    path: '/trash',
+    name: 'Trash',
+    component: Trash
+  },
   // 404 route
   {
     path: '/:pathMatch(.*)*',
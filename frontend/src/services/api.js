@@ -20,14 +20,52 @@ apiClient.interceptors.request.use(config => {
   return config
 })
 
+// Exchange the stored refresh token for a new token pair
+const refreshTokens = async () => {
+  const refreshToken = localStorage.getItem('refreshToken')
+  if (!refreshToken) {
+    throw new Error('No refresh token')
+  }
+  const response = await axios.post(`${API_URL}/auth/refresh`, { refresh_token: refreshToken })
+  localStorage.setItem('token', response.data.token)
+  localStorage.setItem('refreshToken', response.data.refresh_token)
+  return response.data.token
+}
+
 // Add response interceptor to handle errors
 apiClient.interceptors.response.use(
   response => response,
-  error => {
+  async error => {
     // Handle authentication errors (401 Unauthorized)
     if (error.response && error.response.status === 401) {
+      // An expired impersonation token cannot be refreshed; go back to the admin's own session
+      const impersonator = localStorage.getItem('impersonator')
+      if (impersonator) {
+        const { token, refreshToken, user } = JSON.parse(impersonator)
+        localStorage.setItem('token', token)
+        localStorage.setItem('refreshToken', refreshToken)
+        localStorage.setItem('user', JSON.stringify(user))
+        localStorage.removeItem('impersonator')
+        window.location.href = '/dashboard'
+        return Promise.reject(error)
+      }
+
+      // Try once to refresh the access token and replay the request
+      const original = error.config
+      if (!original._retried) {
+        original._retried = true
+        try {
+          const token = await refreshTokens()
+          original.headers.Authorization = `Bearer ${token}`
+          return apiClient(original)
+        } catch (refreshError) {
+          // Fall through to logout
+        }
+      }
+      
       // Clear localStorage
       localStorage.removeItem('token')
+      localStorage.removeItem('refreshToken')
       localStorage.removeItem('user')
       
       // Redirect to login if not already there
@@ -42,18 +80,55 @@ apiClient.interceptors.response.use(
 // Auth API
 export const authAPI = {
   login: (credentials) => apiClient.post('/auth/login', credentials),
-  logout: () => apiClient.post('/auth/logout'),
+  logout: () => apiClient.post('/auth/logout', { refresh_token: localStorage.getItem('refreshToken') }),
+  refresh: (refreshToken) => apiClient.post('/auth/refresh', { refresh_token: refreshToken }),
   getProfile: () => apiClient.get('/auth/profile'),
-  changePassword: (data) => apiClient.post('/auth/change-password', data)
+  changePassword: (data) => apiClient.post('/auth/change-password', data),
+  forgotPassword: (email) => apiClient.post('/auth/forgot-password', { email }),
+  resetPassword: (token, newPassword) => apiClient.post('/auth/reset-password', { token, new_password: newPassword }),
+  loginTwoFactorSetup: (mfaToken) => apiClient.post('/auth/login/2fa/setup', { mfa_token: mfaToken }),
+  setupTwoFactor: () => apiClient.post('/auth/2fa/setup'),
+  enableTwoFactor: (code) => apiClient.post('/auth/2fa/enable', { code }),
+  disableTwoFactor: (password) => apiClient.post('/auth/2fa/disable', { password }),
+  regenerateRecoveryCodes: (code) => apiClient.post('/auth/2fa/recovery-codes', { code }),
+  getSessions: () => apiClient.get('/auth/sessions'),
+  revokeSession: (id) => apiClient.delete(`/auth/sessions/${id}`),
+  revokeOtherSessions: () => apiClient.post('/auth/sessions/revoke-others'),
+  // Single sign-on starts with a full-page redirect, not an XHR
+  oidcLoginURL: () => `${API_URL}/auth/oidc/login`
+}
+
+// Self-service API for the student role
+export const meAPI = {
+  getStudent: () => apiClient.get('/me/student'),
+  updateStudent: (data) => apiClient.patch('/me/student', data),
+  getChildren: () => apiClient.get('/me/children'),
+  getChild: (id) => apiClient.get(`/me/children/${id}`)
 }
 
 // Students API
 export const studentsAPI = {
   getAll: (params) => apiClient.get('/students', { params }),
-  getById: (id) => apiClient.get(`/students/${id}`),
+  getById: (id, params) => apiClient.get(`/students/${id}`, { params }),
   create: (data) => apiClient.post('/students', data),
   update: (id, data) => apiClient.put(`/students/${id}`, data),
-  delete: (id) => apiClient.delete(`/students/${id}`)
+  delete: (id) => apiClient.delete(`/students/${id}`),
+  import: (formData) => apiClient.post('/students/import', formData, {
+    headers: { 'Content-Type': 'multipart/form-data' }
+  }),
+  export: (params) => apiClient.get('/students/export', { params, responseType: 'blob' }),
+  bulk: (data) => apiClient.post('/students/bulk', data),
+  getHistory: (id) => apiClient.get(`/students/${id}/history`),
+  revert: (id, version) => apiClient.post(`/students/${id}/history/${version}/revert`)
+}
+
+// Guardians API (nested under a student)
+export const guardiansAPI = {
+  getAll: (studentId) => apiClient.get(`/students/${studentId}/guardians`),
+  create: (studentId, data) => apiClient.post(`/students/${studentId}/guardians`, data),
+  link: (studentId, guardianId) => apiClient.post(`/students/${studentId}/guardians`, { guardian_id: guardianId }),
+  update: (studentId, id, data) => apiClient.put(`/students/${studentId}/guardians/${id}`, data),
+  delete: (studentId, id) => apiClient.delete(`/students/${studentId}/guardians/${id}`)
 }
 
 // Classes API
@@ -61,9 +136,62 @@ export const classesAPI = {
   getAll: () => apiClient.get('/classes'),
   getById: (id) => apiClient.get(`/classes/${id}`),
   getStudents: (id) => apiClient.get(`/classes/${id}/students`),
+  exportRoster: (id, params) => apiClient.get(`/classes/${id}/roster`, { params, responseType: 'blob' }),
   create: (data) => apiClient.post('/classes', data),
   update: (id, data) => apiClient.put(`/classes/${id}`, data),
   delete: (id) => apiClient.delete(`/classes/${id}`)
 }
 
+// Users API (admin only)
+export const usersAPI = {
+  getAll: () => apiClient.get('/users'),
+  getById: (id) => apiClient.get(`/users/${id}`),
+  create: (data) => apiClient.post('/users', data),
+  update: (id, data) => apiClient.put(`/users/${id}`, data),
+  disable: (id) => apiClient.post(`/users/${id}/disable`),
+  enable: (id) => apiClient.post(`/users/${id}/enable`),
+  resetTwoFactor: (id) => apiClient.post(`/users/${id}/2fa/reset`),
+  getClasses: (id) => apiClient.get(`/users/${id}/classes`),
+  setClasses: (id, classIds) => apiClient.put(`/users/${id}/classes`, { class_ids: classIds }),
+  getStudent: (id) => apiClient.get(`/users/${id}/student`),
+  setStudent: (id, studentId) => apiClient.put(`/users/${id}/student`, { student_id: studentId }),
+  unlinkStudent: (id) => apiClient.delete(`/users/${id}/student`),
+  getGuardian: (id) => apiClient.get(`/users/${id}/guardian`),
+  setGuardian: (id, guardianId) => apiClient.put(`/users/${id}/guardian`, { guardian_id: guardianId }),
+  unlinkGuardian: (id) => apiClient.delete(`/users/${id}/guardian`),
+  impersonate: (id) => apiClient.post(`/users/${id}/impersonate`),
+  delete: (id) => apiClient.delete(`/users/${id}`)
+}
+
+// Roles API (requires roles:manage)
+export const rolesAPI = {
+  getAll: () => apiClient.get('/roles'),
+  getPermissions: () => apiClient.get('/permissions'),
+  create: (data) => apiClient.post('/roles', data),
+  update: (name, data) => apiClient.put(`/roles/${name}`, data),
+  delete: (name) => apiClient.delete(`/roles/${name}`)
+}
+
+// API keys API (requires api_keys:manage)
+export const apiKeysAPI = {
+  getAll: () => apiClient.get('/api-keys'),
+  create: (data) => apiClient.post('/api-keys', data),
+  revoke: (id) => apiClient.delete(`/api-keys/${id}`)
+}
+
+// Trash API for deleted students and classes
+export const trashAPI = {
+  getStudents: () => apiClient.get('/trash/students'),
+  restoreStudent: (id) => apiClient.post(`/trash/students/${id}/restore`),
+  purgeStudent: (id) => apiClient.delete(`/trash/students/${id}`),
+  getClasses: () => apiClient.get('/trash/classes'),
+  restoreClass: (id) => apiClient.post(`/trash/classes/${id}/restore`),
+  purgeClass: (id) => apiClient.delete(`/trash/classes/${id}`)
+}
+
+// Audit log API (requires audit:read)
+export const auditAPI = {
+  getAll: (params) => apiClient.get('/audit', { params })
+}
+
 export default apiClient 
\ No newline at end of file
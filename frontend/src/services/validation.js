@@ -0,0 +1,12 @@
+// Collect the field errors of a 422 validation response as { field: message }, for the
+// error prop of the form items. Returns null when the error is not a validation error.
+export const fieldErrors = error => {
+  const data = error.response?.data
+  if (error.response?.status !== 422 || !Array.isArray(data?.errors)) return null
+
+  const errors = {}
+  for (const { field, message } of data.errors) {
+    errors[field] = errors[field] ? `${errors[field]}；${message}` : message
+  }
+  return errors
+}
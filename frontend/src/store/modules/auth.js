@@ -12,54 +12,133 @@ const setAuthHeader = (token) => {
   }
 }
 
+// Store the tokens and user returned by a successful login
+const storeSession = (commit, data) => {
+  const { token, refresh_token: refreshToken, user: userData } = data
+  localStorage.setItem('token', token)
+  localStorage.setItem('refreshToken', refreshToken)
+  localStorage.setItem('user', JSON.stringify(userData))
+  setAuthHeader(token)
+  commit('AUTH_SUCCESS', { token, user: userData })
+}
+
+// Remove the stored session after a failed login
+const clearSession = (commit) => {
+  commit('AUTH_ERROR')
+  localStorage.removeItem('token')
+  localStorage.removeItem('refreshToken')
+  localStorage.removeItem('user')
+}
+
 const state = {
   token: localStorage.getItem('token') || '',
   user: JSON.parse(localStorage.getItem('user')) || null,
+  // The admin's own session while they are impersonating another user
+  impersonator: JSON.parse(localStorage.getItem('impersonator')) || null,
   status: ''
 }
 
 const getters = {
   isAuthenticated: state => !!state.token,
   authStatus: state => state.status,
-  user: state => state.user
+  user: state => state.user,
+  impersonator: state => state.impersonator,
+  hasPermission: state => permission => !!state.user?.permissions?.includes(permission)
 }
 
 const actions = {
-  // Login action
+  // Login action; resolves with the MFA challenge instead when a second factor is required
   async login({ commit }, user) {
     commit('AUTH_REQUEST')
     try {
       const response = await axios.post(`${API_URL}/auth/login`, user)
-      const { token, user: userData } = response.data
-      
-      // Store token in localStorage
-      localStorage.setItem('token', token)
-      localStorage.setItem('user', JSON.stringify(userData))
-      
-      // Set auth header
-      setAuthHeader(token)
-      
-      commit('AUTH_SUCCESS', { token, user: userData })
+      if (response.data.mfa_required) {
+        commit('AUTH_MFA_REQUIRED')
+        return response
+      }
+      storeSession(commit, response.data)
       return response
     } catch (error) {
-      commit('AUTH_ERROR', error)
-      localStorage.removeItem('token')
-      localStorage.removeItem('user')
+      clearSession(commit)
+      throw error
+    }
+  },
+
+  // Finish a login with a TOTP code or a recovery code
+  async loginTwoFactor({ commit }, { mfaToken, code, recoveryCode }) {
+    commit('AUTH_REQUEST')
+    try {
+      const response = await axios.post(`${API_URL}/auth/login/2fa`, {
+        mfa_token: mfaToken,
+        code,
+        recovery_code: recoveryCode
+      })
+      storeSession(commit, response.data)
+      return response
+    } catch (error) {
+      commit('AUTH_MFA_REQUIRED')
       throw error
     }
   },
   
-  // Logout action
-  async logout({ commit }) {
+  // Finish a single sign-on login with the tokens handed over by the callback page
+  async loginWithTokens({ dispatch }, { token, refreshToken }) {
+    localStorage.setItem('token', token)
+    localStorage.setItem('refreshToken', refreshToken)
+    return dispatch('restoreSession', token)
+  },
+  
+  // Act as another user; the admin's own tokens are kept aside until the impersonation ends
+  async startImpersonation({ commit, state }, userId) {
+    const response = await axios.post(`${API_URL}/users/${userId}/impersonate`)
+    const impersonator = {
+      token: state.token,
+      refreshToken: localStorage.getItem('refreshToken'),
+      user: state.user
+    }
+    localStorage.setItem('impersonator', JSON.stringify(impersonator))
+    localStorage.setItem('token', response.data.token)
+    localStorage.removeItem('refreshToken')
+    localStorage.setItem('user', JSON.stringify(response.data.user))
+    setAuthHeader(response.data.token)
+    commit('IMPERSONATION_START', { impersonator, token: response.data.token, user: response.data.user })
+    return response
+  },
+  
+  // Stop impersonating: revoke the impersonation token and restore the admin's session
+  async stopImpersonation({ commit, state }) {
+    const impersonator = state.impersonator
+    if (!impersonator) return
     try {
-      // Call logout endpoint if needed
       await axios.post(`${API_URL}/auth/logout`)
     } catch (error) {
       console.error('Logout error:', error)
     }
+    localStorage.setItem('token', impersonator.token)
+    localStorage.setItem('refreshToken', impersonator.refreshToken)
+    localStorage.setItem('user', JSON.stringify(impersonator.user))
+    localStorage.removeItem('impersonator')
+    setAuthHeader(impersonator.token)
+    commit('IMPERSONATION_END')
+  },
+  
+  // Logout action
+  async logout({ commit, dispatch, state }) {
+    if (state.impersonator) {
+      await dispatch('stopImpersonation')
+    }
+    try {
+      // Revoke the access token and refresh token on the server
+      await axios.post(`${API_URL}/auth/logout`, {
+        refresh_token: localStorage.getItem('refreshToken')
+      })
+    } catch (error) {
+      console.error('Logout error:', error)
+    }
     
     // Clean up regardless of the API call result
     localStorage.removeItem('token')
+    localStorage.removeItem('refreshToken')
     localStorage.removeItem('user')
     setAuthHeader(null)
     commit('AUTH_LOGOUT')
@@ -82,6 +161,7 @@ const actions = {
     } catch (error) {
       commit('AUTH_ERROR', error)
       localStorage.removeItem('token')
+      localStorage.removeItem('refreshToken')
       localStorage.removeItem('user')
       setAuthHeader(null)
       throw error
@@ -89,12 +169,19 @@ const actions = {
   },
   
   // Change password
-  async changePassword({ commit }, { oldPassword, newPassword }) {
+  async changePassword({ commit, state }, { oldPassword, newPassword }) {
     try {
       const response = await axios.post(`${API_URL}/auth/change-password`, {
         old_password: oldPassword,
         new_password: newPassword
       })
+      
+      // All other sessions were revoked; keep this one with the new tokens
+      const { token, refresh_token: refreshToken } = response.data
+      localStorage.setItem('token', token)
+      localStorage.setItem('refreshToken', refreshToken)
+      setAuthHeader(token)
+      commit('AUTH_SUCCESS', { token, user: state.user })
       return response
     } catch (error) {
       throw error
@@ -111,6 +198,9 @@ const mutations = {
     state.token = token
     state.user = user
   },
+  AUTH_MFA_REQUIRED(state) {
+    state.status = 'mfa'
+  },
   AUTH_ERROR(state) {
     state.status = 'error'
     state.token = ''
@@ -120,6 +210,16 @@ const mutations = {
     state.status = ''
     state.token = ''
     state.user = null
+  },
+  IMPERSONATION_START(state, { impersonator, token, user }) {
+    state.impersonator = impersonator
+    state.token = token
+    state.user = user
+  },
+  IMPERSONATION_END(state) {
+    state.token = state.impersonator.token
+    state.user = state.impersonator.user
+    state.impersonator = null
   }
 }
 
@@ -6,7 +6,9 @@ const API_URL = process.env.VUE_APP_API_URL || 'http://localhost:8080/api'
 const state = {
   classes: [],
   class: null,
-  classStudents: []
+  classStudents: [],
+  // ETags of loaded classes by ID, sent as If-Match so concurrent edits are detected
+  etags: {}
 }
 
 const getters = {
@@ -39,6 +41,7 @@ const actions = {
     try {
       const response = await axios.get(`${API_URL}/classes/${id}`)
       commit('SET_CLASS', response.data)
+      commit('SET_ETAG', { id: response.data.id, etag: response.headers.etag })
       return response.data
     } catch (error) {
       console.error(`Error fetching class ${id}:`, error)
@@ -71,10 +74,15 @@ const actions = {
   },
   
   // Update an existing class
-  async updateClass({ commit }, classData) {
+  // Fails with status 412 when the class was changed since it was loaded
+  async updateClass({ commit, state }, classData) {
     try {
-      const response = await axios.put(`${API_URL}/classes/${classData.id}`, classData)
+      const etag = state.etags[classData.id]
+      const response = await axios.put(`${API_URL}/classes/${classData.id}`, classData, {
+        headers: etag ? { 'If-Match': etag } : {}
+      })
       commit('UPDATE_CLASS', response.data)
+      commit('SET_ETAG', { id: response.data.id, etag: response.headers.etag })
       return response.data
     } catch (error) {
       console.error(`Error updating class ${classData.id}:`, error)
@@ -102,6 +110,9 @@ const mutations = {
   SET_CLASS(state, classData) {
     state.class = classData
   },
+  SET_ETAG(state, { id, etag }) {
+    state.etags = { ...state.etags, [id]: etag }
+  },
   SET_CLASS_STUDENTS(state, students) {
     state.classStudents = students
   },
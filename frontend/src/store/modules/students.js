@@ -6,6 +6,8 @@ const API_URL = process.env.VUE_APP_API_URL || 'http://localhost:8080/api'
 const state = {
   students: [],
   student: null,
+  // ETags of loaded students by ID, sent as If-Match so concurrent edits are detected
+  etags: {},
   pagination: {
     total: 0,
     page: 1,
@@ -14,7 +16,8 @@ const state = {
   filters: {
     classId: '',
     studentId: '',
-    name: ''
+    name: '',
+    tag: ''
   }
 }
 
@@ -31,13 +34,14 @@ const actions = {
   async fetchStudents({ commit, state }) {
     try {
       const { page, pageSize } = state.pagination
-      const { classId, studentId, name } = state.filters
+      const { classId, studentId, name, tag } = state.filters
       
       // Build query params
       let params = `page=${page}&page_size=${pageSize}`
       if (classId) params += `&class_id=${classId}`
       if (studentId) params += `&student_id=${studentId}`
       if (name) params += `&name=${name}`
+      if (tag) params += `&tag=${encodeURIComponent(tag)}`
       
       const response = await axios.get(`${API_URL}/students?${params}`)
       const { data, pagination } = response.data
@@ -57,6 +61,7 @@ const actions = {
     try {
       const response = await axios.get(`${API_URL}/students/${id}`)
       commit('SET_STUDENT', response.data)
+      commit('SET_ETAG', { id: response.data.id, etag: response.headers.etag })
       return response.data
     } catch (error) {
       console.error(`Error fetching student ${id}:`, error)
@@ -77,10 +82,15 @@ const actions = {
   },
   
   // Update an existing student
-  async updateStudent({ commit }, student) {
+  // Fails with status 412 when the student was changed since it was loaded
+  async updateStudent({ commit, state }, student) {
     try {
-      const response = await axios.put(`${API_URL}/students/${student.id}`, student)
+      const etag = state.etags[student.id]
+      const response = await axios.put(`${API_URL}/students/${student.id}`, student, {
+        headers: etag ? { 'If-Match': etag } : {}
+      })
       commit('UPDATE_STUDENT', response.data)
+      commit('SET_ETAG', { id: response.data.id, etag: response.headers.etag })
       return response.data
     } catch (error) {
       console.error(`Error updating student ${student.id}:`, error)
@@ -154,6 +164,9 @@ const mutations = {
   },
   SET_FILTERS(state, filters) {
     state.filters = { ...state.filters, ...filters }
+  },
+  SET_ETAG(state, { id, etag }) {
+    state.etags = { ...state.etags, [id]: etag }
   }
 }
 